@@ -10,6 +10,7 @@ import (
 	"github.com/ezquant/azbot/azbot/model"
 	"github.com/ezquant/azbot/azbot/notification"
 	"github.com/ezquant/azbot/azbot/order"
+	"github.com/ezquant/azbot/azbot/risk"
 	"github.com/ezquant/azbot/azbot/service"
 	"github.com/ezquant/azbot/azbot/storage"
 	"github.com/ezquant/azbot/azbot/strategy"
@@ -47,11 +48,15 @@ type AzBot struct {
 	orderController       *order.Controller
 	priorityQueueCandle   *model.PriorityQueue
 	strategiesControllers map[string]*strategy.Controller
+	portfolioController   *strategy.PortfolioController
 	orderFeed             *order.Feed
 	dataFeed              *exchange.DataFeedSubscription
 	paperWallet           *exchange.PaperWallet
+	riskLimits            *risk.Limits
+	riskManager           *risk.Manager
 
-	backtest bool
+	backtest     bool
+	hideProgress bool
 }
 
 type Option func(*AzBot)
@@ -99,6 +104,16 @@ func NewBot(ctx context.Context, settings model.Settings, exch service.Exchange,
 		WithNotifier(bot.telegram)(bot)
 	}
 
+	if bot.riskLimits != nil {
+		_, quote := exchange.SplitAssetQuote(settings.Pairs[0])
+		var options []risk.Option
+		if bot.notifier != nil {
+			options = append(options, risk.WithNotifier(bot.notifier))
+		}
+		bot.riskManager = risk.NewManager(bot.orderController, quote, *bot.riskLimits, options...)
+		bot.SubscribeOrder(bot.riskManager)
+	}
+
 	return bot, nil
 }
 
@@ -112,6 +127,13 @@ func WithBacktest(wallet *exchange.PaperWallet) Option {
 	}
 }
 
+// WithProgressBar enables or disables the progress bar displayed in stderr during backtesting (enabled by default)
+func WithProgressBar(enabled bool) Option {
+	return func(bot *AzBot) {
+		bot.hideProgress = !enabled
+	}
+}
+
 // WithStorage sets the storage for the bot, by default it uses a local file called azbot.db
 func WithStorage(storage storage.Storage) Option {
 	return func(bot *AzBot) {
@@ -135,6 +157,13 @@ func WithNotifier(notifier service.Notifier) Option {
 	}
 }
 
+// WithRiskManager validates orders of the strategy against the given limits, pairs must share the same quote asset
+func WithRiskManager(limits risk.Limits) Option {
+	return func(bot *AzBot) {
+		bot.riskLimits = &limits
+	}
+}
+
 // WithCandleSubscription subscribes a given struct to the candle feed
 func WithCandleSubscription(subscriber CandleSubscriber) Option {
 	return func(bot *AzBot) {
@@ -175,8 +204,21 @@ func (n *AzBot) Controller() *order.Controller {
 	return n.orderController
 }
 
+// RiskManager returns the risk manager, nil when the bot is created without WithRiskManager
+func (n *AzBot) RiskManager() *risk.Manager {
+	return n.riskManager
+}
+
+// broker returns the broker used by strategies
+func (n *AzBot) broker() service.Broker {
+	if n.riskManager != nil {
+		return n.riskManager
+	}
+	return n.orderController
+}
+
 // Summary function displays all trades, accuracy and some bot metrics in stdout
-// To access the raw data, you may access `bot.Controller().Results`
+// To access the raw data, you may use `bot.Report()` or access `bot.Controller().Results`
 func (n *AzBot) Summary() {
 	var (
 		total  float64
@@ -236,21 +278,41 @@ func (n *AzBot) onCandle(candle model.Candle) {
 }
 
 func (n *AzBot) processCandle(candle model.Candle) {
+	// partial candles of the next time arrive before the other pairs close the current time
+	if n.portfolioController != nil && candle.Complete {
+		n.portfolioController.Advance(candle.Time)
+	}
+
 	if n.paperWallet != nil {
 		n.paperWallet.OnCandle(candle)
 	}
 
+	if n.riskManager != nil {
+		n.riskManager.OnCandle(candle)
+	}
+
 	n.strategiesControllers[candle.Pair].OnPartialCandle(candle)
 	if candle.Complete {
 		n.strategiesControllers[candle.Pair].OnCandle(candle)
+		if n.portfolioController != nil {
+			n.portfolioController.OnCandle(candle)
+		}
 		n.orderController.OnCandle(candle)
 	}
 }
 
-// Process pending candles in buffer
-func (n *AzBot) processCandles() {
-	for item := range n.priorityQueueCandle.PopLock() {
-		n.processCandle(item.(model.Candle))
+// Process pending candles in buffer until ctx is canceled
+func (n *AzBot) processCandles(ctx context.Context) {
+	candles := n.priorityQueueCandle.PopLock()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case item := <-candles:
+			if candle, ok := item.(model.Candle); ok {
+				n.processCandle(candle)
+			}
+		}
 	}
 }
 
@@ -260,23 +322,42 @@ func (n *AzBot) backtestCandles() {
 	log.Info("[SETUP] Starting backtesting")
 
 	progressBar := progressbar.Default(int64(n.priorityQueueCandle.Len()))
+	if n.hideProgress {
+		progressBar = progressbar.DefaultSilent(int64(n.priorityQueueCandle.Len()))
+	}
 	for n.priorityQueueCandle.Len() > 0 {
 		item := n.priorityQueueCandle.Pop()
 
 		candle := item.(model.Candle)
+		if n.portfolioController != nil && candle.Complete {
+			n.portfolioController.Advance(candle.Time)
+		}
+
 		if n.paperWallet != nil {
 			n.paperWallet.OnCandle(candle)
 		}
 
+		if n.riskManager != nil {
+			n.riskManager.OnCandle(candle)
+		}
+
 		n.strategiesControllers[candle.Pair].OnPartialCandle(candle)
 		if candle.Complete {
 			n.strategiesControllers[candle.Pair].OnCandle(candle)
+			if n.portfolioController != nil {
+				n.portfolioController.OnCandle(candle)
+			}
 		}
 
 		if err := progressBar.Add(1); err != nil {
 			log.Warnf("update progressbar fail: %v", err)
 		}
 	}
+
+	// execute the portfolio strategy with the last candles
+	if n.portfolioController != nil {
+		n.portfolioController.Flush()
+	}
 }
 
 // Before Azbot start, we need to load the necessary data to fill strategy indicators
@@ -286,6 +367,19 @@ func (n *AzBot) preload(ctx context.Context, pair string) error {
 		return nil
 	}
 
+	if str, ok := n.strategy.(strategy.MultiTimeframeStrategy); ok {
+		for _, timeframe := range str.Timeframes() {
+			candles, err := n.exchange.CandlesByLimit(ctx, pair, timeframe, n.strategy.WarmupPeriod())
+			if err != nil {
+				return err
+			}
+
+			for _, candle := range candles {
+				n.strategiesControllers[pair].OnTimeframeCandle(timeframe, candle)
+			}
+		}
+	}
+
 	candles, err := n.exchange.CandlesByLimit(ctx, pair, n.strategy.Timeframe(), n.strategy.WarmupPeriod())
 	if err != nil {
 		return err
@@ -300,11 +394,18 @@ func (n *AzBot) preload(ctx context.Context, pair string) error {
 	return nil
 }
 
-// Run will initialize the strategy controller, order controller, preload data and start the bot
+// Run will initialize the strategy controller, order controller, preload data and start the bot.
+// In live and paper trading, it blocks until ctx is canceled, then stops the order controller and
+// returns nil, so the caller can print the summary and close the storage
 func (n *AzBot) Run(ctx context.Context) error {
+	if str, ok := n.strategy.(strategy.PortfolioStrategy); ok {
+		n.portfolioController = strategy.NewPortfolioController(n.settings.Pairs, str, n.broker(),
+			n.strategiesControllers)
+	}
+
 	for _, pair := range n.settings.Pairs {
 		// setup and subscribe strategy to data feed (candles)
-		n.strategiesControllers[pair] = strategy.NewStrategyController(pair, n.strategy, n.orderController)
+		n.strategiesControllers[pair] = strategy.NewStrategyController(pair, n.strategy, n.broker())
 
 		// preload candles for warmup period
 		err := n.preload(ctx, pair)
@@ -315,10 +416,27 @@ func (n *AzBot) Run(ctx context.Context) error {
 		// link to azbot controller
 		n.dataFeed.Subscribe(pair, n.strategy.Timeframe(), n.onCandle, false)
 
+		// additional timeframes are consumed only by the strategy controller
+		if str, ok := n.strategy.(strategy.MultiTimeframeStrategy); ok {
+			controller := n.strategiesControllers[pair]
+			for _, timeframe := range str.Timeframes() {
+				timeframe := timeframe
+				n.dataFeed.Subscribe(pair, timeframe, func(candle model.Candle) {
+					controller.OnTimeframeCandle(timeframe, candle)
+				}, true)
+			}
+		}
+
 		// start strategy controller
 		n.strategiesControllers[pair].Start()
 	}
 
+	if n.portfolioController != nil {
+		// flush candles of the warmup period
+		n.portfolioController.Flush()
+		n.portfolioController.Start()
+	}
+
 	// start order feed and controller
 	n.orderFeed.Start()
 	n.orderController.Start()
@@ -328,13 +446,14 @@ func (n *AzBot) Run(ctx context.Context) error {
 	}
 
 	// start data feed and receives new candles
-	n.dataFeed.Start(n.backtest)
+	n.dataFeed.Start(ctx, n.backtest)
 
 	// start processing new candles for production or backtesting environment
 	if n.backtest {
 		n.backtestCandles()
 	} else {
-		n.processCandles()
+		n.processCandles(ctx)
+		log.Info("[SHUTDOWN] Stopping bot")
 	}
 
 	return nil
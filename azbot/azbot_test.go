@@ -2,9 +2,17 @@ package azbot
 
 import (
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ezquant/azbot/azbot/exchange"
+	"github.com/ezquant/azbot/azbot/model"
+	"github.com/ezquant/azbot/azbot/risk"
 	"github.com/ezquant/azbot/azbot/service"
 	"github.com/ezquant/azbot/azbot/storage"
 	"github.com/ezquant/azbot/azbot/strategy"
@@ -111,5 +119,351 @@ func TestMarketOrder(t *testing.T) {
 	require.Len(t, results.Win(), 9)
 	require.Len(t, results.Lose(), 8)
 
+	report := bot.Report()
+	require.Len(t, report.Pairs, 2)
+	require.Equal(t, "BTCUSDT", report.Pairs[0].Pair)
+	require.Equal(t, 17, report.Pairs[0].Trades)
+	require.InDelta(t, 7424.3705, report.Pairs[0].Profit, 0.001)
+	require.Len(t, report.Pairs[0].Profits, 17)
+	require.Equal(t, 34, report.Trades)
+	require.Len(t, report.ClosedTrades, 34)
+	for i := 1; i < len(report.ClosedTrades); i++ {
+		require.False(t, report.ClosedTrades[i].Time.Before(report.ClosedTrades[i-1].Time))
+	}
+	require.Equal(t, 10000.0, report.InitialValue)
+	require.InDelta(t, report.InitialValue+report.Profit, report.FinalValue, 0.001)
+	require.InDelta(t, report.Profit/report.InitialValue, report.Return, 0.001)
+	require.NotEmpty(t, report.Equity)
+	require.Equal(t, report.Equity[len(report.Equity)-1].Time, report.End)
+	require.Less(t, report.MaxDrawdown, 0.0)
+	require.Greater(t, report.Exposure, 0.0)
+	require.LessOrEqual(t, report.Exposure, 1.0)
+	require.Equal(t, 34, report.Metrics.Trades)
+	require.InDelta(t, report.Return, report.Metrics.TotalReturn, 0.001)
+	require.NotZero(t, report.Metrics.Sharpe)
+
 	bot.Summary()
 }
+
+func TestRunCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+
+	strategy := new(fakeStrategy)
+	csvFeed, err := exchange.NewCSVFeed(strategy.Timeframe(), exchange.PairFeed{
+		Pair:      "BTCUSDT",
+		File:      "../testdata/btc-1h.csv",
+		Timeframe: "1h",
+	})
+	require.NoError(t, err)
+
+	// without WithBacktest, the bot processes candles as in paper trading until ctx is canceled
+	paperWallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000),
+		exchange.WithDataFeed(csvFeed))
+	bot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, paperWallet, strategy,
+		WithStorage(storage), WithPaperWallet(paperWallet), WithLogLevel(log.ErrorLevel))
+	require.NoError(t, err)
+
+	done := make(chan error)
+	go func() {
+		done <- bot.Run(ctx)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after ctx was canceled")
+	}
+}
+
+type fakeMultiTimeframeStrategy struct {
+	candles      int
+	dailyCandles int
+	lookAhead    bool
+}
+
+func (e fakeMultiTimeframeStrategy) Timeframe() string {
+	return "1h"
+}
+
+func (e fakeMultiTimeframeStrategy) Timeframes() []string {
+	return []string{"1d"}
+}
+
+func (e fakeMultiTimeframeStrategy) WarmupPeriod() int {
+	return 1
+}
+
+func (e fakeMultiTimeframeStrategy) Indicators(_ *Dataframe) []strategy.ChartIndicator {
+	return nil
+}
+
+func (e *fakeMultiTimeframeStrategy) TimeframeIndicators(_ string, df *Dataframe) {
+	if len(df.Close) >= 3 {
+		df.Metadata["sma3"] = talib.Sma(df.Close, 3)
+	}
+}
+
+func (e *fakeMultiTimeframeStrategy) OnCandle(df *Dataframe, _ service.Broker) {
+	e.candles++
+	daily := df.Timeframes["1d"]
+	if len(daily.Time) == 0 {
+		return
+	}
+
+	e.dailyCandles = len(daily.Time)
+	if daily.Time[len(daily.Time)-1].Add(24 * time.Hour).After(df.Time[len(df.Time)-1].Add(time.Hour)) {
+		e.lookAhead = true
+	}
+}
+
+func TestMultiTimeframeStrategy(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+
+	strategy := new(fakeMultiTimeframeStrategy)
+	csvFeed, err := exchange.NewCSVFeed(strategy.Timeframe(), exchange.PairFeed{
+		Pair:      "BTCUSDT",
+		File:      "../testdata/btc-1h.csv",
+		Timeframe: "1h",
+	})
+	require.NoError(t, err)
+	require.NoError(t, csvFeed.AddTimeframes(strategy.Timeframes()...))
+
+	paperWallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000),
+		exchange.WithDataFeed(csvFeed))
+
+	bot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, paperWallet, strategy,
+		WithStorage(storage), WithBacktest(paperWallet), WithLogLevel(log.ErrorLevel), WithProgressBar(false))
+	require.NoError(t, err)
+	require.NoError(t, bot.Run(ctx))
+
+	var daily int
+	for _, candle := range csvFeed.CandlePairTimeFrame["BTCUSDT--1d"] {
+		if candle.Complete {
+			daily++
+		}
+	}
+
+	require.Equal(t, len(csvFeed.CandlePairTimeFrame["BTCUSDT--1h"]), strategy.candles)
+	require.Equal(t, daily, strategy.dailyCandles)
+	require.False(t, strategy.lookAhead)
+	require.Len(t, bot.strategiesControllers["BTCUSDT"].Dataframe().Timeframes["1d"].Metadata["sma3"], daily)
+}
+
+type fakePortfolioStrategy struct {
+	fakeStrategy
+	calls  int
+	synced int
+
+	// last is the time of the latest candle of the previous call, each call must see a later time
+	last      time.Time
+	lookAhead bool
+}
+
+func (e *fakePortfolioStrategy) OnCandle(_ *Dataframe, _ service.Broker) {}
+
+func (e *fakePortfolioStrategy) OnPortfolioCandle(dataframes map[string]*Dataframe, _ service.Broker) {
+	e.calls++
+	btc, eth := dataframes["BTCUSDT"], dataframes["ETHUSDT"]
+	if btc != nil && eth != nil && btc.LastUpdate.Equal(eth.LastUpdate) {
+		e.synced++
+	}
+
+	var latest time.Time
+	for _, dataframe := range dataframes {
+		if last := dataframe.Time[len(dataframe.Time)-1]; last.After(latest) {
+			latest = last
+		}
+	}
+	if !latest.After(e.last) {
+		e.lookAhead = true
+	}
+	e.last = latest
+}
+
+func TestPortfolioStrategy(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+
+	strategy := new(fakePortfolioStrategy)
+	csvFeed, err := exchange.NewCSVFeed(strategy.Timeframe(),
+		exchange.PairFeed{Pair: "BTCUSDT", File: "../testdata/btc-1h.csv", Timeframe: "1h"},
+		exchange.PairFeed{Pair: "ETHUSDT", File: "../testdata/eth-1h.csv", Timeframe: "1h"},
+	)
+	require.NoError(t, err)
+
+	paperWallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000),
+		exchange.WithDataFeed(csvFeed))
+
+	bot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT", "ETHUSDT"}}, paperWallet, strategy,
+		WithStorage(storage), WithBacktest(paperWallet), WithLogLevel(log.ErrorLevel), WithProgressBar(false))
+	require.NoError(t, err)
+	require.NoError(t, bot.Run(ctx))
+
+	// ETH data starts and ends one day after BTC
+	pairsByTime := make(map[time.Time]int)
+	for _, pair := range []string{"BTCUSDT", "ETHUSDT"} {
+		for _, candle := range csvFeed.CandlePairTimeFrame[pair+"--1d"] {
+			if candle.Complete {
+				pairsByTime[candle.Time]++
+			}
+		}
+	}
+
+	var common int
+	for _, pairs := range pairsByTime {
+		if pairs == 2 {
+			common++
+		}
+	}
+
+	warmup := strategy.WarmupPeriod() - 1
+	require.Equal(t, len(pairsByTime)-warmup, strategy.calls)
+	require.Equal(t, common-warmup, strategy.synced)
+}
+
+func TestPortfolioStrategy_missingCandle(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+
+	// ETH has no candles in a day of the middle of the data
+	data, err := os.ReadFile("../testdata/eth-1h.csv")
+	require.NoError(t, err)
+
+	missing := time.Date(2021, 2, 10, 0, 0, 0, 0, time.UTC)
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		timestamp, err := strconv.ParseInt(strings.Split(line, ",")[0], 10, 64)
+		require.NoError(t, err)
+		if day := time.Unix(timestamp, 0).UTC(); day.Before(missing) || !day.Before(missing.AddDate(0, 0, 1)) {
+			lines = append(lines, line)
+		}
+	}
+	ethFile := filepath.Join(t.TempDir(), "eth-1h.csv")
+	require.NoError(t, os.WriteFile(ethFile, []byte(strings.Join(lines, "\n")), 0644))
+
+	strategy := new(fakePortfolioStrategy)
+	csvFeed, err := exchange.NewCSVFeed(strategy.Timeframe(),
+		exchange.PairFeed{Pair: "BTCUSDT", File: "../testdata/btc-1h.csv", Timeframe: "1h"},
+		exchange.PairFeed{Pair: "ETHUSDT", File: ethFile, Timeframe: "1h"},
+	)
+	require.NoError(t, err)
+
+	paperWallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000),
+		exchange.WithDataFeed(csvFeed))
+
+	bot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT", "ETHUSDT"}}, paperWallet, strategy,
+		WithStorage(storage), WithBacktest(paperWallet), WithLogLevel(log.ErrorLevel), WithProgressBar(false))
+	require.NoError(t, err)
+	require.NoError(t, bot.Run(ctx))
+
+	require.Positive(t, strategy.calls)
+	require.False(t, strategy.lookAhead, "the strategy received the candles of the next time")
+}
+
+func TestPortfolioStrategy_partialCandles(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+
+	str := new(fakePortfolioStrategy)
+	pairs := []string{"BTCUSDT", "ETHUSDT"}
+	paperWallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000))
+	bot, err := NewBot(ctx, Settings{Pairs: pairs}, paperWallet, str, WithStorage(storage),
+		WithLogLevel(log.ErrorLevel))
+	require.NoError(t, err)
+
+	for _, pair := range pairs {
+		bot.strategiesControllers[pair] = strategy.NewStrategyController(pair, str, bot.broker())
+		bot.strategiesControllers[pair].Start()
+	}
+	bot.portfolioController = strategy.NewPortfolioController(pairs, str, bot.broker(), bot.strategiesControllers)
+	bot.portfolioController.Start()
+
+	// the partial candle of the next day of BTC arrives before ETH closes the day, as in live feeds
+	days := 20
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < days; i++ {
+		day := start.AddDate(0, 0, i)
+		bot.processCandle(model.Candle{Pair: "BTCUSDT", Time: day, UpdatedAt: day, Close: 100, Complete: true})
+		bot.processCandle(model.Candle{Pair: "BTCUSDT", Time: day.AddDate(0, 0, 1), UpdatedAt: day.AddDate(0, 0, 1),
+			Close: 100})
+		bot.processCandle(model.Candle{Pair: "ETHUSDT", Time: day, UpdatedAt: day, Close: 10, Complete: true})
+	}
+	bot.portfolioController.Flush()
+
+	warmup := str.WarmupPeriod() - 1
+	require.Equal(t, days-warmup, str.calls)
+	require.Equal(t, days-warmup, str.synced)
+	require.False(t, str.lookAhead)
+}
+
+type fakeRiskStrategy struct {
+	rejected int
+}
+
+func (e fakeRiskStrategy) Timeframe() string {
+	return "1d"
+}
+
+func (e fakeRiskStrategy) WarmupPeriod() int {
+	return 1
+}
+
+func (e fakeRiskStrategy) Indicators(_ *Dataframe) []strategy.ChartIndicator {
+	return nil
+}
+
+func (e *fakeRiskStrategy) OnCandle(df *Dataframe, broker service.Broker) {
+	_, err := broker.CreateOrderMarketQuote(SideTypeBuy, df.Pair, 300)
+	if errors.Is(err, risk.ErrPositionLimit) {
+		e.rejected++
+	}
+}
+
+func TestRiskManager(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+
+	strategy := new(fakeRiskStrategy)
+	csvFeed, err := exchange.NewCSVFeed(strategy.Timeframe(), exchange.PairFeed{
+		Pair:      "BTCUSDT",
+		File:      "../testdata/btc-1h.csv",
+		Timeframe: "1h",
+	})
+	require.NoError(t, err)
+
+	paperWallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000),
+		exchange.WithDataFeed(csvFeed))
+
+	bot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, paperWallet, strategy,
+		WithStorage(storage), WithBacktest(paperWallet), WithLogLevel(log.ErrorLevel), WithProgressBar(false),
+		WithRiskManager(risk.Limits{MaxPositionValue: 1000}))
+	require.NoError(t, err)
+	require.NotNil(t, bot.RiskManager())
+	require.NoError(t, bot.Run(ctx))
+
+	// orders are rejected after three buys of 300 USDT
+	require.Greater(t, strategy.rejected, 0)
+	assets, quote, err := paperWallet.Position("BTCUSDT")
+	require.NoError(t, err)
+	require.InDelta(t, 9100, quote, 0.01)
+	require.Greater(t, assets, 0.0)
+}
@@ -0,0 +1,277 @@
+// Package calendar describes the trading sessions and holidays of exchanges, it is used to aggregate
+// candles and schedule orders of markets that don't trade 24/7, eg: A-shares.
+package calendar
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+// Shanghai is the time zone of Shanghai and Shenzhen stock exchanges (Beijing time)
+var Shanghai = time.FixedZone("UTC+8", int(8*time.Hour/time.Second))
+
+// Session is a trading period of a day, as offsets from the local midnight
+type Session struct {
+	Open  time.Duration
+	Close time.Duration
+}
+
+// Calendar is the trading schedule of an exchange
+type Calendar struct {
+	Name     string
+	Location *time.Location
+	Sessions []Session
+	Weekend  []time.Weekday
+
+	holidays map[string]bool
+}
+
+// New creates a calendar with the given sessions, ordered by time, and closed weekdays
+func New(name string, location *time.Location, sessions []Session, weekend ...time.Weekday) *Calendar {
+	return &Calendar{
+		Name:     name,
+		Location: location,
+		Sessions: sessions,
+		Weekend:  weekend,
+		holidays: make(map[string]bool),
+	}
+}
+
+// Crypto returns a calendar of markets that trade 24/7, days start at midnight UTC
+func Crypto() *Calendar {
+	return New("crypto", time.UTC, []Session{{Open: 0, Close: day}})
+}
+
+// AShare returns the calendar of Shanghai and Shenzhen stock exchanges, sessions are from 09:30 to 11:30
+// and from 13:00 to 15:00 (Beijing time). Holidays are published yearly by the exchanges, they can be
+// added from a list, a file or the trading days of an index.
+func AShare() *Calendar {
+	return New("XSHG", Shanghai, []Session{
+		{Open: 9*time.Hour + 30*time.Minute, Close: 11*time.Hour + 30*time.Minute},
+		{Open: 13 * time.Hour, Close: 15 * time.Hour},
+	}, time.Saturday, time.Sunday)
+}
+
+func (c *Calendar) midnight(t time.Time) time.Time {
+	local := t.In(c.Location)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, c.Location)
+}
+
+// AddHolidays closes the calendar in the days of the given times
+func (c *Calendar) AddHolidays(dates ...time.Time) {
+	if c.holidays == nil {
+		c.holidays = make(map[string]bool)
+	}
+
+	for _, date := range dates {
+		c.holidays[date.In(c.Location).Format(time.DateOnly)] = true
+	}
+}
+
+// LoadHolidays reads holidays from a file with a date per line in the format 2006-01-02,
+// empty lines and lines starting with # are ignored
+func (c *Calendar) LoadHolidays(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		date, err := time.ParseInLocation(time.DateOnly, line, c.Location)
+		if err != nil {
+			return fmt.Errorf("invalid holiday in %s: %w", file, err)
+		}
+		c.AddHolidays(date)
+	}
+
+	return scanner.Err()
+}
+
+// AddTradingDays marks as holidays the weekdays without trading between the first and the last given day,
+// eg: the times of the daily candles of an index
+func (c *Calendar) AddTradingDays(days ...time.Time) {
+	if len(days) == 0 {
+		return
+	}
+
+	first, last := days[0], days[0]
+	traded := make(map[string]bool, len(days))
+	for _, date := range days {
+		traded[date.In(c.Location).Format(time.DateOnly)] = true
+		if date.Before(first) {
+			first = date
+		}
+		if date.After(last) {
+			last = date
+		}
+	}
+
+	for date := c.midnight(first); !date.After(last); date = date.AddDate(0, 0, 1) {
+		if !c.isWeekend(date) && !traded[date.Format(time.DateOnly)] {
+			c.AddHolidays(date)
+		}
+	}
+}
+
+func (c *Calendar) isWeekend(t time.Time) bool {
+	weekday := t.In(c.Location).Weekday()
+	for _, closed := range c.Weekend {
+		if weekday == closed {
+			return true
+		}
+	}
+	return false
+}
+
+// IsHoliday returns true when the exchange is closed in a weekday
+func (c *Calendar) IsHoliday(t time.Time) bool {
+	return c.holidays[t.In(c.Location).Format(time.DateOnly)]
+}
+
+// IsTradingDay returns true when the exchange opens in the day of the given time
+func (c *Calendar) IsTradingDay(t time.Time) bool {
+	return !c.isWeekend(t) && !c.IsHoliday(t)
+}
+
+// IsOpen returns true when the time is inside a trading session
+func (c *Calendar) IsOpen(t time.Time) bool {
+	if !c.IsTradingDay(t) {
+		return false
+	}
+
+	offset := t.Sub(c.midnight(t))
+	for _, session := range c.Sessions {
+		if offset >= session.Open && offset < session.Close {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpen returns the time itself when the market is open or the beginning of the next session
+func (c *Calendar) NextOpen(t time.Time) time.Time {
+	if len(c.Sessions) == 0 || c.IsOpen(t) {
+		return t
+	}
+
+	// holidays lists are finite, the loop ends in the first trading day after them
+	for date := c.midnight(t); ; date = date.AddDate(0, 0, 1) {
+		if !c.IsTradingDay(date) {
+			continue
+		}
+
+		for _, session := range c.Sessions {
+			open := date.Add(session.Open)
+			if !open.Before(t) {
+				return open
+			}
+		}
+	}
+}
+
+// SessionMinutes returns the duration of a trading day in minutes
+func (c *Calendar) SessionMinutes() int {
+	var total time.Duration
+	for _, session := range c.Sessions {
+		total += session.Close - session.Open
+	}
+	return int(total / time.Minute)
+}
+
+// SessionMinute returns the trading minutes elapsed in the day before the given time
+func (c *Calendar) SessionMinute(t time.Time) int {
+	offset := t.Sub(c.midnight(t))
+
+	var elapsed time.Duration
+	for _, session := range c.Sessions {
+		if offset < session.Open {
+			break
+		}
+
+		if offset < session.Close {
+			elapsed += offset - session.Open
+			break
+		}
+		elapsed += session.Close - session.Open
+	}
+	return int(elapsed / time.Minute)
+}
+
+// Period returns an identifier of the period of the timeframe that contains the time.
+// Intraday periods are aligned to the sessions, eg: 1h A-share candles end at 10:30, 11:30, 14:00 and 15:00,
+// and weekly periods start on Monday.
+func (c *Calendar) Period(t time.Time, timeframe time.Duration) int64 {
+	local := t.In(c.Location)
+	date := int64(local.Year()*10000 + int(local.Month())*100 + local.Day())
+	switch {
+	case timeframe >= 7*day:
+		year, week := local.ISOWeek()
+		return int64(year*100 + week)
+	case timeframe >= day:
+		return date
+	default:
+		return date*10000 + int64(c.SessionMinute(t)/int(timeframe.Minutes()))
+	}
+}
+
+// IsPeriodStart returns true when the time is the first possible candle of a period of the timeframe
+func (c *Calendar) IsPeriodStart(t time.Time, timeframe time.Duration) bool {
+	if timeframe >= 7*day && t.In(c.Location).Weekday() != time.Monday {
+		return false
+	}
+
+	minutes := int(timeframe.Minutes())
+	if minutes > c.SessionMinutes() {
+		minutes = c.SessionMinutes()
+	}
+	return c.SessionMinute(t)%minutes == 0
+}
+
+// Count returns the number of candles of the timeframe in the interval [start, end)
+func (c *Calendar) Count(start, end time.Time, timeframe time.Duration) int {
+	var count int
+	var lastWeek int64
+	for date := c.midnight(start); date.Before(end); date = date.AddDate(0, 0, 1) {
+		if !c.IsTradingDay(date) {
+			continue
+		}
+
+		switch {
+		case timeframe >= 7*day:
+			if week := c.Period(date, timeframe); week != lastWeek {
+				lastWeek = week
+				count++
+			}
+		case timeframe >= day:
+			// the candle of the first day is before start when start is not a midnight
+			if !date.Before(start) {
+				count++
+			}
+		default:
+			from, to := 0, c.SessionMinutes()
+			if start.After(date) {
+				from = c.SessionMinute(start)
+			}
+			if next := date.AddDate(0, 0, 1); end.Before(next) {
+				to = c.SessionMinute(end)
+			}
+
+			if minutes := int(timeframe.Minutes()); to > from {
+				count += (to-1)/minutes - from/minutes + 1
+			}
+		}
+	}
+	return count
+}
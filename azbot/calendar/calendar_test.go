@@ -0,0 +1,116 @@
+package calendar
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func at(day, hour, minute int) time.Time {
+	return time.Date(2024, 1, day, hour, minute, 0, 0, Shanghai)
+}
+
+func TestCalendar_IsOpen(t *testing.T) {
+	calendar := AShare()
+	calendar.AddHolidays(at(1, 0, 0))
+
+	require.True(t, calendar.IsOpen(at(2, 9, 30)))
+	require.False(t, calendar.IsOpen(at(2, 9, 29)))
+	require.False(t, calendar.IsOpen(at(2, 12, 0)))
+	require.True(t, calendar.IsOpen(at(2, 14, 59)))
+	require.False(t, calendar.IsOpen(at(2, 15, 0)))
+	require.False(t, calendar.IsOpen(at(6, 10, 0)), "saturday")
+	require.False(t, calendar.IsOpen(at(1, 10, 0)), "holiday")
+	require.True(t, calendar.IsOpen(at(2, 10, 0).UTC()))
+
+	require.True(t, Crypto().IsOpen(at(6, 3, 0)))
+}
+
+func TestCalendar_NextOpen(t *testing.T) {
+	calendar := AShare()
+	calendar.AddHolidays(at(8, 0, 0))
+
+	require.Equal(t, at(2, 10, 0), calendar.NextOpen(at(2, 10, 0)))
+	require.Equal(t, at(2, 13, 0), calendar.NextOpen(at(2, 12, 59)))
+	require.Equal(t, at(2, 9, 30), calendar.NextOpen(at(2, 9, 25)))
+	require.Equal(t, at(9, 9, 30), calendar.NextOpen(at(5, 15, 0)))
+}
+
+func TestCalendar_Period(t *testing.T) {
+	calendar := AShare()
+	require.Equal(t, 240, calendar.SessionMinutes())
+	require.Equal(t, 0, calendar.SessionMinute(at(2, 9, 0)))
+	require.Equal(t, 120, calendar.SessionMinute(at(2, 12, 0)))
+	require.Equal(t, 150, calendar.SessionMinute(at(2, 13, 30)))
+	require.Equal(t, 240, calendar.SessionMinute(at(2, 16, 0)))
+
+	hour := time.Hour
+	require.Equal(t, calendar.Period(at(2, 9, 30), hour), calendar.Period(at(2, 10, 29), hour))
+	require.NotEqual(t, calendar.Period(at(2, 10, 29), hour), calendar.Period(at(2, 10, 30), hour))
+	require.Equal(t, calendar.Period(at(2, 11, 29), hour), calendar.Period(at(2, 10, 30), hour))
+	require.NotEqual(t, calendar.Period(at(2, 11, 29), hour), calendar.Period(at(2, 13, 0), hour))
+	require.Equal(t, calendar.Period(at(2, 9, 30), 24*hour), calendar.Period(at(2, 14, 0), 24*hour))
+	require.Equal(t, calendar.Period(at(1, 9, 30), 7*24*hour), calendar.Period(at(5, 14, 0), 7*24*hour))
+
+	require.True(t, calendar.IsPeriodStart(at(2, 13, 0), 2*hour))
+	require.False(t, calendar.IsPeriodStart(at(2, 13, 30), hour))
+	require.True(t, calendar.IsPeriodStart(at(2, 9, 30), 24*hour))
+	require.False(t, calendar.IsPeriodStart(at(2, 9, 30), 7*24*hour))
+}
+
+func TestCalendar_Count(t *testing.T) {
+	calendar := AShare()
+	calendar.AddHolidays(at(1, 0, 0))
+
+	tt := []struct {
+		name      string
+		start     time.Time
+		end       time.Time
+		timeframe time.Duration
+		count     int
+	}{
+		{"one day of 1h candles", at(2, 0, 0), at(3, 0, 0), time.Hour, 4},
+		{"one day of 1m candles", at(2, 0, 0), at(3, 0, 0), time.Minute, 240},
+		{"partial day", at(2, 10, 30), at(2, 14, 0), 30 * time.Minute, 4},
+		{"daily candles without weekend and holidays", at(1, 0, 0), at(15, 0, 0), 24 * time.Hour, 9},
+		{"daily candles from the middle of a day", at(2, 8, 0), at(15, 0, 0), 24 * time.Hour, 8},
+		{"weekly candles", at(1, 0, 0), at(15, 0, 0), 7 * 24 * time.Hour, 2},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.count, calendar.Count(tc.start, tc.end, tc.timeframe))
+		})
+	}
+
+	require.Equal(t, 48, Crypto().Count(at(6, 8, 0), at(8, 8, 0), time.Hour))
+}
+
+func TestCalendar_Holidays(t *testing.T) {
+	t.Run("from file", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "holidays.txt")
+		require.NoError(t, os.WriteFile(file, []byte("# new year\n2024-01-01\n\n2024-02-09\n"), 0644))
+
+		calendar := AShare()
+		require.NoError(t, calendar.LoadHolidays(file))
+		require.True(t, calendar.IsHoliday(at(1, 10, 0)))
+		require.False(t, calendar.IsTradingDay(time.Date(2024, 2, 9, 10, 0, 0, 0, Shanghai)))
+		require.True(t, calendar.IsTradingDay(at(2, 10, 0)))
+
+		require.NoError(t, os.WriteFile(file, []byte("01/01/2024\n"), 0644))
+		require.Error(t, calendar.LoadHolidays(file))
+	})
+
+	t.Run("from trading days", func(t *testing.T) {
+		calendar := AShare()
+		calendar.AddTradingDays(at(10, 0, 0), at(2, 0, 0), at(3, 0, 0), at(5, 0, 0), at(8, 0, 0))
+		require.True(t, calendar.IsHoliday(at(4, 0, 0)))
+		require.True(t, calendar.IsHoliday(at(9, 0, 0)))
+		require.False(t, calendar.IsHoliday(at(6, 0, 0)))
+		require.False(t, calendar.IsHoliday(at(1, 0, 0)))
+		require.False(t, calendar.IsHoliday(at(11, 0, 0)))
+	})
+}
@@ -11,7 +11,6 @@ import (
 	"github.com/ezquant/azbot/azbot/service"
 	"github.com/ezquant/azbot/examples/backtesting"
 	"github.com/urfave/cli/v2"
-	"gopkg.in/yaml.v3"
 )
 
 func main() {
@@ -70,6 +69,10 @@ func main() {
 						Value:    false,
 						Required: false,
 					},
+					&cli.StringFlag{
+						Name:  "calendar",
+						Usage: "count only the candles of trading sessions: crypto or ashare",
+					},
 				},
 				Action: func(c *cli.Context) error {
 					var (
@@ -77,6 +80,11 @@ func main() {
 						err error
 					)
 
+					cal, err := parseCalendar(c.String("calendar"))
+					if err != nil {
+						return err
+					}
+
 					if c.Bool("futures") {
 						// fetch data from binance futures
 						exc, err = exchange.NewBinanceFuture(c.Context)
@@ -96,6 +104,10 @@ func main() {
 						options = append(options, download.WithDays(days))
 					}
 
+					if cal != nil {
+						options = append(options, download.WithCalendar(cal))
+					}
+
 					start := c.Timestamp("start")
 					end := c.Timestamp("end")
 					if start != nil && end != nil && !start.IsZero() && !end.IsZero() {
@@ -124,7 +136,7 @@ func main() {
 				Action: func(c *cli.Context) error {
 					// 调用 backtesting.go 中的主逻辑
 					// bug修复：将 c.String("config") 的返回值转换为 *string 类型
-					config, err := ReadConfig(c.String("config"))
+					config, err := ReadConfig(c.String("config"), models.ModeBacktest)
 					if err != nil {
 						log.Fatalf("cannot read config file: %v", err)
 					}
@@ -146,6 +158,13 @@ func main() {
 					return nil
 				},
 			},
+			optimizeCommand(),
+			studyCommand(),
+			strategiesCommand(),
+			configCommand(),
+			dataCommand(),
+			liveCommand(),
+			paperCommand(),
 		},
 	}
 
@@ -155,14 +174,15 @@ func main() {
 	}
 }
 
-func ReadConfig(path string) (config *models.Config, err error) {
-	data, err := os.ReadFile(path)
+// ReadConfig loads the config file, interpolating ${ENV} variables, and validates it for the mode
+func ReadConfig(path string, mode models.Mode) (*models.Config, error) {
+	config, err := models.LoadConfig(path)
 	if err != nil {
 		return nil, err
 	}
 
-	config = &models.Config{}
-	err = yaml.Unmarshal(data, config)
-
-	return
+	if err := config.ValidateMode(mode); err != nil {
+		return nil, err
+	}
+	return config, nil
 }
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/ezquant/azbot/azbot/plus/models"
+	"github.com/ezquant/azbot/azbot/strategy"
+)
+
+func configCommand() *cli.Command {
+	return &cli.Command{
+		Name:     "config",
+		HelpName: "config",
+		Usage:    "Create and validate config files",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "validate",
+				Usage: "Check a config file for a mode, including the parameters of the strategy",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "config",
+						Aliases:  []string{"c"},
+						Usage:    "eg. ./user_data/config_CrossEMA.yml",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:    "mode",
+						Aliases: []string{"m"},
+						Usage:   "backtest, paper or live",
+						Value:   string(models.ModeBacktest),
+					},
+				},
+				Action: func(c *cli.Context) error {
+					config, err := models.LoadConfig(c.String("config"))
+					if err != nil {
+						return err
+					}
+
+					err = errors.Join(
+						config.ValidateMode(models.Mode(c.String("mode"))),
+						strategy.ValidateParameters(config),
+					)
+					if err != nil {
+						return fmt.Errorf("%s:\n%w", c.String("config"), err)
+					}
+
+					fmt.Printf("%s: valid %s config for %s\n", c.String("config"), c.String("mode"), config.Strategy)
+					return nil
+				},
+			},
+			{
+				Name:  "init",
+				Usage: "Create a config file with the default parameters of a strategy",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "strategy",
+						Aliases:  []string{"s"},
+						Usage:    "eg. CrossEMA, see `azbot strategies list`",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "eg. ./user_data/config_CrossEMA.yml (default ./user_data/config_<strategy>.yml)",
+					},
+					&cli.BoolFlag{
+						Name:    "force",
+						Aliases: []string{"f"},
+						Usage:   "overwrite an existing file",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					name := c.String("strategy")
+					parameters, err := strategy.Parameters(name)
+					if err != nil {
+						return err
+					}
+
+					output := c.String("output")
+					if output == "" {
+						output = fmt.Sprintf("./user_data/config_%s.yml", name)
+					}
+					if _, err := os.Stat(output); err == nil && !c.Bool("force") {
+						return fmt.Errorf("%s already exists, use --force to overwrite it", output)
+					}
+
+					if err := models.DefaultConfig(name, parameters).Save(output); err != nil {
+						return err
+					}
+					fmt.Printf("config for %s written to %s\n", name, output)
+					return nil
+				},
+			},
+		},
+	}
+}
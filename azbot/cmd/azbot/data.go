@@ -0,0 +1,332 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/ezquant/azbot/azbot/calendar"
+	"github.com/ezquant/azbot/azbot/history"
+)
+
+// seriesFlags are the options to read the input files of the data commands
+func seriesFlags(flags ...cli.Flag) []cli.Flag {
+	return append([]cli.Flag{
+		&cli.StringFlag{
+			Name:    "pair",
+			Aliases: []string{"p"},
+			Usage:   "eg. BTCUSDT (default the name of CSV files)",
+		},
+		&cli.StringFlag{
+			Name:    "timeframe",
+			Aliases: []string{"t"},
+			Usage:   "timeframe of CSV files, eg. 1h (default detected from the candles)",
+		},
+		&cli.StringFlag{
+			Name:  "calendar",
+			Usage: "trading sessions of the candles: crypto or ashare (default ashare for TDX files)",
+		},
+		&cli.StringFlag{
+			Name:  "holidays",
+			Usage: "file with a holiday of the calendar per line, eg. ./user_data/holidays.txt",
+		},
+	}, flags...)
+}
+
+// parseCalendar returns the calendar of a name, crypto or ashare, and nil for an empty name
+func parseCalendar(name string) (*calendar.Calendar, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case "crypto":
+		return calendar.Crypto(), nil
+	case "ashare":
+		return calendar.AShare(), nil
+	}
+	return nil, fmt.Errorf("invalid calendar %s, expected crypto or ashare", name)
+}
+
+// loadSeries reads the files given as arguments, at least one is required
+func loadSeries(c *cli.Context) ([]*history.Series, error) {
+	if c.NArg() == 0 {
+		return nil, errors.New("missing data file, eg. ./user_data/btc-1h.csv")
+	}
+
+	var options []history.Option
+	if pair := c.String("pair"); pair != "" {
+		options = append(options, history.WithPair(pair))
+	}
+	if timeframe := c.String("timeframe"); timeframe != "" {
+		options = append(options, history.WithTimeframe(timeframe))
+	}
+
+	cal, err := parseCalendar(c.String("calendar"))
+	if err != nil {
+		return nil, err
+	}
+
+	if holidays := c.String("holidays"); holidays != "" {
+		if cal == nil {
+			cal = calendar.AShare()
+		}
+		if err := cal.LoadHolidays(holidays); err != nil {
+			return nil, err
+		}
+	}
+	if cal != nil {
+		options = append(options, history.WithCalendar(cal))
+	}
+
+	series := make([]*history.Series, 0, c.NArg())
+	for _, file := range c.Args().Slice() {
+		s, err := history.Load(file, options...)
+		if err != nil {
+			return nil, err
+		}
+		series = append(series, s)
+	}
+	return series, nil
+}
+
+// loadFile reads the single file of the commands that transform a file
+func loadFile(c *cli.Context) (*history.Series, error) {
+	if c.NArg() > 1 {
+		return nil, fmt.Errorf("expected a single data file, got %d", c.NArg())
+	}
+
+	series, err := loadSeries(c)
+	if err != nil {
+		return nil, err
+	}
+	return series[0], nil
+}
+
+var outputFlag = &cli.StringFlag{
+	Name:     "output",
+	Aliases:  []string{"o"},
+	Usage:    "eg. ./btc-4h.csv, the format is given by the extension: .csv, .day, .lc5, .5 or .lc1",
+	Required: true,
+}
+
+func saveSeries(series *history.Series, output string) error {
+	if err := series.Save(output); err != nil {
+		return err
+	}
+	fmt.Printf("%d candles of %s written to %s\n", len(series.Candles), series.Timeframe, output)
+	return nil
+}
+
+func dataCommand() *cli.Command {
+	var fillPolicies []string
+	for _, policy := range history.FillPolicies {
+		fillPolicies = append(fillPolicies, string(policy))
+	}
+
+	return &cli.Command{
+		Name:     "data",
+		HelpName: "data",
+		Usage:    "Inspect and repair historical data files (CSV or TDX)",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "info",
+				Usage:     "Show the range, the number of candles, the gaps and the duplicates of data files",
+				ArgsUsage: "FILE...",
+				Flags: seriesFlags(&cli.IntFlag{
+					Name:  "gaps",
+					Usage: "number of gaps listed, the largest first",
+					Value: 10,
+				}),
+				Action: func(c *cli.Context) error {
+					series, err := loadSeries(c)
+					if err != nil {
+						return err
+					}
+
+					for i, s := range series {
+						info, err := s.Info()
+						if err != nil {
+							return err
+						}
+						printInfo(c.Args().Get(i), info, c.Int("gaps"))
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "validate",
+				Usage:     "Check the candles for OHLC inconsistencies, zero volume, duplicates and unordered times",
+				ArgsUsage: "FILE...",
+				Flags: seriesFlags(
+					&cli.StringSliceFlag{
+						Name:  "ignore",
+						Usage: "kinds of issues ignored: invalid-value, ohlc, zero-volume, duplicate or unordered",
+					},
+					&cli.IntFlag{
+						Name:  "max",
+						Usage: "number of issues listed by file",
+						Value: 20,
+					},
+				),
+				Action: func(c *cli.Context) error {
+					series, err := loadSeries(c)
+					if err != nil {
+						return err
+					}
+
+					ignored := make(map[history.IssueKind]bool)
+					for _, kind := range c.StringSlice("ignore") {
+						ignored[history.IssueKind(kind)] = true
+					}
+
+					var invalid int
+					for i, s := range series {
+						var issues []history.Issue
+						for _, issue := range s.Validate() {
+							if !ignored[issue.Kind] {
+								issues = append(issues, issue)
+							}
+						}
+
+						if len(issues) == 0 {
+							fmt.Printf("%s: %d valid candles\n", c.Args().Get(i), len(s.Candles))
+							continue
+						}
+
+						invalid++
+						fmt.Printf("%s: %d issues in %d candles\n", c.Args().Get(i), len(issues), len(s.Candles))
+						for j, issue := range issues {
+							if j == c.Int("max") {
+								fmt.Printf("  ... %d more\n", len(issues)-j)
+								break
+							}
+							fmt.Printf("  %s\n", issue)
+						}
+					}
+
+					if invalid > 0 {
+						return fmt.Errorf("%d of %d files have issues", invalid, len(series))
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "fill",
+				Usage:     "Insert the missing candles of gaps, after sorting the candles and removing duplicates",
+				ArgsUsage: "FILE",
+				Flags: seriesFlags(
+					&cli.StringFlag{
+						Name:  "policy",
+						Usage: "prices of the inserted candles: " + strings.Join(fillPolicies, ", "),
+						Value: fillPolicies[0],
+					},
+					&cli.IntFlag{
+						Name:  "limit",
+						Usage: "fill only gaps up to this number of candles (default all gaps)",
+					},
+					outputFlag,
+				),
+				Action: func(c *cli.Context) error {
+					series, err := loadFile(c)
+					if err != nil {
+						return err
+					}
+
+					inserted, err := series.Fill(history.FillPolicy(c.String("policy")), c.Int("limit"))
+					if err != nil {
+						return err
+					}
+					fmt.Printf("%d candles inserted with the %s policy\n", inserted, c.String("policy"))
+					return saveSeries(series, c.String("output"))
+				},
+			},
+			{
+				Name:      "resample",
+				Usage:     "Aggregate the candles to a larger timeframe, as the backtest feeds do",
+				ArgsUsage: "FILE",
+				Flags: seriesFlags(
+					&cli.StringFlag{
+						Name:     "to",
+						Usage:    "eg. 4h",
+						Required: true,
+					},
+					outputFlag,
+				),
+				Action: func(c *cli.Context) error {
+					series, err := loadFile(c)
+					if err != nil {
+						return err
+					}
+
+					resampled, err := series.Resample(c.String("to"))
+					if err != nil {
+						return err
+					}
+					return saveSeries(resampled, c.String("output"))
+				},
+			},
+			{
+				Name:      "merge",
+				Usage:     "Concatenate files of the same timeframe, overlapping candles are taken from the last file",
+				ArgsUsage: "FILE...",
+				Flags:     seriesFlags(outputFlag),
+				Action: func(c *cli.Context) error {
+					series, err := loadSeries(c)
+					if err != nil {
+						return err
+					}
+
+					merged, overlaps, err := history.Merge(series...)
+					if err != nil {
+						return err
+					}
+					fmt.Printf("%d files merged, %d overlapping candles replaced\n", len(series), overlaps)
+					return saveSeries(merged, c.String("output"))
+				},
+			},
+			{
+				Name:      "convert",
+				Usage:     "Translate a file between CSV and TDX formats (.day, .lc5, .5 or .lc1)",
+				ArgsUsage: "FILE",
+				Flags:     seriesFlags(outputFlag),
+				Action: func(c *cli.Context) error {
+					series, err := loadFile(c)
+					if err != nil {
+						return err
+					}
+					return saveSeries(series, c.String("output"))
+				},
+			},
+		},
+	}
+}
+
+func printInfo(file string, info history.Info, gaps int) {
+	const layout = "2006-01-02 15:04"
+	fmt.Printf("%s\n", file)
+	fmt.Printf("  pair:       %s\n", info.Pair)
+	fmt.Printf("  timeframe:  %s\n", info.Timeframe)
+	if info.Count > 0 {
+		fmt.Printf("  range:      %s - %s UTC\n", info.Start.UTC().Format(layout), info.End.UTC().Format(layout))
+	}
+	fmt.Printf("  candles:    %d\n", info.Count)
+	fmt.Printf("  duplicates: %d\n", info.Duplicates)
+	fmt.Printf("  unordered:  %d\n", info.Unordered)
+	fmt.Printf("  gaps:       %d (%d missing candles)\n", len(info.Gaps), info.Missing)
+
+	largest := append([]history.Gap(nil), info.Gaps...)
+	sort.SliceStable(largest, func(i, j int) bool {
+		return largest[i].Missing > largest[j].Missing
+	})
+	for i, gap := range largest {
+		if i == gaps {
+			fmt.Printf("    ... %d more\n", len(largest)-i)
+			break
+		}
+		fmt.Printf("    %s - %s: %d missing (%s)\n", gap.After.UTC().Format(layout), gap.Before.UTC().Format(layout),
+			gap.Missing, gap.Before.Sub(gap.After).Round(time.Minute))
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/glebarez/sqlite"
+	"github.com/urfave/cli/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/ezquant/azbot/azbot/optimize"
+	"github.com/ezquant/azbot/azbot/plus/models"
+	"github.com/ezquant/azbot/examples/optimizer"
+)
+
+const defaultStudyDatabase = "./user_data/study.db"
+
+var studyDatabaseFlag = &cli.StringFlag{
+	Name:  "db",
+	Usage: "study database (SQLite)",
+	Value: defaultStudyDatabase,
+}
+
+func optimizeCommand() *cli.Command {
+	return &cli.Command{
+		Name:     "optimize",
+		HelpName: "optimize",
+		Usage:    "Optimize the parameters of a strategy",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "config",
+				Aliases:  []string{"c"},
+				Usage:    "eg. ./user_data/config_CrossEMA.yml",
+				Required: true,
+			},
+			studyDatabaseFlag,
+			&cli.BoolFlag{
+				Name:  "resume",
+				Usage: "skip trials completed in the study database",
+			},
+			&cli.BoolFlag{
+				Name:  "reset",
+				Usage: "delete the trials of the study in the database, required to run again a study with trials",
+			},
+			&cli.IntFlag{
+				Name:    "workers",
+				Aliases: []string{"w"},
+				Usage:   "number of backtests run in parallel",
+				Value:   4,
+			},
+			&cli.StringFlag{
+				Name:    "method",
+				Aliases: []string{"m"},
+				Usage:   "grid, random, tpe or genetic (default from the config)",
+			},
+			&cli.StringFlag{
+				Name:  "objective",
+				Usage: strings.Join(models.Objectives, ", ") + " (default from the config, or sharpe)",
+			},
+			&cli.StringFlag{
+				Name:    "output",
+				Aliases: []string{"o"},
+				Usage:   "write all trials to a CSV or JSON file, eg. ./trials.csv",
+			},
+			&cli.StringFlag{
+				Name:  "report",
+				Usage: "write a HTML report with parameter heatmaps and equity curves, eg. ./report.html",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			config, err := ReadConfig(c.String("config"), models.ModeBacktest)
+			if err != nil {
+				log.Fatalf("cannot read config file: %v", err)
+			}
+
+			// the flags take precedence over the optimizer section of the config
+			if c.IsSet("method") {
+				config.Optimizer.Method = c.String("method")
+			}
+			if c.IsSet("objective") {
+				config.Optimizer.Objective = c.String("objective")
+			}
+			if err := config.Validate(); err != nil {
+				return err
+			}
+
+			databasePath := c.String("db")
+			optimizer.Run(config, &databasePath,
+				optimizer.WithResume(c.Bool("resume")),
+				optimizer.WithReset(c.Bool("reset")),
+				optimizer.WithWorkers(c.Int("workers")),
+				optimizer.WithTrialsOutput(c.String("output")),
+				optimizer.WithReport(c.String("report")),
+			)
+			return nil
+		},
+	}
+}
+
+func openStudy(c *cli.Context) (*optimize.Study, error) {
+	if _, err := os.Stat(c.String("db")); err != nil {
+		return nil, err
+	}
+	return optimize.OpenStudy(sqlite.Open(c.String("db")), c.String("study"), &gorm.Config{Logger: logger.Discard})
+}
+
+func studyCommand() *cli.Command {
+	studyFlag := &cli.StringFlag{
+		Name:     "study",
+		Aliases:  []string{"s"},
+		Usage:    "study name, eg. CrossEMA",
+		Required: true,
+	}
+
+	return &cli.Command{
+		Name:     "study",
+		HelpName: "study",
+		Usage:    "Query trials of optimization studies",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "list",
+				Usage: "List the studies in the database",
+				Flags: []cli.Flag{studyDatabaseFlag},
+				Action: func(c *cli.Context) error {
+					study, err := openStudy(c)
+					if err != nil {
+						return err
+					}
+
+					names, err := study.Studies()
+					if err != nil {
+						return err
+					}
+
+					for _, name := range names {
+						trials, err := study.WithName(name).Trials()
+						if err != nil {
+							return err
+						}
+						fmt.Printf("%s\t%d trials\n", name, len(trials))
+					}
+					return nil
+				},
+			},
+			{
+				Name:  "top",
+				Usage: "Show the trials with the highest score",
+				Flags: []cli.Flag{
+					studyDatabaseFlag,
+					studyFlag,
+					&cli.IntFlag{
+						Name:    "limit",
+						Aliases: []string{"n"},
+						Value:   10,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					study, err := openStudy(c)
+					if err != nil {
+						return err
+					}
+
+					trials, err := study.Top(c.Int("limit"))
+					if err != nil {
+						return err
+					}
+					return optimize.WriteCSV(os.Stdout, trials)
+				},
+			},
+			{
+				Name:  "export",
+				Usage: "Export all trials of a study to CSV",
+				Flags: []cli.Flag{
+					studyDatabaseFlag,
+					studyFlag,
+					&cli.StringFlag{
+						Name:     "output",
+						Aliases:  []string{"o"},
+						Usage:    "eg. ./trials.csv",
+						Required: true,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					study, err := openStudy(c)
+					if err != nil {
+						return err
+					}
+
+					trials, err := study.Trials()
+					if err != nil {
+						return err
+					}
+
+					file, err := os.Create(c.String("output"))
+					if err != nil {
+						return err
+					}
+					defer file.Close()
+
+					return optimize.WriteCSV(file, trials)
+				},
+			},
+		},
+	}
+}
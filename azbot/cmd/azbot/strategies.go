@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/ezquant/azbot/azbot/strategy"
+
+	// register the example strategies, eg: CrossEMA and RLPPO
+	_ "github.com/ezquant/azbot/examples/strategies"
+)
+
+func strategiesCommand() *cli.Command {
+	return &cli.Command{
+		Name:     "strategies",
+		HelpName: "strategies",
+		Usage:    "Registered strategies, available to the config files",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "list",
+				Usage: "List the names of the registered strategies",
+				Action: func(c *cli.Context) error {
+					for _, name := range strategy.Names() {
+						fmt.Println(name)
+					}
+					return nil
+				},
+			},
+		},
+	}
+}
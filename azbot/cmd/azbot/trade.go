@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"syscall"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/ezquant/azbot/azbot"
+	"github.com/ezquant/azbot/azbot/exchange"
+	"github.com/ezquant/azbot/azbot/plus/localkv"
+	"github.com/ezquant/azbot/azbot/plus/models"
+	"github.com/ezquant/azbot/azbot/service"
+	"github.com/ezquant/azbot/azbot/storage"
+	"github.com/ezquant/azbot/azbot/strategy"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func liveCommand() *cli.Command {
+	return tradeCommand(models.ModeLive, "Run a strategy with a real Binance account")
+}
+
+func paperCommand() *cli.Command {
+	return tradeCommand(models.ModePaper, "Run a strategy with live data and a simulated wallet (dry run)")
+}
+
+func tradeCommand(mode models.Mode, usage string) *cli.Command {
+	return &cli.Command{
+		Name:     string(mode),
+		HelpName: string(mode),
+		Usage:    usage,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "config",
+				Aliases:  []string{"c"},
+				Usage:    "eg. ./user_data/config_CrossEMA.yml",
+				Required: true,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			config, err := ReadConfig(c.String("config"), mode)
+			if err != nil {
+				return err
+			}
+
+			// stop the bot gracefully on Ctrl+C or `kill`
+			ctx, stop := signal.NotifyContext(c.Context, os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			return runBot(ctx, config, mode)
+		},
+	}
+}
+
+// runBot builds the exchange, storage, notifiers and strategy from the config and runs the bot until
+// ctx is canceled, then prints the summary of the trades
+func runBot(ctx context.Context, config *models.Config, mode models.Mode) error {
+	var options []azbot.Option
+	if config.Log.Level != "" {
+		level, err := log.ParseLevel(config.Log.Level)
+		if err != nil {
+			return err
+		}
+		options = append(options, azbot.WithLogLevel(level))
+	}
+
+	pairs := make([]string, 0, len(config.AssetWeights))
+	for pair := range config.AssetWeights {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+
+	settings := azbot.Settings{
+		Pairs: pairs,
+		Telegram: azbot.TelegramSettings{
+			Enabled: config.Telegram.Enabled,
+			Token:   config.Telegram.Token,
+			Users:   config.Telegram.Users,
+		},
+	}
+
+	if config.Exchange.Name == "" {
+		config.Exchange.Name = models.ExchangeBinance
+	}
+	exch, err := newExchange(ctx, config.Exchange, pairs, mode == models.ModeLive)
+	if err != nil {
+		return fmt.Errorf("cannot connect to %s: %w", config.Exchange.Name, err)
+	}
+
+	if mode == models.ModePaper {
+		quote := config.Paper.Quote
+		if quote == "" {
+			quote = "USDT"
+		}
+		wallet := exchange.NewPaperWallet(
+			ctx,
+			quote,
+			exchange.WithPaperAsset(quote, config.Paper.InitialBalance),
+			exchange.WithPaperFee(config.Paper.Fee, config.Paper.Fee),
+			exchange.WithPaperSlippage(exchange.FixedSlippage{BPS: config.Paper.Slippage * 10000}),
+			exchange.WithDataFeed(exch),
+		)
+		exch = wallet
+		options = append(options, azbot.WithPaperWallet(wallet))
+	}
+
+	// orders and the state of the strategy are kept next to each other, in memory without a path
+	var (
+		orders       storage.Storage
+		databasePath *string
+	)
+	if config.Storage.Path == "" {
+		orders, err = storage.FromMemory()
+	} else {
+		dir := filepath.Dir(config.Storage.Path)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		databasePath = &dir
+		orders, err = storage.FromFile(config.Storage.Path)
+	}
+	if err != nil {
+		return err
+	}
+	options = append(options, azbot.WithStorage(orders))
+
+	kv, err := localkv.NewLocalKV(databasePath)
+	if err != nil {
+		return err
+	}
+	defer kv.Close()
+
+	str, err := strategy.New(config, kv)
+	if err != nil {
+		return err
+	}
+
+	bot, err := azbot.NewBot(ctx, settings, exch, str, options...)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("[SETUP] Running %s in %s mode, press Ctrl+C to stop", config.Strategy, mode)
+	if err := bot.Run(ctx); err != nil {
+		return err
+	}
+
+	bot.Summary()
+	return nil
+}
+
+// newExchange connects to the exchange of the config, the credentials are used only for live trading
+func newExchange(ctx context.Context, settings models.Exchange, pairs []string,
+	live bool) (service.Exchange, error) {
+
+	switch settings.Name {
+	case models.ExchangeBinance:
+		var options []exchange.BinanceOption
+		if live {
+			options = append(options, exchange.WithBinanceCredentials(settings.APIKey, settings.APISecret))
+		}
+		if settings.Testnet {
+			options = append(options, exchange.WithTestNet())
+		}
+		return exchange.NewBinance(ctx, options...)
+	case models.ExchangeBinanceFutures:
+		var options []exchange.BinanceFutureOption
+		if live {
+			options = append(options, exchange.WithBinanceFutureCredentials(settings.APIKey, settings.APISecret))
+
+			marginType := exchange.MarginTypeCrossed
+			if settings.MarginType == "isolated" {
+				marginType = exchange.MarginTypeIsolated
+			}
+			if settings.Leverage > 0 {
+				for _, pair := range pairs {
+					options = append(options, exchange.WithBinanceFutureLeverage(pair, settings.Leverage,
+						marginType))
+				}
+			}
+		}
+		if settings.Testnet {
+			options = append(options, exchange.WithBinanceFutureTestNet())
+		}
+		return exchange.NewBinanceFuture(ctx, options...)
+	}
+	return nil, fmt.Errorf("%w: unknown exchange %s", models.ErrInvalidConfig, settings.Name)
+}
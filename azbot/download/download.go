@@ -9,6 +9,7 @@ import (
 	"github.com/schollz/progressbar/v3"
 	"github.com/xhit/go-str2duration/v2"
 
+	"github.com/ezquant/azbot/azbot/calendar"
 	"github.com/ezquant/azbot/azbot/service"
 	"github.com/ezquant/azbot/azbot/tools/log"
 )
@@ -26,8 +27,9 @@ func NewDownloader(exchange service.Feeder) Downloader {
 }
 
 type Parameters struct {
-	Start time.Time
-	End   time.Time
+	Start    time.Time
+	End      time.Time
+	Calendar *calendar.Calendar
 }
 
 type Option func(*Parameters)
@@ -46,6 +48,13 @@ func WithDays(days int) Option {
 	}
 }
 
+// WithCalendar counts only candles in trading sessions, eg: calendar.AShare()
+func WithCalendar(cal *calendar.Calendar) Option {
+	return func(parameters *Parameters) {
+		parameters.Calendar = cal
+	}
+}
+
 func candlesCount(start, end time.Time, timeframe string) (int, time.Duration, error) {
 	totalDuration := end.Sub(start)
 	interval, err := str2duration.ParseDuration(timeframe)
@@ -86,6 +95,10 @@ func (d Downloader) Download(ctx context.Context, pair, timeframe string, output
 		return err
 	}
 	candlesCount++
+	if parameters.Calendar != nil {
+		// candles are requested up to the end, inclusive
+		candlesCount = parameters.Calendar.Count(parameters.Start, parameters.End.Add(time.Nanosecond), interval)
+	}
 
 	log.Infof("Downloading %d candles of %s for %s", candlesCount, timeframe, pair)
 	info := d.exchange.AssetsInfo(pair)
@@ -126,7 +139,11 @@ func (d Downloader) Download(ctx context.Context, pair, timeframe string, output
 
 		countCandles := len(candles)
 		if !isLastLoop {
-			lostData += batchSize - countCandles
+			expected := batchSize
+			if parameters.Calendar != nil {
+				expected = parameters.Calendar.Count(begin, end, interval)
+			}
+			lostData += expected - countCandles
 		}
 
 		if err = progressBar.Add(countCandles); err != nil {
@@ -2,11 +2,19 @@ package download
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+
+	"github.com/ezquant/azbot/azbot/calendar"
 	"github.com/ezquant/azbot/azbot/exchange"
+	"github.com/ezquant/azbot/azbot/model"
 	"github.com/ezquant/azbot/azbot/service"
 
 	"github.com/stretchr/testify/assert"
@@ -53,6 +61,86 @@ func TestDownloader_withInterval(t *testing.T) {
 	assert.Equal(t, startingParams[0], startingParams[1])
 }
 
+func TestDownloader_withCalendar(t *testing.T) {
+	cal := calendar.AShare()
+	parameters := Parameters{}
+	WithCalendar(cal)(&parameters)
+	assert.Equal(t, cal, parameters.Calendar)
+}
+
+// sessionFeeder returns daily candles of the trading days of the calendar, labeled at midnight
+type sessionFeeder struct {
+	service.Feeder
+	calendar *calendar.Calendar
+}
+
+func (f sessionFeeder) AssetsInfo(string) model.AssetInfo {
+	return model.AssetInfo{QuotePrecision: 2}
+}
+
+func (f sessionFeeder) CandlesByPeriod(_ context.Context, pair, _ string, start, end time.Time) (
+	[]model.Candle, error) {
+	local := start.In(f.calendar.Location)
+	date := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, f.calendar.Location)
+
+	var candles []model.Candle
+	for ; !date.After(end); date = date.AddDate(0, 0, 1) {
+		if date.Before(start) || !f.calendar.IsTradingDay(date) {
+			continue
+		}
+		candles = append(candles, model.Candle{
+			Pair: pair, Time: date, UpdatedAt: date, Open: 1, Close: 1, Low: 1, High: 1, Volume: 1, Complete: true,
+		})
+	}
+	return candles, nil
+}
+
+func TestDownloader_downloadCalendar(t *testing.T) {
+	cal := calendar.AShare()
+	downloader := NewDownloader(sessionFeeder{calendar: cal})
+
+	// more than a batch of days, the missing candles are counted in the complete batches
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	download := func(options ...Option) ([]*logrus.Entry, int) {
+		hook := logtest.NewGlobal()
+		defer logrus.StandardLogger().ReplaceHooks(make(logrus.LevelHooks))
+
+		output := filepath.Join(t.TempDir(), "sh600104.csv")
+		err := downloader.Download(context.Background(), "SH600104CNY", "1d", output,
+			append(options, WithInterval(start, end))...)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(output)
+		require.NoError(t, err)
+		return hook.AllEntries(), strings.Count(string(data), "\n") - 1
+	}
+
+	t.Run("trading sessions", func(t *testing.T) {
+		entries, rows := download(WithCalendar(cal))
+		require.Equal(t, fmt.Sprintf("Downloading %d candles of 1d for SH600104CNY", rows), entries[0].Message)
+		for _, entry := range entries {
+			require.NotEqual(t, logrus.WarnLevel, entry.Level, entry.Message)
+		}
+	})
+
+	t.Run("continuous time", func(t *testing.T) {
+		entries, rows := download()
+		require.Equal(t, "Downloading 731 candles of 1d for SH600104CNY", entries[0].Message)
+		require.Less(t, rows, 731)
+
+		var warnings []string
+		for _, entry := range entries {
+			if entry.Level == logrus.WarnLevel {
+				warnings = append(warnings, entry.Message)
+			}
+		}
+		require.Len(t, warnings, 1, "closed days are missing candles")
+		require.Contains(t, warnings[0], "missing candles")
+	})
+}
+
 func TestDownloader_download(t *testing.T) {
 	ctx := context.Background()
 	tmpFile, err := os.CreateTemp(os.TempDir(), "*.csv")
@@ -316,6 +316,7 @@ func (b *Binance) CreateOrderMarket(side model.SideType, pair string, quantity f
 		Type:       model.OrderType(order.Type),
 		Status:     model.OrderStatusType(order.Status),
 		Price:      cost / quantity,
+		AvgPrice:   cost / quantity,
 		Quantity:   quantity,
 	}, nil
 }
@@ -356,6 +357,7 @@ func (b *Binance) CreateOrderMarketQuote(side model.SideType, pair string, quant
 		Type:       model.OrderType(order.Type),
 		Status:     model.OrderStatusType(order.Status),
 		Price:      cost / quantity,
+		AvgPrice:   cost / quantity,
 		Quantity:   quantity,
 	}, nil
 }
@@ -399,11 +401,13 @@ func (b *Binance) Order(pair string, id int64) (model.Order, error) {
 }
 
 func newOrder(order *binance.Order) model.Order {
-	var price float64
+	var price, avgPrice float64
 	cost, _ := strconv.ParseFloat(order.CummulativeQuoteQuantity, 64)
-	quantity, _ := strconv.ParseFloat(order.ExecutedQuantity, 64)
+	executed, _ := strconv.ParseFloat(order.ExecutedQuantity, 64)
+	quantity := executed
 	if cost > 0 && quantity > 0 {
 		price = cost / quantity
+		avgPrice = price
 	} else {
 		price, _ = strconv.ParseFloat(order.Price, 64)
 		quantity, _ = strconv.ParseFloat(order.OrigQuantity, 64)
@@ -418,7 +422,10 @@ func newOrder(order *binance.Order) model.Order {
 		Type:       model.OrderType(order.Type),
 		Status:     model.OrderStatusType(order.Status),
 		Price:      price,
+		AvgPrice:   avgPrice,
 		Quantity:   quantity,
+
+		ExecutedQuantity: executed,
 	}
 }
 
@@ -474,7 +481,7 @@ func (b *Binance) CandlesSubscription(ctx context.Context, pair, period string)
 		}
 
 		for {
-			done, _, err := binance.WsKlineServe(pair, period, func(event *binance.WsKlineEvent) {
+			done, stop, err := binance.WsKlineServe(pair, period, func(event *binance.WsKlineEvent) {
 				ba.Reset()
 				candle := CandleFromWsKline(pair, event.Kline)
 
@@ -490,10 +497,17 @@ func (b *Binance) CandlesSubscription(ctx context.Context, pair, period string)
 					}
 				}
 
-				ccandle <- candle
+				// stop sending when the subscription is canceled, so the websocket can be closed
+				select {
+				case ccandle <- candle:
+				case <-ctx.Done():
+				}
 
 			}, func(err error) {
-				cerr <- err
+				select {
+				case cerr <- err:
+				case <-ctx.Done():
+				}
 			})
 			if err != nil {
 				cerr <- err
@@ -504,6 +518,8 @@ func (b *Binance) CandlesSubscription(ctx context.Context, pair, period string)
 
 			select {
 			case <-ctx.Done():
+				close(stop)
+				<-done
 				close(cerr)
 				close(ccandle)
 				return
@@ -62,6 +62,14 @@ func WithBinanceFutureCredentials(key, secret string) BinanceFutureOption {
 	}
 }
 
+// WithBinanceFutureTestNet will use the testnet of Binance Futures
+func WithBinanceFutureTestNet() BinanceFutureOption {
+	return func(b *BinanceFuture) {
+		b.Testnet = true
+		futures.UseTestnet = true
+	}
+}
+
 // WithBinanceFutureLeverage will set the leverage for a pair
 func WithBinanceFutureLeverage(pair string, leverage int, marginType MarginType) BinanceFutureOption {
 	return func(b *BinanceFuture) {
@@ -299,6 +307,7 @@ func (b *BinanceFuture) CreateOrderMarket(side model.SideType, pair string, quan
 		Type:       model.OrderType(order.Type),
 		Status:     model.OrderStatusType(order.Status),
 		Price:      cost / quantity,
+		AvgPrice:   cost / quantity,
 		Quantity:   quantity,
 	}, nil
 }
@@ -347,13 +356,16 @@ func (b *BinanceFuture) Order(pair string, id int64) (model.Order, error) {
 
 func newFutureOrder(order *futures.Order) model.Order {
 	var (
-		price float64
-		err   error
+		price    float64
+		avgPrice float64
+		err      error
 	)
 	cost, _ := strconv.ParseFloat(order.CumQuote, 64)
-	quantity, _ := strconv.ParseFloat(order.ExecutedQuantity, 64)
+	executed, _ := strconv.ParseFloat(order.ExecutedQuantity, 64)
+	quantity := executed
 	if cost > 0 && quantity > 0 {
 		price = cost / quantity
+		avgPrice = price
 	} else {
 		price, err = strconv.ParseFloat(order.Price, 64)
 		log.CheckErr(log.WarnLevel, err)
@@ -370,7 +382,10 @@ func newFutureOrder(order *futures.Order) model.Order {
 		Type:       model.OrderType(order.Type),
 		Status:     model.OrderStatusType(order.Status),
 		Price:      price,
+		AvgPrice:   avgPrice,
 		Quantity:   quantity,
+
+		ExecutedQuantity: executed,
 	}
 }
 
@@ -454,7 +469,7 @@ func (b *BinanceFuture) CandlesSubscription(ctx context.Context, pair, period st
 		}
 
 		for {
-			done, _, err := futures.WsKlineServe(pair, period, func(event *futures.WsKlineEvent) {
+			done, stop, err := futures.WsKlineServe(pair, period, func(event *futures.WsKlineEvent) {
 				ba.Reset()
 				candle := FutureCandleFromWsKline(pair, event.Kline)
 
@@ -470,10 +485,17 @@ func (b *BinanceFuture) CandlesSubscription(ctx context.Context, pair, period st
 					}
 				}
 
-				ccandle <- candle
+				// stop sending when the subscription is canceled, so the websocket can be closed
+				select {
+				case ccandle <- candle:
+				case <-ctx.Done():
+				}
 
 			}, func(err error) {
-				cerr <- err
+				select {
+				case cerr <- err:
+				case <-ctx.Done():
+				}
 			})
 			if err != nil {
 				cerr <- err
@@ -484,6 +506,8 @@ func (b *BinanceFuture) CandlesSubscription(ctx context.Context, pair, period st
 
 			select {
 			case <-ctx.Done():
+				close(stop)
+				<-done
 				close(cerr)
 				close(ccandle)
 				return
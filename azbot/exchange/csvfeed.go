@@ -7,12 +7,14 @@ import (
 	"fmt"
 	"math"
 	"os"
+	"sort"
 	"strconv"
 	"time"
 
 	"github.com/samber/lo"
 	"github.com/xhit/go-str2duration/v2"
 
+	"github.com/ezquant/azbot/azbot/calendar"
 	"github.com/ezquant/azbot/azbot/model"
 )
 
@@ -28,8 +30,13 @@ type PairFeed struct {
 type CSVFeed struct {
 	Feeds               map[string]PairFeed
 	CandlePairTimeFrame map[string][]model.Candle
+	// Calendar aggregates candles by trading sessions, time is continuous when nil
+	Calendar *calendar.Calendar
 }
 
+// additiveMetadata are metadata accumulated like the volume when candles are resampled
+var additiveMetadata = []string{"turnover"}
+
 func (c CSVFeed) AssetsInfo(pair string) model.AssetInfo {
 	asset, quote := SplitAssetQuote(pair)
 	return model.AssetInfo{
@@ -66,97 +73,134 @@ func parseHeaders(headers []string) (index map[string]int, additional []string,
 
 // NewCSVFeed creates a new data feed from CSV files and resample
 func NewCSVFeed(targetTimeframe string, feeds ...PairFeed) (*CSVFeed, error) {
+	return NewCSVFeedWithCalendar(nil, targetTimeframe, feeds...)
+}
+
+// NewCSVFeedWithCalendar creates a new data feed from CSV files and resample by the sessions of the calendar
+func NewCSVFeedWithCalendar(cal *calendar.Calendar, targetTimeframe string, feeds ...PairFeed) (*CSVFeed, error) {
 	csvFeed := &CSVFeed{
 		Feeds:               make(map[string]PairFeed),
 		CandlePairTimeFrame: make(map[string][]model.Candle),
+		Calendar:            cal,
 	}
 
 	for _, feed := range feeds {
 		csvFeed.Feeds[feed.Pair] = feed
 
-		csvFile, err := os.Open(feed.File)
+		candles, err := ReadCSVCandles(feed)
 		if err != nil {
 			return nil, err
 		}
 
-		csvLines, err := csv.NewReader(csvFile).ReadAll()
+		csvFeed.CandlePairTimeFrame[csvFeed.feedTimeframeKey(feed.Pair, feed.Timeframe)] = candles
+
+		err = csvFeed.resample(feed.Pair, feed.Timeframe, targetTimeframe)
 		if err != nil {
 			return nil, err
 		}
+	}
 
-		var candles []model.Candle
-		ha := model.NewHeikinAshi()
-
-		// map each header label with its index
-		headerMap, additionalHeaders, hasCustomHeaders := parseHeaders(csvLines[0])
-		if hasCustomHeaders {
-			csvLines = csvLines[1:]
-		}
-
-		for _, line := range csvLines {
-			timestamp, err := strconv.Atoi(line[headerMap["time"]])
-			if err != nil {
-				return nil, err
-			}
+	return csvFeed, nil
+}
 
-			candle := model.Candle{
-				Time:      time.Unix(int64(timestamp), 0).UTC(),
-				UpdatedAt: time.Unix(int64(timestamp), 0).UTC(),
-				Pair:      feed.Pair,
-				Complete:  true,
-			}
+// ReadCSVCandles reads the candles of a CSV file in the format of the download command, with optional headers.
+// Additional columns are loaded as metadata, eg: turnover.
+func ReadCSVCandles(feed PairFeed) ([]model.Candle, error) {
+	csvFile, err := os.Open(feed.File)
+	if err != nil {
+		return nil, err
+	}
+	defer csvFile.Close()
 
-			candle.Open, err = strconv.ParseFloat(line[headerMap["open"]], 64)
-			if err != nil {
-				return nil, err
-			}
+	csvLines, err := csv.NewReader(csvFile).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(csvLines) == 0 {
+		return nil, fmt.Errorf("%w: %s is empty", ErrInsufficientData, feed.File)
+	}
 
-			candle.Close, err = strconv.ParseFloat(line[headerMap["close"]], 64)
-			if err != nil {
-				return nil, err
-			}
+	var candles []model.Candle
+	ha := model.NewHeikinAshi()
 
-			candle.Low, err = strconv.ParseFloat(line[headerMap["low"]], 64)
-			if err != nil {
-				return nil, err
-			}
+	// map each header label with its index
+	headerMap, additionalHeaders, hasCustomHeaders := parseHeaders(csvLines[0])
+	if hasCustomHeaders {
+		csvLines = csvLines[1:]
+	}
 
-			candle.High, err = strconv.ParseFloat(line[headerMap["high"]], 64)
-			if err != nil {
-				return nil, err
-			}
+	for _, line := range csvLines {
+		timestamp, err := strconv.Atoi(line[headerMap["time"]])
+		if err != nil {
+			return nil, err
+		}
 
-			candle.Volume, err = strconv.ParseFloat(line[headerMap["volume"]], 64)
-			if err != nil {
-				return nil, err
-			}
+		candle := model.Candle{
+			Time:      time.Unix(int64(timestamp), 0).UTC(),
+			UpdatedAt: time.Unix(int64(timestamp), 0).UTC(),
+			Pair:      feed.Pair,
+			Complete:  true,
+		}
 
-			if hasCustomHeaders {
-				candle.Metadata = make(map[string]float64)
-				for _, header := range additionalHeaders {
-					candle.Metadata[header], err = strconv.ParseFloat(line[headerMap[header]], 64)
-					if err != nil {
-						return nil, err
-					}
-				}
-			}
+		candle.Open, err = strconv.ParseFloat(line[headerMap["open"]], 64)
+		if err != nil {
+			return nil, err
+		}
 
-			if feed.HeikinAshi {
-				candle = candle.ToHeikinAshi(ha)
-			}
+		candle.Close, err = strconv.ParseFloat(line[headerMap["close"]], 64)
+		if err != nil {
+			return nil, err
+		}
 
-			candles = append(candles, candle)
+		candle.Low, err = strconv.ParseFloat(line[headerMap["low"]], 64)
+		if err != nil {
+			return nil, err
 		}
 
-		csvFeed.CandlePairTimeFrame[csvFeed.feedTimeframeKey(feed.Pair, feed.Timeframe)] = candles
+		candle.High, err = strconv.ParseFloat(line[headerMap["high"]], 64)
+		if err != nil {
+			return nil, err
+		}
 
-		err = csvFeed.resample(feed.Pair, feed.Timeframe, targetTimeframe)
+		candle.Volume, err = strconv.ParseFloat(line[headerMap["volume"]], 64)
 		if err != nil {
 			return nil, err
 		}
+
+		if hasCustomHeaders {
+			candle.Metadata = make(map[string]float64)
+			for _, header := range additionalHeaders {
+				candle.Metadata[header], err = strconv.ParseFloat(line[headerMap[header]], 64)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if feed.HeikinAshi {
+			candle = candle.ToHeikinAshi(ha)
+		}
+
+		candles = append(candles, candle)
 	}
 
-	return csvFeed, nil
+	return candles, nil
+}
+
+// AddTimeframes resamples the source data of all pairs to other timeframes, eg: for multi-timeframe strategies
+func (c *CSVFeed) AddTimeframes(timeframes ...string) error {
+	for pair, feed := range c.Feeds {
+		for _, timeframe := range timeframes {
+			if _, ok := c.CandlePairTimeFrame[c.feedTimeframeKey(pair, timeframe)]; ok {
+				continue
+			}
+
+			if err := c.resample(pair, feed.Timeframe, timeframe); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 func (c CSVFeed) feedTimeframeKey(pair, timeframe string) string {
@@ -177,6 +221,53 @@ func (c *CSVFeed) Limit(duration time.Duration) *CSVFeed {
 	return c
 }
 
+// Slice returns a copy of the feed with candles in the interval [start, end), a zero time is not limited.
+// Unlike Limit, the feed is not changed, eg: to backtest different periods of the same data.
+func (c *CSVFeed) Slice(start, end time.Time) *CSVFeed {
+	feed := &CSVFeed{
+		Feeds:               c.Feeds,
+		CandlePairTimeFrame: make(map[string][]model.Candle, len(c.CandlePairTimeFrame)),
+		Calendar:            c.Calendar,
+	}
+
+	for key, candles := range c.CandlePairTimeFrame {
+		first := 0
+		if !start.IsZero() {
+			first = sort.Search(len(candles), func(i int) bool {
+				return !candles[i].Time.Before(start)
+			})
+		}
+
+		last := len(candles)
+		if !end.IsZero() {
+			last = sort.Search(len(candles), func(i int) bool {
+				return !candles[i].Time.Before(end)
+			})
+		}
+
+		feed.CandlePairTimeFrame[key] = append([]model.Candle(nil), candles[first:max(first, last)]...)
+	}
+	return feed
+}
+
+// Range returns the time of the first and the last candle of all pairs and timeframes
+func (c CSVFeed) Range() (start, end time.Time) {
+	for _, candles := range c.CandlePairTimeFrame {
+		if len(candles) == 0 {
+			continue
+		}
+
+		if start.IsZero() || candles[0].Time.Before(start) {
+			start = candles[0].Time
+		}
+
+		if last := candles[len(candles)-1].Time; last.After(end) {
+			end = last
+		}
+	}
+	return start, end
+}
+
 func isFistCandlePeriod(t time.Time, fromTimeframe, targetTimeframe string) (bool, error) {
 	fromDuration, err := str2duration.ParseDuration(fromTimeframe)
 	if err != nil {
@@ -229,6 +320,10 @@ func isLastCandlePeriod(t time.Time, fromTimeframe, targetTimeframe string) (boo
 }
 
 func (c *CSVFeed) resample(pair, sourceTimeframe, targetTimeframe string) error {
+	if c.Calendar != nil {
+		return c.resampleSessions(pair, sourceTimeframe, targetTimeframe)
+	}
+
 	sourceKey := c.feedTimeframeKey(pair, sourceTimeframe)
 	targetKey := c.feedTimeframeKey(pair, targetTimeframe)
 
@@ -245,6 +340,13 @@ func (c *CSVFeed) resample(pair, sourceTimeframe, targetTimeframe string) error
 	candles := make([]model.Candle, 0)
 	for ; i < len(c.CandlePairTimeFrame[sourceKey]); i++ {
 		candle := c.CandlePairTimeFrame[sourceKey][i]
+		if len(candle.Metadata) > 0 {
+			candle.Metadata = make(map[string]float64, len(candle.Metadata))
+			for key, value := range c.CandlePairTimeFrame[sourceKey][i].Metadata {
+				candle.Metadata[key] = value
+			}
+		}
+
 		if last, err := isLastCandlePeriod(candle.Time, sourceTimeframe, targetTimeframe); err != nil {
 			return err
 		} else if last {
@@ -260,12 +362,20 @@ func (c *CSVFeed) resample(pair, sourceTimeframe, targetTimeframe string) error
 			candle.High = math.Max(candles[lastIndex].High, candle.High)
 			candle.Low = math.Min(candles[lastIndex].Low, candle.Low)
 			candle.Volume += candles[lastIndex].Volume
+			for _, key := range additiveMetadata {
+				if value, ok := candles[lastIndex].Metadata[key]; ok {
+					if candle.Metadata == nil {
+						candle.Metadata = make(map[string]float64)
+					}
+					candle.Metadata[key] += value
+				}
+			}
 		}
 		candles = append(candles, candle)
 	}
 
 	// remove last candle if not complete
-	if !candles[len(candles)-1].Complete {
+	if len(candles) > 0 && !candles[len(candles)-1].Complete {
 		candles = candles[:len(candles)-1]
 	}
 
@@ -274,14 +384,116 @@ func (c *CSVFeed) resample(pair, sourceTimeframe, targetTimeframe string) error
 	return nil
 }
 
+// resampleSessions aggregates candles by the sessions of the calendar, a candle is the last of its period
+// when the next candle belongs to another period or the session is closed after it
+func (c *CSVFeed) resampleSessions(pair, sourceTimeframe, targetTimeframe string) error {
+	if sourceTimeframe == targetTimeframe {
+		return nil
+	}
+
+	sourceDuration, err := str2duration.ParseDuration(sourceTimeframe)
+	if err != nil {
+		return err
+	}
+
+	target, err := str2duration.ParseDuration(targetTimeframe)
+	if err != nil {
+		return err
+	}
+
+	source := c.CandlePairTimeFrame[c.feedTimeframeKey(pair, sourceTimeframe)]
+	var i int
+	for ; i < len(source); i++ {
+		if i == 0 && c.Calendar.IsPeriodStart(source[i].Time, target) {
+			break
+		}
+
+		if i > 0 && c.Calendar.Period(source[i].Time, target) != c.Calendar.Period(source[i-1].Time, target) {
+			break
+		}
+	}
+
+	candles := make([]model.Candle, 0)
+	for ; i < len(source); i++ {
+		candle := source[i]
+		candle.Metadata = make(map[string]float64, len(source[i].Metadata))
+		for key, value := range source[i].Metadata {
+			candle.Metadata[key] = value
+		}
+
+		if i+1 < len(source) {
+			candle.Complete = c.Calendar.Period(candle.Time, target) != c.Calendar.Period(source[i+1].Time, target)
+		} else {
+			// last candle is complete only at the end of the trading day
+			end := c.Calendar.SessionMinute(candle.Time)+int(sourceDuration.Minutes()) >= c.Calendar.SessionMinutes()
+			candle.Complete = end && target < 7*24*time.Hour
+		}
+
+		lastIndex := len(candles) - 1
+		if lastIndex >= 0 && !candles[lastIndex].Complete {
+			candle.Time = candles[lastIndex].Time
+			candle.Open = candles[lastIndex].Open
+			candle.High = math.Max(candles[lastIndex].High, candle.High)
+			candle.Low = math.Min(candles[lastIndex].Low, candle.Low)
+			candle.Volume += candles[lastIndex].Volume
+			for _, key := range additiveMetadata {
+				if value, ok := candles[lastIndex].Metadata[key]; ok {
+					candle.Metadata[key] += value
+				}
+			}
+		}
+		candles = append(candles, candle)
+	}
+
+	// remove last candle if not complete
+	if len(candles) > 0 && !candles[len(candles)-1].Complete {
+		candles = candles[:len(candles)-1]
+	}
+
+	c.CandlePairTimeFrame[c.feedTimeframeKey(pair, targetTimeframe)] = candles
+
+	return nil
+}
+
+// Resample aggregates candles of a pair to a larger timeframe, by the sessions of the calendar when it is
+// not nil. Unlike the feed, that also streams the partial candles of each period, only complete candles
+// are returned.
+func Resample(cal *calendar.Calendar, candles []model.Candle, sourceTimeframe, targetTimeframe string) ([]model.Candle, error) {
+	if len(candles) == 0 {
+		return nil, nil
+	}
+
+	pair := candles[0].Pair
+	feed := &CSVFeed{
+		CandlePairTimeFrame: make(map[string][]model.Candle),
+		Calendar:            cal,
+	}
+	feed.CandlePairTimeFrame[feed.feedTimeframeKey(pair, sourceTimeframe)] = candles
+
+	if err := feed.resample(pair, sourceTimeframe, targetTimeframe); err != nil {
+		return nil, err
+	}
+	return lo.Filter(feed.CandlePairTimeFrame[feed.feedTimeframeKey(pair, targetTimeframe)],
+		func(candle model.Candle, _ int) bool {
+			return candle.Complete
+		}), nil
+}
+
 func (c CSVFeed) CandlesByPeriod(_ context.Context, pair, timeframe string,
 	start, end time.Time) ([]model.Candle, error) {
 
 	key := c.feedTimeframeKey(pair, timeframe)
+	data := c.CandlePairTimeFrame[key]
+
+	// candles are sorted by time, find the first candle of the period
+	first := sort.Search(len(data), func(i int) bool {
+		return !data[i].Time.Before(start)
+	})
+
 	candles := make([]model.Candle, 0)
-	for _, candle := range c.CandlePairTimeFrame[key] {
-		if candle.Time.Before(start) || candle.Time.After(end) {
-			continue
+	for _, candle := range data[first:] {
+		if candle.Time.After(end) {
+			break
 		}
 		candles = append(candles, candle)
 	}
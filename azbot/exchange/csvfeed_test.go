@@ -8,6 +8,9 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/ezquant/azbot/azbot/calendar"
+	"github.com/ezquant/azbot/azbot/model"
 )
 
 func TestNewCSVFeed(t *testing.T) {
@@ -67,6 +70,33 @@ func TestCSVFeed_CandlesByLimit(t *testing.T) {
 	require.Equal(t, "2021-04-27 00:00:00", candle.Time.UTC().Format("2006-01-02 15:04:05"))
 }
 
+func TestCSVFeed_Slice(t *testing.T) {
+	feed, err := NewCSVFeed("1d", PairFeed{
+		Timeframe: "1d",
+		Pair:      "BTCUSDT",
+		File:      "../../testdata/btc-1d.csv",
+	})
+	require.NoError(t, err)
+
+	start, end := feed.Range()
+	require.Equal(t, time.Date(2021, 4, 26, 0, 0, 0, 0, time.UTC), start)
+	require.Equal(t, time.Date(2021, 5, 9, 0, 0, 0, 0, time.UTC), end)
+
+	slice := feed.Slice(time.Date(2021, 4, 28, 0, 0, 0, 0, time.UTC), time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC))
+	candles := slice.CandlePairTimeFrame["BTCUSDT--1d"]
+	require.Len(t, candles, 3)
+	require.Equal(t, time.Date(2021, 4, 28, 0, 0, 0, 0, time.UTC), candles[0].Time)
+	require.Equal(t, time.Date(2021, 4, 30, 0, 0, 0, 0, time.UTC), candles[2].Time)
+	require.Len(t, feed.CandlePairTimeFrame["BTCUSDT--1d"], 14, "source feed is not changed")
+
+	// zero times are not limited
+	require.Len(t, feed.Slice(time.Time{}, time.Date(2021, 4, 28, 0, 0, 0, 0, time.UTC)).
+		CandlePairTimeFrame["BTCUSDT--1d"], 2)
+	require.Len(t, feed.Slice(time.Date(2021, 5, 8, 0, 0, 0, 0, time.UTC), time.Time{}).
+		CandlePairTimeFrame["BTCUSDT--1d"], 2)
+	require.Empty(t, feed.Slice(end, start).CandlePairTimeFrame["BTCUSDT--1d"])
+}
+
 func TestCSVFeed_resample(t *testing.T) {
 	t.Run("1h to 1d", func(t *testing.T) {
 		feed, err := NewCSVFeed(
@@ -215,3 +245,75 @@ func TestIsFistCandlePeriod(t *testing.T) {
 		require.False(t, last)
 	})
 }
+
+func TestCSVFeed_resampleSessions(t *testing.T) {
+	cal := calendar.AShare()
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, calendar.Shanghai)
+	var candles []model.Candle
+	for _, start := range []time.Duration{570, 600, 630, 660, 780, 810, 840, 870} {
+		candles = append(candles, model.Candle{
+			Pair:     "SH600104CNY",
+			Time:     day.Add(start * time.Minute).UTC(),
+			Open:     float64(start),
+			High:     float64(start),
+			Low:      float64(start),
+			Close:    float64(start),
+			Volume:   1,
+			Complete: true,
+			Metadata: map[string]float64{"turnover": 10},
+		})
+	}
+
+	feed := &CSVFeed{
+		Feeds:               map[string]PairFeed{},
+		CandlePairTimeFrame: map[string][]model.Candle{"SH600104CNY--30m": candles},
+		Calendar:            cal,
+	}
+	require.NoError(t, feed.resample("SH600104CNY", "30m", "1h"))
+
+	var starts []string
+	for _, candle := range feed.CandlePairTimeFrame["SH600104CNY--1h"] {
+		if !candle.Complete {
+			continue
+		}
+
+		starts = append(starts, candle.Time.In(calendar.Shanghai).Format("15:04"))
+		require.Equal(t, 2.0, candle.Volume)
+		require.Equal(t, 20.0, candle.Metadata["turnover"])
+		require.Equal(t, candle.Open+30, candle.Close)
+	}
+	require.Equal(t, []string{"09:30", "10:30", "13:00", "14:00"}, starts)
+	require.Equal(t, 10.0, candles[0].Metadata["turnover"])
+}
+
+func TestResample(t *testing.T) {
+	start := time.Date(2021, 5, 13, 0, 0, 0, 0, time.UTC)
+	var candles []model.Candle
+	for i := 0; i < 5; i++ {
+		candles = append(candles, model.Candle{
+			Pair:     "BTCUSDT",
+			Time:     start.Add(time.Duration(i) * 30 * time.Minute),
+			Open:     float64(i),
+			High:     float64(i + 1),
+			Low:      float64(i),
+			Close:    float64(i + 1),
+			Volume:   1,
+			Complete: true,
+			Metadata: map[string]float64{"turnover": 10},
+		})
+	}
+
+	resampled, err := Resample(nil, candles, "30m", "1h")
+	require.NoError(t, err)
+	require.Len(t, resampled, 2, "partial candles and the incomplete last period are dropped")
+	require.Equal(t, start.Add(time.Hour), resampled[1].Time)
+	require.Equal(t, 2.0, resampled[1].Open)
+	require.Equal(t, 4.0, resampled[1].Close)
+	require.Equal(t, 2.0, resampled[1].Volume)
+	require.Equal(t, 20.0, resampled[1].Metadata["turnover"])
+	require.Equal(t, 10.0, candles[1].Metadata["turnover"], "source candles are not changed")
+
+	resampled, err = Resample(nil, nil, "30m", "1h")
+	require.NoError(t, err)
+	require.Empty(t, resampled)
+}
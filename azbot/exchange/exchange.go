@@ -90,11 +90,12 @@ func (d *DataFeedSubscription) Preload(pair, timeframe string, candles []model.C
 	}
 }
 
-func (d *DataFeedSubscription) Connect() {
+// Connect subscribes to the candles of all feeds, the subscriptions are closed when ctx is canceled
+func (d *DataFeedSubscription) Connect(ctx context.Context) {
 	log.Infof("Connecting to the exchange.")
 	for feed := range d.Feeds.Iter() {
 		pair, timeframe := d.pairTimeframeFromKey(feed)
-		ccandle, cerr := d.exchange.CandlesSubscription(context.Background(), pair, timeframe)
+		ccandle, cerr := d.exchange.CandlesSubscription(ctx, pair, timeframe)
 		d.DataFeeds[feed] = &DataFeed{
 			Data: ccandle,
 			Err:  cerr,
@@ -102,12 +103,15 @@ func (d *DataFeedSubscription) Connect() {
 	}
 }
 
-func (d *DataFeedSubscription) Start(loadSync bool) {
-	d.Connect()
+// Start connects to the exchange and dispatches the candles to the subscribers until the feeds are closed,
+// eg: when ctx is canceled. With loadSync, it blocks until all feeds are consumed (backtesting)
+func (d *DataFeedSubscription) Start(ctx context.Context, loadSync bool) {
+	d.Connect(ctx)
 	wg := new(sync.WaitGroup)
 	for key, feed := range d.DataFeeds {
 		wg.Add(1)
 		go func(key string, feed *DataFeed) {
+			errs := feed.Err
 			for {
 				select {
 				case candle, ok := <-feed.Data:
@@ -121,7 +125,12 @@ func (d *DataFeedSubscription) Start(loadSync bool) {
 						}
 						subscription.consumer(candle)
 					}
-				case err := <-feed.Err:
+				case err, ok := <-errs:
+					if !ok {
+						// a closed channel is always ready, stop listening for errors
+						errs = nil
+						continue
+					}
 					if err != nil {
 						log.Error("dataFeedSubscription/start: ", err)
 					}
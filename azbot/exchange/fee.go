@@ -0,0 +1,69 @@
+package exchange
+
+import (
+	"math"
+
+	"github.com/ezquant/azbot/azbot/model"
+)
+
+// FeeModel calculates the commission, in quote asset, charged by the paper wallet for a fill.
+// Maker is true when the fill provided liquidity (limit orders resting in the book).
+type FeeModel interface {
+	Fee(order model.Order, price, quantity float64, maker bool) float64
+}
+
+// SlippageModel returns the execution price of an order that takes liquidity from the book
+type SlippageModel interface {
+	Price(side model.SideType, price, quantity float64, candle model.Candle) float64
+}
+
+// MakerTakerFee charges a percentage of the filled value, eg: 0.001 for 0.1%
+type MakerTakerFee struct {
+	Maker float64
+	Taker float64
+}
+
+func (f MakerTakerFee) Fee(_ model.Order, price, quantity float64, maker bool) float64 {
+	if maker {
+		return price * quantity * f.Maker
+	}
+	return price * quantity * f.Taker
+}
+
+// FixedSlippage moves the execution price against the order by a fixed amount of basis points
+type FixedSlippage struct {
+	BPS float64
+}
+
+func (s FixedSlippage) Price(side model.SideType, price, _ float64, _ model.Candle) float64 {
+	return slippedPrice(side, price, s.BPS)
+}
+
+// VolumeSlippage moves the execution price by a base amount of basis points plus a market impact
+// proportional to the order participation in the candle volume, limited by MaxBPS (when greater than zero).
+// eg: BPS = 5, Impact = 100 and an order of 10% of the candle volume results in 15 bps of slippage.
+type VolumeSlippage struct {
+	BPS    float64
+	Impact float64
+	MaxBPS float64
+}
+
+func (s VolumeSlippage) Price(side model.SideType, price, quantity float64, candle model.Candle) float64 {
+	bps := s.BPS
+	if candle.Volume > 0 {
+		bps += s.Impact * quantity / candle.Volume
+	}
+
+	if s.MaxBPS > 0 {
+		bps = math.Min(bps, s.MaxBPS)
+	}
+
+	return slippedPrice(side, price, bps)
+}
+
+func slippedPrice(side model.SideType, price, bps float64) float64 {
+	if side == model.SideTypeBuy {
+		return price * (1 + bps/10000)
+	}
+	return price * (1 - bps/10000)
+}
@@ -0,0 +1,31 @@
+package exchange
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ezquant/azbot/azbot/model"
+)
+
+func TestMakerTakerFee(t *testing.T) {
+	fee := MakerTakerFee{Maker: 0.001, Taker: 0.002}
+	require.InDelta(t, 0.1, fee.Fee(model.Order{}, 100, 1, true), 1e-9)
+	require.InDelta(t, 0.2, fee.Fee(model.Order{}, 100, 1, false), 1e-9)
+}
+
+func TestFixedSlippage(t *testing.T) {
+	slippage := FixedSlippage{BPS: 10}
+	require.InDelta(t, 100.1, slippage.Price(model.SideTypeBuy, 100, 1, model.Candle{}), 1e-9)
+	require.InDelta(t, 99.9, slippage.Price(model.SideTypeSell, 100, 1, model.Candle{}), 1e-9)
+}
+
+func TestVolumeSlippage(t *testing.T) {
+	slippage := VolumeSlippage{BPS: 5, Impact: 100}
+	candle := model.Candle{Volume: 10}
+	require.InDelta(t, 100.15, slippage.Price(model.SideTypeBuy, 100, 1, candle), 1e-9)
+	require.InDelta(t, 99.95, slippage.Price(model.SideTypeSell, 100, 1, model.Candle{}), 1e-9)
+
+	slippage.MaxBPS = 8
+	require.InDelta(t, 100.08, slippage.Price(model.SideTypeBuy, 100, 1, candle), 1e-9)
+}
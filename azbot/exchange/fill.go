@@ -0,0 +1,304 @@
+package exchange
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/xhit/go-str2duration/v2"
+
+	"github.com/ezquant/azbot/azbot/model"
+	"github.com/ezquant/azbot/azbot/service"
+	"github.com/ezquant/azbot/azbot/tools/log"
+)
+
+// FillPolicy defines how the paper wallet executes pending orders inside a candle
+type FillPolicy string
+
+const (
+	// FillPolicyClose fills buy orders when the close price reaches the limit and sell orders when
+	// high or low reach the target or stop, in creation order. It is the default policy.
+	FillPolicyClose FillPolicy = "close"
+	// FillPolicyPessimistic fills orders touched by high or low, when the target and the stop of the
+	// same group are touched in the same candle, the stop is executed.
+	FillPolicyPessimistic FillPolicy = "pessimistic"
+	// FillPolicyOptimistic fills orders touched by high or low, when the target and the stop of the
+	// same group are touched in the same candle, the target is executed.
+	FillPolicyOptimistic FillPolicy = "optimistic"
+	// FillPolicyOHLC assumes the price path open, low, high, close for bullish candles and
+	// open, high, low, close for bearish candles, orders are executed in the order they are touched.
+	FillPolicyOHLC FillPolicy = "ohlc"
+)
+
+// WithPaperFillPolicy sets the policy used to execute pending orders inside a candle
+func WithPaperFillPolicy(policy FillPolicy) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.fillPolicy = policy
+	}
+}
+
+type subCandleFeed struct {
+	feeder       service.Feeder
+	duration     time.Duration
+	subTimeframe string
+}
+
+// WithPaperSubCandles replays candles of a lower timeframe (eg: 1m) inside each candle of the given timeframe
+// to find out which pending order is executed first. The feeder must provide the lower timeframe for all
+// pairs, eg: a CSVFeed created with the same target timeframe. Candles are replayed only when there are
+// pending orders, if no lower timeframe data is found the fill policy is applied to the original candle.
+func WithPaperSubCandles(feeder service.Feeder, timeframe, subTimeframe string) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		duration, err := str2duration.ParseDuration(timeframe)
+		if err != nil {
+			log.Errorf("paperwallet: invalid timeframe %s: %v", timeframe, err)
+			return
+		}
+
+		wallet.subCandles = &subCandleFeed{
+			feeder:       feeder,
+			duration:     duration,
+			subTimeframe: subTimeframe,
+		}
+	}
+}
+
+// WithPaperLiquidity limits the quantity filled by pending orders (limit, stop and OCO) in each candle
+// to a fraction of the candle volume, eg: 0.1 for 10%. The remaining quantity stays partially filled and
+// is executed in the next candles. Market orders are filled at once, use VolumeSlippage to model their impact.
+func WithPaperLiquidity(ratio float64) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.liquidity = ratio
+	}
+}
+
+func isPending(order model.Order) bool {
+	return order.Status == model.OrderStatusTypeNew || order.Status == model.OrderStatusTypePartiallyFilled
+}
+
+func (p *PaperWallet) hasPendingOrders(pair string) bool {
+	for _, order := range p.orders {
+		if order.Pair == pair && isPending(order) {
+			return true
+		}
+	}
+	return false
+}
+
+// replaySubCandles executes pending orders using lower timeframe candles of the given candle period
+func (p *PaperWallet) replaySubCandles(candle model.Candle) {
+	end := candle.Time.Add(p.subCandles.duration - time.Nanosecond)
+	candles, err := p.subCandles.feeder.CandlesByPeriod(p.ctx, candle.Pair, p.subCandles.subTimeframe,
+		candle.Time, end)
+	if err != nil || len(candles) == 0 {
+		if err != nil {
+			log.Warnf("paperwallet: sub candles for %s: %v", candle.Pair, err)
+		}
+		p.fillOrders(candle)
+		return
+	}
+
+	for _, subCandle := range candles {
+		// partial candle, ignore sub candles after the last update
+		if !candle.Complete && subCandle.Time.After(candle.UpdatedAt) {
+			break
+		}
+
+		// keep the time of the strategy candle to preserve the order of events of the controller
+		subCandle.Pair = candle.Pair
+		subCandle.Time = candle.Time
+		p.fillOrders(subCandle)
+	}
+}
+
+func isStopOrder(order model.Order) bool {
+	return order.Type == model.OrderTypeStopLoss || order.Type == model.OrderTypeStopLossLimit
+}
+
+func isTakerOrder(order model.Order) bool {
+	return order.Type == model.OrderTypeStopLoss || order.Type == model.OrderTypeTakeProfit
+}
+
+// fillPrice returns the execution price of a pending order in a candle, or false if it is not executed
+func (p *PaperWallet) fillPrice(order model.Order, candle model.Candle) (float64, bool) {
+	if p.fillPolicy == FillPolicyClose || p.fillPolicy == "" {
+		switch {
+		case order.Side == model.SideTypeBuy:
+			return order.Price, order.Price >= candle.Close
+		case isStopOrder(order):
+			return *order.Stop, candle.Low <= *order.Stop
+		default:
+			return order.Price, candle.High >= order.Price
+		}
+	}
+
+	// when the candle opens beyond the price (gap), orders are executed in the open price
+	switch {
+	case order.Side == model.SideTypeBuy:
+		return math.Min(order.Price, candle.Open), candle.Low <= order.Price
+	case order.Type == model.OrderTypeStopLoss:
+		return math.Min(*order.Stop, candle.Open), candle.Low <= *order.Stop
+	case order.Type == model.OrderTypeStopLossLimit:
+		return *order.Stop, candle.Low <= *order.Stop
+	default:
+		return math.Max(order.Price, candle.Open), candle.High >= order.Price
+	}
+}
+
+// fillRank returns the priority of an order touched in a candle, lower values are executed first
+func (p *PaperWallet) fillRank(order model.Order, candle model.Candle) int {
+	switch p.fillPolicy {
+	case FillPolicyPessimistic:
+		if isStopOrder(order) {
+			return 0
+		}
+		return 1
+	case FillPolicyOptimistic:
+		if isStopOrder(order) {
+			return 1
+		}
+		return 0
+	case FillPolicyOHLC:
+		path := []float64{candle.Open, candle.High, candle.Low, candle.Close}
+		if candle.Close > candle.Open {
+			path = []float64{candle.Open, candle.Low, candle.High, candle.Close}
+		}
+
+		for i, price := range path {
+			switch {
+			case order.Side == model.SideTypeBuy && price <= order.Price,
+				isStopOrder(order) && price <= *order.Stop,
+				order.Side == model.SideTypeSell && !isStopOrder(order) && price >= order.Price:
+				return i
+			}
+		}
+		return len(path)
+	}
+	return 0
+}
+
+// fillOrders executes the pending orders of the candle pair according to the fill policy
+func (p *PaperWallet) fillOrders(candle model.Candle) {
+	type fill struct {
+		index int
+		price float64
+		rank  int
+	}
+
+	fills := make([]fill, 0)
+	for i, order := range p.orders {
+		if order.Pair != candle.Pair || !isPending(order) {
+			continue
+		}
+
+		if _, ok := p.volume[candle.Pair]; !ok {
+			p.volume[candle.Pair] = 0
+		}
+
+		if isStopOrder(order) && order.Stop == nil {
+			continue
+		}
+
+		if price, ok := p.fillPrice(order, candle); ok && (p.rules == nil || p.rules.Fillable(order, price)) {
+			fills = append(fills, fill{index: i, price: price, rank: p.fillRank(order, candle)})
+		}
+	}
+
+	sort.SliceStable(fills, func(i, j int) bool {
+		return fills[i].rank < fills[j].rank
+	})
+
+	// volume available for pending orders in this candle
+	available := math.Inf(1)
+	if p.liquidity > 0 {
+		available = p.liquidity * candle.Volume
+	}
+
+	for _, f := range fills {
+		// order can be canceled by other order of the same group
+		if !isPending(p.orders[f.index]) {
+			continue
+		}
+
+		quantity := math.Min(p.orders[f.index].Quantity-p.orders[f.index].ExecutedQuantity, available)
+		if quantity <= 0 {
+			continue
+		}
+		available -= quantity
+
+		if p.orders[f.index].Side == model.SideTypeBuy {
+			p.fillBuy(f.index, f.price, quantity, candle)
+		} else {
+			p.fillSell(f.index, f.price, quantity, candle)
+		}
+	}
+}
+
+// execute registers the execution of a quantity of the order
+func (p *PaperWallet) execute(i int, price, quantity float64, candle model.Candle) {
+	p.volume[candle.Pair] += price * quantity
+	p.orders[i].UpdatedAt = candle.Time
+	executed := p.orders[i].ExecutedQuantity
+	p.orders[i].AvgPrice = (p.orders[i].AvgPrice*executed + price*quantity) / (executed + quantity)
+	p.orders[i].ExecutedQuantity += quantity
+	p.orders[i].Status = model.OrderStatusTypePartiallyFilled
+	if p.orders[i].ExecutedQuantity >= p.orders[i].Quantity {
+		p.orders[i].ExecutedQuantity = p.orders[i].Quantity
+		p.orders[i].Status = model.OrderStatusTypeFilled
+	}
+
+	if p.rules != nil {
+		p.rules.OnFill(p.orders[i], quantity)
+	}
+}
+
+func (p *PaperWallet) fillBuy(i int, price, quantity float64, candle model.Candle) {
+	order := p.orders[i]
+	asset, quote := SplitAssetQuote(order.Pair)
+	if _, ok := p.assets[asset]; !ok {
+		p.assets[asset] = &assetInfo{}
+	}
+
+	p.execute(i, price, quantity, candle)
+
+	// update assets size, values were locked with the order price
+	p.updateAveragePrice(order.Side, order.Pair, quantity, price)
+	p.assets[asset].Free = p.assets[asset].Free + quantity
+	p.assets[quote].Lock = p.assets[quote].Lock - order.Price*quantity
+	p.assets[quote].Free = p.assets[quote].Free + (order.Price-price)*quantity
+	p.chargeFee(&p.orders[i], price, quantity, true)
+}
+
+func (p *PaperWallet) fillSell(i int, price, quantity float64, candle model.Candle) {
+	order := p.orders[i]
+	asset, quote := SplitAssetQuote(order.Pair)
+
+	// market orders triggered by price take liquidity from the book
+	if p.slippage != nil && isTakerOrder(order) {
+		price = p.slippage.Price(order.Side, price, quantity, candle)
+	}
+
+	// Cancel other orders from same group
+	if order.GroupID != nil {
+		for j, groupOrder := range p.orders {
+			if groupOrder.GroupID != nil && *groupOrder.GroupID == *order.GroupID &&
+				groupOrder.ExchangeID != order.ExchangeID {
+				p.orders[j].Status = model.OrderStatusTypeCanceled
+				p.orders[j].UpdatedAt = candle.Time
+				break
+			}
+		}
+	}
+
+	if _, ok := p.assets[quote]; !ok {
+		p.assets[quote] = &assetInfo{}
+	}
+
+	p.execute(i, price, quantity, candle)
+
+	// update assets size
+	p.updateAveragePrice(order.Side, order.Pair, quantity, price)
+	p.assets[asset].Lock = p.assets[asset].Lock - quantity
+	p.assets[quote].Free = p.assets[quote].Free + quantity*price
+	p.chargeFee(&p.orders[i], price, quantity, !isTakerOrder(order) && !isStopOrder(order))
+}
@@ -0,0 +1,201 @@
+package exchange
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ezquant/azbot/azbot/model"
+)
+
+// ocoWallet returns a wallet with 1 BTC and a pending OCO order with target 120 and stop 90
+func ocoWallet(t *testing.T, options ...PaperWalletOption) *PaperWallet {
+	t.Helper()
+
+	options = append(options, WithPaperAsset("USDT", 0), WithPaperAsset("BTC", 1))
+	wallet := NewPaperWallet(context.Background(), "USDT", options...)
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Open: 100, High: 100, Low: 100, Close: 100})
+
+	_, err := wallet.CreateOrderOCO(model.SideTypeSell, "BTCUSDT", 1, 120, 90, 90)
+	require.NoError(t, err)
+	return wallet
+}
+
+func TestPaperWallet_FillPolicy(t *testing.T) {
+	tt := []struct {
+		name   string
+		policy FillPolicy
+		candle model.Candle
+		filled model.OrderType
+		price  float64
+	}{
+		{
+			name:   "close policy keeps creation order",
+			policy: FillPolicyClose,
+			candle: model.Candle{Open: 100, High: 125, Low: 85, Close: 100},
+			filled: model.OrderTypeLimitMaker,
+			price:  120,
+		},
+		{
+			name:   "pessimistic",
+			policy: FillPolicyPessimistic,
+			candle: model.Candle{Open: 100, High: 125, Low: 85, Close: 100},
+			filled: model.OrderTypeStopLoss,
+			price:  90,
+		},
+		{
+			name:   "optimistic",
+			policy: FillPolicyOptimistic,
+			candle: model.Candle{Open: 100, High: 125, Low: 85, Close: 100},
+			filled: model.OrderTypeLimitMaker,
+			price:  120,
+		},
+		{
+			name:   "ohlc bullish candle touches low first",
+			policy: FillPolicyOHLC,
+			candle: model.Candle{Open: 100, High: 125, Low: 85, Close: 110},
+			filled: model.OrderTypeStopLoss,
+			price:  90,
+		},
+		{
+			name:   "ohlc bearish candle touches high first",
+			policy: FillPolicyOHLC,
+			candle: model.Candle{Open: 100, High: 125, Low: 85, Close: 95},
+			filled: model.OrderTypeLimitMaker,
+			price:  120,
+		},
+		{
+			name:   "gap down fills stop at open",
+			policy: FillPolicyPessimistic,
+			candle: model.Candle{Open: 80, High: 85, Low: 75, Close: 80},
+			filled: model.OrderTypeStopLoss,
+			price:  80,
+		},
+		{
+			name:   "gap up fills target at open",
+			policy: FillPolicyPessimistic,
+			candle: model.Candle{Open: 130, High: 135, Low: 125, Close: 130},
+			filled: model.OrderTypeLimitMaker,
+			price:  130,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			wallet := ocoWallet(t, WithPaperFillPolicy(tc.policy))
+			tc.candle.Pair = "BTCUSDT"
+			wallet.OnCandle(tc.candle)
+
+			var filled, canceled int
+			for _, order := range wallet.orders {
+				switch order.Status {
+				case model.OrderStatusTypeFilled:
+					filled++
+					require.Equal(t, tc.filled, order.Type)
+					require.Equal(t, tc.price, order.AvgPrice)
+				case model.OrderStatusTypeCanceled:
+					canceled++
+				}
+			}
+			require.Equal(t, 1, filled)
+			require.Equal(t, 1, canceled)
+			require.Equal(t, tc.price, wallet.assets["USDT"].Free)
+		})
+	}
+}
+
+func TestPaperWallet_FillPolicyBuyGap(t *testing.T) {
+	wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100),
+		WithPaperFillPolicy(FillPolicyPessimistic))
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Open: 110, High: 110, Low: 110, Close: 110})
+
+	_, err := wallet.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 100)
+	require.NoError(t, err)
+
+	// limit price not touched
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Open: 110, High: 110, Low: 101, Close: 105})
+	require.Equal(t, model.OrderStatusTypeNew, wallet.orders[0].Status)
+
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Open: 90, High: 95, Low: 85, Close: 92})
+	require.Equal(t, model.OrderStatusTypeFilled, wallet.orders[0].Status)
+	require.Equal(t, 1.0, wallet.assets["BTC"].Free)
+	require.Equal(t, 10.0, wallet.assets["USDT"].Free)
+	require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
+	require.Equal(t, 90.0, wallet.avgLongPrice["BTCUSDT"])
+	require.Equal(t, 90.0, wallet.orders[0].AvgPrice)
+}
+
+func TestPaperWallet_SubCandles(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	feed := &CSVFeed{
+		Feeds: map[string]PairFeed{},
+		CandlePairTimeFrame: map[string][]model.Candle{
+			"BTCUSDT--1m": {
+				{Time: start, Open: 100, High: 101, Low: 99, Close: 100},
+				{Time: start.Add(time.Minute), Open: 100, High: 125, Low: 100, Close: 121},
+				{Time: start.Add(2 * time.Minute), Open: 121, High: 121, Low: 85, Close: 110},
+				{Time: start.Add(time.Hour), Open: 110, High: 110, Low: 50, Close: 50},
+			},
+		},
+	}
+
+	// the 1h candle touches both prices and is bullish, sub candles show the target was reached first
+	wallet := ocoWallet(t, WithPaperFillPolicy(FillPolicyOHLC), WithPaperSubCandles(feed, "1h", "1m"))
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Time: start, Open: 100, High: 125, Low: 85, Close: 110,
+		Complete: true})
+
+	require.Equal(t, model.OrderStatusTypeFilled, wallet.orders[0].Status)
+	require.Equal(t, model.OrderTypeLimitMaker, wallet.orders[0].Type)
+	require.Equal(t, start, wallet.orders[0].UpdatedAt)
+	require.Equal(t, model.OrderStatusTypeCanceled, wallet.orders[1].Status)
+	require.Equal(t, 120.0, wallet.assets["USDT"].Free)
+
+	t.Run("without sub candles", func(t *testing.T) {
+		wallet := ocoWallet(t, WithPaperFillPolicy(FillPolicyOHLC), WithPaperSubCandles(feed, "1h", "1m"))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Time: start.Add(24 * time.Hour), Open: 100, High: 125,
+			Low: 85, Close: 110, Complete: true})
+
+		require.Equal(t, model.OrderStatusTypeFilled, wallet.orders[1].Status)
+		require.Equal(t, 90.0, wallet.assets["USDT"].Free)
+	})
+}
+
+func TestPaperWallet_Liquidity(t *testing.T) {
+	wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000),
+		WithPaperLiquidity(0.1))
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 110, Low: 110, High: 110, Volume: 10})
+
+	order, err := wallet.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 2.5, 100)
+	require.NoError(t, err)
+
+	// 10% of the candle volume is available
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Low: 100, High: 100, Volume: 10})
+	order, err = wallet.Order("BTCUSDT", order.ExchangeID)
+	require.NoError(t, err)
+	require.Equal(t, model.OrderStatusTypePartiallyFilled, order.Status)
+	require.Equal(t, 1.0, order.ExecutedQuantity)
+	require.Equal(t, 1.0, wallet.assets["BTC"].Free)
+	require.Equal(t, 150.0, wallet.assets["USDT"].Lock)
+	require.Equal(t, 750.0, wallet.assets["USDT"].Free)
+
+	// orders share the candle liquidity
+	sell, err := wallet.CreateOrderLimit(model.SideTypeSell, "BTCUSDT", 0.5, 90)
+	require.NoError(t, err)
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Low: 100, High: 100, Volume: 15})
+	order, err = wallet.Order("BTCUSDT", order.ExchangeID)
+	require.NoError(t, err)
+	require.Equal(t, model.OrderStatusTypeFilled, order.Status)
+	require.Equal(t, 2.5, order.ExecutedQuantity)
+	sell, err = wallet.Order("BTCUSDT", sell.ExchangeID)
+	require.NoError(t, err)
+	require.Equal(t, model.OrderStatusTypeNew, sell.Status)
+
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Low: 100, High: 100, Volume: 2})
+	sell, err = wallet.Order("BTCUSDT", sell.ExchangeID)
+	require.NoError(t, err)
+	require.Equal(t, model.OrderStatusTypePartiallyFilled, sell.Status)
+	require.InDelta(t, 0.2, sell.ExecutedQuantity, 1e-9)
+	require.InDelta(t, 2.3, wallet.assets["BTC"].Free+wallet.assets["BTC"].Lock, 1e-9)
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sync"
 
 	"github.com/adshao/go-binance/v2"
 	"github.com/adshao/go-binance/v2/futures"
@@ -20,6 +21,8 @@ var (
 	//go:embed pairs.json
 	pairs             []byte
 	pairAssetQuoteMap = make(map[string]AssetQuote)
+	// pairsMutex guards pairAssetQuoteMap, pairs are registered while feeds and controllers read them
+	pairsMutex sync.RWMutex
 )
 
 func init() {
@@ -29,7 +32,21 @@ func init() {
 	}
 }
 
+// RegisterPair adds a pair that is not listed by Binance, eg: stocks loaded from local files
+func RegisterPair(pair, asset, quote string) {
+	pairsMutex.Lock()
+	defer pairsMutex.Unlock()
+
+	pairAssetQuoteMap[pair] = AssetQuote{
+		Quote: quote,
+		Asset: asset,
+	}
+}
+
 func SplitAssetQuote(pair string) (asset string, quote string) {
+	pairsMutex.RLock()
+	defer pairsMutex.RUnlock()
+
 	data := pairAssetQuoteMap[pair]
 	return data.Asset, data.Quote
 }
@@ -47,6 +64,9 @@ func updateParisFile() error {
 		return fmt.Errorf("failed to get exchange info: %v", err)
 	}
 
+	pairsMutex.Lock()
+	defer pairsMutex.Unlock()
+
 	for _, info := range sportInfo.Symbols {
 		pairAssetQuoteMap[info.Symbol] = AssetQuote{
 			Quote: info.QuoteAsset,
@@ -1,6 +1,8 @@
 package exchange
 
 import (
+	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -27,6 +29,33 @@ func TestSplitAssetQuote(t *testing.T) {
 	}
 }
 
+func TestRegisterPair(t *testing.T) {
+	RegisterPair("SH600104CNY", "SH600104", "CNY")
+	asset, quote := SplitAssetQuote("SH600104CNY")
+	require.Equal(t, "SH600104", asset)
+	require.Equal(t, "CNY", quote)
+
+	t.Run("concurrent", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(2)
+			pair := fmt.Sprintf("SZ%06dCNY", i)
+			go func() {
+				defer wg.Done()
+				RegisterPair(pair, pair[:8], "CNY")
+			}()
+			go func() {
+				defer wg.Done()
+				SplitAssetQuote(pair)
+			}()
+		}
+		wg.Wait()
+
+		asset, _ := SplitAssetQuote("SZ000009CNY")
+		require.Equal(t, "SZ000009", asset)
+	})
+}
+
 func TestUpdatePairFile(t *testing.T) {
 	t.Skip() // it is not a test, just an utilitary to update paris list
 	err := updateParisFile()
@@ -31,8 +31,13 @@ type PaperWallet struct {
 	ctx           context.Context
 	baseCoin      string
 	counter       int64
-	takerFee      float64
-	makerFee      float64
+	feeModel      FeeModel
+	slippage      SlippageModel
+	fillPolicy    FillPolicy
+	subCandles    *subCandleFeed
+	liquidity     float64
+	rules         MarketRules
+	actions       map[string][]CorporateAction
 	initialValue  float64
 	feeder        service.Feeder
 	orders        []model.Order
@@ -40,13 +45,20 @@ type PaperWallet struct {
 	avgShortPrice map[string]float64
 	avgLongPrice  map[string]float64
 	volume        map[string]float64
+	fees          map[string]float64
 	lastCandle    map[string]model.Candle
 	fistCandle    map[string]model.Candle
 	assetValues   map[string][]AssetValue
 	equityValues  []AssetValue
+	exposed       int
 }
 
+// AssetsInfo returns the trading limits of the data feed, if any, eg: lot size of stocks
 func (p *PaperWallet) AssetsInfo(pair string) model.AssetInfo {
+	if p.feeder != nil {
+		return p.feeder.AssetsInfo(pair)
+	}
+
 	asset, quote := SplitAssetQuote(pair)
 	return model.AssetInfo{
 		BaseAsset:          asset,
@@ -71,10 +83,24 @@ func WithPaperAsset(pair string, amount float64) PaperWalletOption {
 	}
 }
 
+// WithPaperFee charges a percentage of the filled value for maker and taker orders, eg: 0.001 for 0.1%
 func WithPaperFee(maker, taker float64) PaperWalletOption {
 	return func(wallet *PaperWallet) {
-		wallet.makerFee = maker
-		wallet.takerFee = taker
+		wallet.feeModel = MakerTakerFee{Maker: maker, Taker: taker}
+	}
+}
+
+// WithPaperFeeModel sets a custom fee model, it replaces the percentages defined with WithPaperFee
+func WithPaperFeeModel(feeModel FeeModel) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.feeModel = feeModel
+	}
+}
+
+// WithPaperSlippage sets the slippage model applied to fills that take liquidity (market and stop market orders)
+func WithPaperSlippage(slippage SlippageModel) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.slippage = slippage
 	}
 }
 
@@ -90,11 +116,13 @@ func NewPaperWallet(ctx context.Context, baseCoin string, options ...PaperWallet
 		baseCoin:      baseCoin,
 		orders:        make([]model.Order, 0),
 		assets:        make(map[string]*assetInfo),
+		fillPolicy:    FillPolicyClose,
 		fistCandle:    make(map[string]model.Candle),
 		lastCandle:    make(map[string]model.Candle),
 		avgShortPrice: make(map[string]float64),
 		avgLongPrice:  make(map[string]float64),
 		volume:        make(map[string]float64),
+		fees:          make(map[string]float64),
 		assetValues:   make(map[string][]AssetValue),
 		equityValues:  make([]AssetValue, 0),
 	}
@@ -174,28 +202,95 @@ func (p *PaperWallet) MaxDrawdown() (float64, time.Time, time.Time) {
 	return globalMin / globalMinBase, globalMinStart, globalMinEnd
 }
 
+// InitialValue returns the amount of base coin available when the wallet was created
+func (p *PaperWallet) InitialValue() float64 {
+	return p.initialValue
+}
+
+// positionValue returns the value in base coin of the position in a given pair, using the last candle close
+func (p *PaperWallet) positionValue(pair string) (quantity, value float64) {
+	asset, _ := SplitAssetQuote(pair)
+	if p.assets[asset] == nil {
+		return 0, 0
+	}
+
+	quantity = p.assets[asset].Free + p.assets[asset].Lock
+	value = quantity * p.lastCandle[pair].Close
+	if quantity < 0 {
+		totalShort := 2.0*p.avgShortPrice[pair]*quantity - p.lastCandle[pair].Close*quantity
+		value = math.Abs(totalShort)
+	}
+	return quantity, value
+}
+
+// Value returns the current portfolio value in base coin, including open positions
+func (p *PaperWallet) Value() float64 {
+	var total float64
+	for pair := range p.lastCandle {
+		_, value := p.positionValue(pair)
+		total += value
+	}
+
+	if info, ok := p.assets[p.baseCoin]; ok {
+		total += info.Free + info.Lock
+	}
+	return total
+}
+
+// MarketChange returns the average price change of the traded pairs since the first candle (buy and hold)
+func (p *PaperWallet) MarketChange() float64 {
+	if len(p.lastCandle) == 0 {
+		return 0
+	}
+
+	var marketChange float64
+	for pair := range p.lastCandle {
+		marketChange += (p.lastCandle[pair].Close - p.fistCandle[pair].Close) / p.fistCandle[pair].Close
+	}
+	return marketChange / float64(len(p.lastCandle))
+}
+
+// Volume returns the traded volume in base coin by pair
+func (p *PaperWallet) Volume() map[string]float64 {
+	volume := make(map[string]float64, len(p.volume))
+	for pair, value := range p.volume {
+		volume[pair] = value
+	}
+	return volume
+}
+
+// Fees returns the commissions paid in base coin by pair
+func (p *PaperWallet) Fees() map[string]float64 {
+	fees := make(map[string]float64, len(p.fees))
+	for pair, value := range p.fees {
+		fees[pair] = value
+	}
+	return fees
+}
+
+// Exposure returns the fraction of complete candles in which the wallet had an open position
+func (p *PaperWallet) Exposure() float64 {
+	if len(p.equityValues) == 0 {
+		return 0
+	}
+	return float64(p.exposed) / float64(len(p.equityValues))
+}
+
 func (p *PaperWallet) Summary() {
 	var (
-		total        float64
-		marketChange float64
-		volume       float64
+		total  float64
+		volume float64
 	)
 
 	fmt.Println("-- FINAL WALLET --")
 	for pair := range p.lastCandle {
 		asset, quote := SplitAssetQuote(pair)
-		quantity := p.assets[asset].Free + p.assets[asset].Lock
-		value := quantity * p.lastCandle[pair].Close
-		if quantity < 0 {
-			totalShort := 2.0*p.avgShortPrice[pair]*quantity - p.lastCandle[pair].Close*quantity
-			value = math.Abs(totalShort)
-		}
+		quantity, value := p.positionValue(pair)
 		total += value
-		marketChange += (p.lastCandle[pair].Close - p.fistCandle[pair].Close) / p.fistCandle[pair].Close
 		fmt.Printf("%.4f %s = %.4f %s\n", quantity, asset, total, quote)
 	}
 
-	avgMarketChange := marketChange / float64(len(p.lastCandle))
+	avgMarketChange := p.MarketChange()
 	baseCoinValue := p.assets[p.baseCoin].Free + p.assets[p.baseCoin].Lock
 	profit := total + baseCoinValue - p.initialValue
 	fmt.Printf("%.4f %s\n", baseCoinValue, p.baseCoin)
@@ -206,6 +301,13 @@ func (p *PaperWallet) Summary() {
 	fmt.Printf("FINAL PORTFOLIO     = %.2f %s\n", total+baseCoinValue, p.baseCoin)
 	fmt.Printf("GROSS PROFIT        =  %f %s (%.2f%%)\n", profit, p.baseCoin, profit/p.initialValue*100)
 	fmt.Printf("MARKET CHANGE (B&H) =  %.2f%%\n", avgMarketChange*100)
+	if len(p.fees) > 0 {
+		var fees float64
+		for _, fee := range p.fees {
+			fees += fee
+		}
+		fmt.Printf("FEES                =  %f %s\n", fees, p.baseCoin)
+	}
 	fmt.Println()
 	fmt.Println("------ RISK -------")
 	fmt.Printf("MAX DRAWDOWN = %.2f %%\n", maxDrawDown*100)
@@ -219,7 +321,9 @@ func (p *PaperWallet) Summary() {
 	fmt.Println("-------------------")
 }
 
-func (p *PaperWallet) validateFunds(side model.SideType, pair string, amount, value float64, fill bool) error {
+// validateFunds checks and locks the funds of an order. Buy orders must also cover the fee, it is charged
+// from the quote asset when the order is filled.
+func (p *PaperWallet) validateFunds(side model.SideType, pair string, amount, value, fee float64, fill bool) error {
 	asset, quote := SplitAssetQuote(pair)
 	if _, ok := p.assets[asset]; !ok {
 		p.assets[asset] = &assetInfo{}
@@ -275,7 +379,7 @@ func (p *PaperWallet) validateFunds(side model.SideType, pair string, amount, va
 			amountToBuy = amount + p.assets[asset].Free
 		}
 
-		if funds < amountToBuy*value {
+		if funds < amountToBuy*value+fee {
 			return &OrderError{
 				Err:      ErrInsufficientFunds,
 				Pair:     pair,
@@ -302,6 +406,50 @@ func (p *PaperWallet) validateFunds(side model.SideType, pair string, amount, va
 	return nil
 }
 
+// validateRules checks the order with the market rules, if any
+func (p *PaperWallet) validateRules(order model.Order) error {
+	if p.rules == nil {
+		return nil
+	}
+
+	var position float64
+	asset, _ := SplitAssetQuote(order.Pair)
+	if info, ok := p.assets[asset]; ok {
+		position = info.Free
+	}
+	return p.rules.ValidateOrder(order, position)
+}
+
+// estimateFee returns the commission of filling the order at its price, zero without a fee model
+func (p *PaperWallet) estimateFee(order model.Order, maker bool) float64 {
+	if p.feeModel == nil {
+		return 0
+	}
+	return p.feeModel.Fee(order, order.Price, order.Quantity, maker)
+}
+
+// chargeFee debits the commission of a fill from the quote asset and registers it in the order
+func (p *PaperWallet) chargeFee(order *model.Order, price, quantity float64, maker bool) {
+	if p.feeModel == nil {
+		return
+	}
+
+	fee := p.feeModel.Fee(*order, price, quantity, maker)
+	if fee == 0 {
+		return
+	}
+
+	_, quote := SplitAssetQuote(order.Pair)
+	if _, ok := p.assets[quote]; !ok {
+		p.assets[quote] = &assetInfo{}
+	}
+
+	p.assets[quote].Free -= fee
+	p.fees[order.Pair] += fee
+	order.Fee += fee
+	order.FeeAsset = quote
+}
+
 func (p *PaperWallet) updateAveragePrice(side model.SideType, pair string, amount, value float64) {
 	actualQty := 0.0
 	asset, quote := SplitAssetQuote(pair)
@@ -373,74 +521,18 @@ func (p *PaperWallet) OnCandle(candle model.Candle) {
 		p.fistCandle[candle.Pair] = candle
 	}
 
-	for i, order := range p.orders {
-		if order.Pair != candle.Pair || order.Status != model.OrderStatusTypeNew {
-			continue
-		}
-
-		if _, ok := p.volume[candle.Pair]; !ok {
-			p.volume[candle.Pair] = 0
-		}
-
-		asset, quote := SplitAssetQuote(order.Pair)
-		if order.Side == model.SideTypeBuy && order.Price >= candle.Close {
-			if _, ok := p.assets[asset]; !ok {
-				p.assets[asset] = &assetInfo{}
-			}
-
-			p.volume[candle.Pair] += order.Price * order.Quantity
-			p.orders[i].UpdatedAt = candle.Time
-			p.orders[i].Status = model.OrderStatusTypeFilled
-
-			// update assets size
-			p.updateAveragePrice(order.Side, order.Pair, order.Quantity, order.Price)
-			p.assets[asset].Free = p.assets[asset].Free + order.Quantity
-			p.assets[quote].Lock = p.assets[quote].Lock - order.Price*order.Quantity
-		}
-
-		if order.Side == model.SideTypeSell {
-			var orderPrice float64
-			if (order.Type == model.OrderTypeLimit ||
-				order.Type == model.OrderTypeLimitMaker ||
-				order.Type == model.OrderTypeTakeProfit ||
-				order.Type == model.OrderTypeTakeProfitLimit) &&
-				candle.High >= order.Price {
-				orderPrice = order.Price
-			} else if (order.Type == model.OrderTypeStopLossLimit ||
-				order.Type == model.OrderTypeStopLoss) &&
-				candle.Low <= *order.Stop {
-				orderPrice = *order.Stop
-			} else {
-				continue
-			}
-
-			// Cancel other orders from same group
-			if order.GroupID != nil {
-				for j, groupOrder := range p.orders {
-					if groupOrder.GroupID != nil && *groupOrder.GroupID == *order.GroupID &&
-						groupOrder.ExchangeID != order.ExchangeID {
-						p.orders[j].Status = model.OrderStatusTypeCanceled
-						p.orders[j].UpdatedAt = candle.Time
-						break
-					}
-				}
-			}
-
-			if _, ok := p.assets[quote]; !ok {
-				p.assets[quote] = &assetInfo{}
-			}
-
-			orderVolume := order.Quantity * orderPrice
+	if p.actions != nil {
+		p.applyCorporateActions(candle)
+	}
 
-			p.volume[candle.Pair] += orderVolume
-			p.orders[i].UpdatedAt = candle.Time
-			p.orders[i].Status = model.OrderStatusTypeFilled
+	if p.rules != nil {
+		p.rules.OnCandle(candle)
+	}
 
-			// update assets size
-			p.updateAveragePrice(order.Side, order.Pair, order.Quantity, orderPrice)
-			p.assets[asset].Lock = p.assets[asset].Lock - order.Quantity
-			p.assets[quote].Free = p.assets[quote].Free + order.Quantity*orderPrice
-		}
+	if p.subCandles != nil && p.hasPendingOrders(candle.Pair) {
+		p.replaySubCandles(candle)
+	} else {
+		p.fillOrders(candle)
 	}
 
 	if candle.Complete {
@@ -462,6 +554,10 @@ func (p *PaperWallet) OnCandle(candle model.Candle) {
 			})
 		}
 
+		if total != 0 {
+			p.exposed++
+		}
+
 		baseCoinInfo := p.assets[p.baseCoin]
 		p.equityValues = append(p.equityValues, AssetValue{
 			Time:  candle.Time,
@@ -509,7 +605,13 @@ func (p *PaperWallet) CreateOrderOCO(side model.SideType, pair string,
 		return nil, ErrInvalidQuantity
 	}
 
-	err := p.validateFunds(side, pair, size, price, false)
+	limitOrder := model.Order{Pair: pair, Side: side, Type: model.OrderTypeLimitMaker, Price: price, Quantity: size}
+	err := p.validateRules(limitOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.validateFunds(side, pair, size, price, p.estimateFee(limitOrder, true), false)
 	if err != nil {
 		return nil, err
 	}
@@ -558,7 +660,13 @@ func (p *PaperWallet) CreateOrderLimit(side model.SideType, pair string,
 		return model.Order{}, ErrInvalidQuantity
 	}
 
-	err := p.validateFunds(side, pair, size, limit, false)
+	limitOrder := model.Order{Pair: pair, Side: side, Type: model.OrderTypeLimit, Price: limit, Quantity: size}
+	err := p.validateRules(limitOrder)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	err = p.validateFunds(side, pair, size, limit, p.estimateFee(limitOrder, true), false)
 	if err != nil {
 		return model.Order{}, err
 	}
@@ -592,7 +700,13 @@ func (p *PaperWallet) CreateOrderStop(pair string, size float64, limit float64)
 		return model.Order{}, ErrInvalidQuantity
 	}
 
-	err := p.validateFunds(model.SideTypeSell, pair, size, limit, false)
+	err := p.validateRules(model.Order{Pair: pair, Side: model.SideTypeSell, Type: model.OrderTypeStopLossLimit,
+		Price: limit, Quantity: size})
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	err = p.validateFunds(model.SideTypeSell, pair, size, limit, 0, false)
 	if err != nil {
 		return model.Order{}, err
 	}
@@ -618,7 +732,22 @@ func (p *PaperWallet) createOrderMarket(side model.SideType, pair string, size f
 		return model.Order{}, ErrInvalidQuantity
 	}
 
-	err := p.validateFunds(side, pair, size, p.lastCandle[pair].Close, true)
+	price := p.lastCandle[pair].Close
+	if p.slippage != nil {
+		price = p.slippage.Price(side, price, size, p.lastCandle[pair])
+	}
+
+	order := model.Order{Pair: pair, Side: side, Type: model.OrderTypeMarket, Price: price, Quantity: size}
+	err := p.validateRules(order)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	if p.rules != nil && !p.rules.Fillable(order, price) {
+		return model.Order{}, &OrderError{Err: ErrPriceLimit, Pair: pair, Quantity: size}
+	}
+
+	err = p.validateFunds(side, pair, size, price, p.estimateFee(order, false), true)
 	if err != nil {
 		return model.Order{}, err
 	}
@@ -627,9 +756,9 @@ func (p *PaperWallet) createOrderMarket(side model.SideType, pair string, size f
 		p.volume[pair] = 0
 	}
 
-	p.volume[pair] += p.lastCandle[pair].Close * size
+	p.volume[pair] += price * size
 
-	order := model.Order{
+	order = model.Order{
 		ExchangeID: p.ID(),
 		CreatedAt:  p.lastCandle[pair].Time,
 		UpdatedAt:  p.lastCandle[pair].Time,
@@ -637,8 +766,15 @@ func (p *PaperWallet) createOrderMarket(side model.SideType, pair string, size f
 		Side:       side,
 		Type:       model.OrderTypeMarket,
 		Status:     model.OrderStatusTypeFilled,
-		Price:      p.lastCandle[pair].Close,
+		Price:      price,
+		AvgPrice:   price,
 		Quantity:   size,
+
+		ExecutedQuantity: size,
+	}
+	p.chargeFee(&order, price, size, false)
+	if p.rules != nil {
+		p.rules.OnFill(order, size)
 	}
 
 	p.orders = append(p.orders, order)
@@ -13,7 +13,7 @@ import (
 func TestPaperWallet_ValidateFunds(t *testing.T) {
 	t.Run("simple lock limit", func(t *testing.T) {
 		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100))
-		err := wallet.validateFunds(model.SideTypeBuy, "BTCUSDT", 1, 100, false)
+		err := wallet.validateFunds(model.SideTypeBuy, "BTCUSDT", 1, 100, 0, false)
 		require.NoError(t, err)
 		require.Equal(t, 0.0, wallet.assets["USDT"].Free)
 		require.Equal(t, 100.0, wallet.assets["USDT"].Lock)
@@ -24,7 +24,7 @@ func TestPaperWallet_ValidateFunds(t *testing.T) {
 	t.Run("simple buy market", func(t *testing.T) {
 		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100))
 		wallet.lastCandle["BTCUSDT"] = model.Candle{Pair: "BTCUSDT", Close: 100}
-		err := wallet.validateFunds(model.SideTypeBuy, "BTCUSDT", 1, 100, true)
+		err := wallet.validateFunds(model.SideTypeBuy, "BTCUSDT", 1, 100, 0, true)
 		require.NoError(t, err)
 		require.Equal(t, 0.0, wallet.assets["USDT"].Free)
 		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
@@ -35,7 +35,7 @@ func TestPaperWallet_ValidateFunds(t *testing.T) {
 	t.Run("simple short market", func(t *testing.T) {
 		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100))
 		wallet.lastCandle["BTCUSDT"] = model.Candle{Pair: "BTCUSDT", Close: 100}
-		err := wallet.validateFunds(model.SideTypeSell, "BTCUSDT", 1, 100, true)
+		err := wallet.validateFunds(model.SideTypeSell, "BTCUSDT", 1, 100, 0, true)
 		require.NoError(t, err)
 		require.Equal(t, 0.0, wallet.assets["USDT"].Free)
 		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
@@ -45,7 +45,7 @@ func TestPaperWallet_ValidateFunds(t *testing.T) {
 
 	t.Run("simple short limit", func(t *testing.T) {
 		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100))
-		err := wallet.validateFunds(model.SideTypeSell, "BTCUSDT", 1, 100, false)
+		err := wallet.validateFunds(model.SideTypeSell, "BTCUSDT", 1, 100, 0, false)
 		require.NoError(t, err)
 		require.Equal(t, 0.0, wallet.assets["USDT"].Free)
 		require.Equal(t, 100.0, wallet.assets["USDT"].Lock)
@@ -57,7 +57,7 @@ func TestPaperWallet_ValidateFunds(t *testing.T) {
 		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("BTC", 1), WithPaperAsset("USDT", 100))
 		wallet.avgLongPrice["BTCUSDT"] = 100
 
-		err := wallet.validateFunds(model.SideTypeSell, "BTCUSDT", 2, 100, true)
+		err := wallet.validateFunds(model.SideTypeSell, "BTCUSDT", 2, 100, 0, true)
 		require.NoError(t, err)
 		require.Equal(t, 0.0, wallet.assets["USDT"].Free)
 		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
@@ -69,7 +69,7 @@ func TestPaperWallet_ValidateFunds(t *testing.T) {
 		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("BTC", -1), WithPaperAsset("USDT", 100))
 		wallet.avgShortPrice["BTCUSDT"] = 100
 
-		err := wallet.validateFunds(model.SideTypeBuy, "BTCUSDT", 2, 150, true)
+		err := wallet.validateFunds(model.SideTypeBuy, "BTCUSDT", 2, 150, 0, true)
 		require.NoError(t, err)
 		require.Equal(t, 0.0, wallet.assets["USDT"].Free)
 		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
@@ -552,3 +552,65 @@ func TestUpdateAveragePrice(t *testing.T) {
 	})
 
 }
+
+func TestPaperWallet_FeeAndSlippage(t *testing.T) {
+	t.Run("market order", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000),
+			WithPaperFee(0.001, 0.002), WithPaperSlippage(FixedSlippage{BPS: 100}))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+
+		order, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+		require.Equal(t, 101.0, order.Price)
+		require.InDelta(t, 0.202, order.Fee, 1e-9)
+		require.Equal(t, "USDT", order.FeeAsset)
+		require.InDelta(t, 1000-101-0.202, wallet.assets["USDT"].Free, 1e-9)
+
+		order, err = wallet.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+		require.NoError(t, err)
+		require.Equal(t, 99.0, order.Price)
+		require.InDelta(t, 0.198, order.Fee, 1e-9)
+		require.InDelta(t, 1000-2-0.4, wallet.assets["USDT"].Free, 1e-9)
+		require.InDelta(t, 0.4, wallet.Fees()["BTCUSDT"], 1e-9)
+	})
+
+	t.Run("limit and stop orders", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000),
+			WithPaperFee(0.001, 0.002), WithPaperSlippage(FixedSlippage{BPS: 100}))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+
+		_, err := wallet.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 100)
+		require.NoError(t, err)
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, High: 100, Low: 100})
+		require.InDelta(t, 0.1, wallet.orders[0].Fee, 1e-9)
+		require.InDelta(t, 899.9, wallet.assets["USDT"].Free, 1e-9)
+
+		_, err = wallet.CreateOrderOCO(model.SideTypeSell, "BTCUSDT", 1, 120, 90, 90)
+		require.NoError(t, err)
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 85, High: 100, Low: 85})
+		require.Equal(t, model.OrderStatusTypeFilled, wallet.orders[2].Status)
+		require.Equal(t, model.OrderStatusTypeCanceled, wallet.orders[1].Status)
+
+		// stop market fills with slippage and taker fee
+		require.InDelta(t, 89.1*0.002, wallet.orders[2].Fee, 1e-9)
+		require.InDelta(t, 899.9+89.1-89.1*0.002, wallet.assets["USDT"].Free, 1e-9)
+	})
+
+	t.Run("exact free balance", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100),
+			WithPaperFee(0.001, 0.002))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, High: 100, Low: 100})
+
+		// the fee is not covered
+		expected := &OrderError{Err: ErrInsufficientFunds, Pair: "BTCUSDT", Quantity: 1}
+		_, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.Equal(t, expected, err)
+		_, err = wallet.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 100)
+		require.Equal(t, expected, err)
+		require.Equal(t, 100.0, wallet.assets["USDT"].Free)
+
+		_, err = wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 0.998)
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, wallet.assets["USDT"].Free, 0.0)
+	})
+}
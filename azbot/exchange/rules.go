@@ -0,0 +1,190 @@
+package exchange
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/ezquant/azbot/azbot/exchange/tdx_local"
+	"github.com/ezquant/azbot/azbot/model"
+)
+
+var (
+	ErrLotSize    = errors.New("quantity is not a multiple of the lot size")
+	ErrPriceLimit = errors.New("price out of the daily limit")
+	ErrUnsettled  = errors.New("quantity not available until settlement")
+)
+
+// chinextReform is the date ChiNext price limits changed from 10% to 20%
+var chinextReform = time.Date(2020, 8, 24, 0, 0, 0, 0, tdxLocation)
+
+// MarketRules restricts orders and fills of the paper wallet according to the rules of an exchange
+type MarketRules interface {
+	// OnCandle is called with every candle before pending orders are filled
+	OnCandle(candle model.Candle)
+	// ValidateOrder is called before an order is created, position is the free quantity of the asset
+	ValidateOrder(order model.Order, position float64) error
+	// Fillable returns false when the order can't be executed at the given price
+	Fillable(order model.Order, price float64) bool
+	// OnFill is called for each execution of an order
+	OnFill(order model.Order, quantity float64)
+}
+
+// WithPaperMarketRules validates orders and fills of the paper wallet with exchange rules, eg: AShareRules
+func WithPaperMarketRules(rules MarketRules) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.rules = rules
+	}
+}
+
+// PriceLimit returns the daily price limit of an A-share given its market code (XSHG or XSHE, as
+// returned by tdx_local) and security code, eg: 0.2 for STAR Market. Zero means no limit.
+func PriceLimit(market, code string, date time.Time) float64 {
+	if len(code) < 3 {
+		return 0
+	}
+
+	switch market {
+	case "XSHG":
+		if code[:3] == "688" || code[:3] == "689" {
+			return 0.2
+		}
+		return 0.1
+	case "XSHE":
+		if (code[:3] == "300" || code[:3] == "301") && !date.Before(chinextReform) {
+			return 0.2
+		}
+		return 0.1
+	}
+	return 0
+}
+
+// AShareRules implements the rules of Shanghai and Shenzhen stock exchanges for pairs loaded by TDXFeed:
+// T+1 settlement, board lots and daily price limits. Shares bought in a trading day can be sold in the
+// next day, buy orders must be a multiple of the lot size (odd lots can only be sold at once) and
+// fills are blocked at limit-up (buy) and limit-down (sell) prices.
+type AShareRules struct {
+	LotSize float64
+	// Limits overrides the daily price limit of a pair, eg: 0.05 for ST stocks
+	Limits map[string]float64
+
+	day       map[string]string
+	lastClose map[string]float64
+	prevClose map[string]float64
+	bought    map[string]float64
+	lastTime  map[string]time.Time
+}
+
+func NewAShareRules() *AShareRules {
+	return &AShareRules{
+		LotSize:   100,
+		Limits:    make(map[string]float64),
+		day:       make(map[string]string),
+		lastClose: make(map[string]float64),
+		prevClose: make(map[string]float64),
+		bought:    make(map[string]float64),
+		lastTime:  make(map[string]time.Time),
+	}
+}
+
+func (r *AShareRules) OnCandle(candle model.Candle) {
+	day := candle.Time.In(tdxLocation).Format(time.DateOnly)
+	if r.day[candle.Pair] != day {
+		if last, ok := r.lastClose[candle.Pair]; ok {
+			r.prevClose[candle.Pair] = last
+		}
+		r.day[candle.Pair] = day
+		r.bought[candle.Pair] = 0
+	}
+
+	r.lastClose[candle.Pair] = candle.Close
+	r.lastTime[candle.Pair] = candle.Time
+}
+
+// PriceLimits returns the limit-down and limit-up prices of the pair in the current trading day
+func (r *AShareRules) PriceLimits(pair string) (down, up float64, ok bool) {
+	prevClose, ok := r.prevClose[pair]
+	if !ok {
+		return 0, 0, false
+	}
+
+	limit, ok := r.Limits[pair]
+	if !ok {
+		asset, _ := SplitAssetQuote(pair)
+		if len(asset) < 3 {
+			return 0, 0, false
+		}
+
+		market, err := tdx_local.Market(asset[:2])
+		if err != nil {
+			return 0, 0, false
+		}
+		limit = PriceLimit(market, asset[2:], r.lastTime[pair])
+	}
+
+	if limit == 0 {
+		return 0, 0, false
+	}
+
+	down = math.Round(prevClose*(1-limit)*100) / 100
+	up = math.Round(prevClose*(1+limit)*100) / 100
+	return down, up, true
+}
+
+func (r *AShareRules) ValidateOrder(order model.Order, position float64) error {
+	lots := order.Quantity / r.LotSize
+	isLot := math.Abs(lots-math.Round(lots)) < 1e-9
+	if !isLot && (order.Side == model.SideTypeBuy || order.Quantity != position) {
+		return &OrderError{Err: ErrLotSize, Pair: order.Pair, Quantity: order.Quantity}
+	}
+
+	if order.Side == model.SideTypeSell && order.Quantity > position-r.bought[order.Pair] {
+		return &OrderError{Err: ErrUnsettled, Pair: order.Pair, Quantity: order.Quantity}
+	}
+
+	if order.Type != model.OrderTypeMarket {
+		down, up, ok := r.PriceLimits(order.Pair)
+		if ok && (order.Price < down || order.Price > up) {
+			return &OrderError{Err: ErrPriceLimit, Pair: order.Pair, Quantity: order.Quantity}
+		}
+	}
+
+	return nil
+}
+
+func (r *AShareRules) Fillable(order model.Order, price float64) bool {
+	down, up, ok := r.PriceLimits(order.Pair)
+	if !ok {
+		return true
+	}
+
+	if order.Side == model.SideTypeBuy {
+		return price < up
+	}
+	return price > down
+}
+
+func (r *AShareRules) OnFill(order model.Order, quantity float64) {
+	if order.Side == model.SideTypeBuy {
+		r.bought[order.Pair] += quantity
+	}
+}
+
+// AShareFee charges the commission of the broker, with a minimum value per fill, the transfer fee
+// and the stamp duty on sells, eg: AShareFee{Commission: 0.00025, MinCommission: 5, StampDuty: 0.0005,
+// TransferFee: 0.00001}
+type AShareFee struct {
+	Commission    float64
+	MinCommission float64
+	StampDuty     float64
+	TransferFee   float64
+}
+
+func (f AShareFee) Fee(order model.Order, price, quantity float64, _ bool) float64 {
+	value := price * quantity
+	fee := math.Max(value*f.Commission, f.MinCommission) + value*f.TransferFee
+	if order.Side == model.SideTypeSell {
+		fee += value * f.StampDuty
+	}
+	return fee
+}
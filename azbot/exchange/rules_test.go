@@ -0,0 +1,114 @@
+package exchange
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ezquant/azbot/azbot/model"
+)
+
+func TestPriceLimit(t *testing.T) {
+	date := time.Date(2021, 1, 4, 0, 0, 0, 0, tdxLocation)
+	require.Equal(t, 0.1, PriceLimit("XSHG", "600104", date))
+	require.Equal(t, 0.2, PriceLimit("XSHG", "688001", date))
+	require.Equal(t, 0.1, PriceLimit("XSHE", "000001", date))
+	require.Equal(t, 0.2, PriceLimit("XSHE", "300750", date))
+	require.Equal(t, 0.1, PriceLimit("XSHE", "300750", date.AddDate(-1, 0, 0)))
+	require.Equal(t, 0.0, PriceLimit("XHKG", "00700", date))
+}
+
+func TestAShareRules(t *testing.T) {
+	RegisterPair("SH600104CNY", "SH600104", "CNY")
+	day := time.Date(2021, 1, 4, 0, 0, 0, 0, tdxLocation)
+	candle := func(days int, price float64) model.Candle {
+		return model.Candle{Pair: "SH600104CNY", Time: day.AddDate(0, 0, days), Open: price, High: price,
+			Low: price, Close: price, Complete: true}
+	}
+
+	rules := NewAShareRules()
+	wallet := NewPaperWallet(context.Background(), "CNY", WithPaperAsset("CNY", 10000),
+		WithPaperMarketRules(rules))
+	wallet.OnCandle(candle(0, 20))
+
+	t.Run("lot size", func(t *testing.T) {
+		_, err := wallet.CreateOrderMarket(model.SideTypeBuy, "SH600104CNY", 150)
+		require.ErrorIs(t, err.(*OrderError).Err, ErrLotSize)
+
+		_, err = wallet.CreateOrderMarket(model.SideTypeBuy, "SH600104CNY", 200)
+		require.NoError(t, err)
+	})
+
+	t.Run("T+1", func(t *testing.T) {
+		_, err := wallet.CreateOrderMarket(model.SideTypeSell, "SH600104CNY", 100)
+		require.ErrorIs(t, err.(*OrderError).Err, ErrUnsettled)
+
+		wallet.OnCandle(candle(1, 21))
+		_, err = wallet.CreateOrderMarket(model.SideTypeSell, "SH600104CNY", 100)
+		require.NoError(t, err)
+	})
+
+	t.Run("price limits", func(t *testing.T) {
+		down, up, ok := rules.PriceLimits("SH600104CNY")
+		require.True(t, ok)
+		require.Equal(t, 18.0, down)
+		require.Equal(t, 22.0, up)
+
+		_, err := wallet.CreateOrderLimit(model.SideTypeBuy, "SH600104CNY", 100, 17)
+		require.ErrorIs(t, err.(*OrderError).Err, ErrPriceLimit)
+
+		// limit up blocks buy orders
+		wallet.OnCandle(candle(2, 23.1))
+		_, err = wallet.CreateOrderMarket(model.SideTypeBuy, "SH600104CNY", 100)
+		require.ErrorIs(t, err.(*OrderError).Err, ErrPriceLimit)
+
+		order, err := wallet.CreateOrderLimit(model.SideTypeBuy, "SH600104CNY", 100, 23.1)
+		require.NoError(t, err)
+		wallet.OnCandle(model.Candle{Pair: "SH600104CNY", Time: day.AddDate(0, 0, 2).Add(time.Hour),
+			Open: 23.1, High: 23.1, Low: 23.1, Close: 23.1})
+		order, err = wallet.Order("SH600104CNY", order.ExchangeID)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeNew, order.Status)
+
+		wallet.OnCandle(candle(3, 23))
+		order, err = wallet.Order("SH600104CNY", order.ExchangeID)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeFilled, order.Status)
+	})
+
+	t.Run("odd lots are sold at once", func(t *testing.T) {
+		rules := NewAShareRules()
+		wallet := NewPaperWallet(context.Background(), "CNY", WithPaperAsset("CNY", 0),
+			WithPaperAsset("SH600104", 150), WithPaperMarketRules(rules))
+		wallet.OnCandle(candle(10, 20))
+
+		_, err := wallet.CreateOrderMarket(model.SideTypeSell, "SH600104CNY", 50)
+		require.ErrorIs(t, err.(*OrderError).Err, ErrLotSize)
+
+		_, err = wallet.CreateOrderMarket(model.SideTypeSell, "SH600104CNY", 150)
+		require.NoError(t, err)
+	})
+
+	t.Run("custom limit", func(t *testing.T) {
+		rules := NewAShareRules()
+		rules.Limits["SH600104CNY"] = 0.05
+		rules.OnCandle(candle(10, 20))
+		rules.OnCandle(candle(11, 20))
+
+		down, up, ok := rules.PriceLimits("SH600104CNY")
+		require.True(t, ok)
+		require.Equal(t, 19.0, down)
+		require.Equal(t, 21.0, up)
+	})
+}
+
+func TestAShareFee(t *testing.T) {
+	fee := AShareFee{Commission: 0.00025, MinCommission: 5, StampDuty: 0.0005, TransferFee: 0.00001}
+	buy := model.Order{Side: model.SideTypeBuy}
+	sell := model.Order{Side: model.SideTypeSell}
+
+	require.InDelta(t, 5+0.02, fee.Fee(buy, 20, 100, true), 1e-9)
+	require.InDelta(t, 25+1+50, fee.Fee(sell, 100, 1000, false), 1e-9)
+}
@@ -1,10 +1,13 @@
 package tdx_local
 
 import (
+	"bufio"
 	"encoding/binary"
 	"fmt"
+	"math"
 	"os"
 	"path"
+	"strings"
 	"time"
 )
 
@@ -157,6 +160,18 @@ type Dataset struct {
 	Bars    []Bar
 }
 
+// Market returns the market identification code (ISO 10383) of a TDX market prefix, eg: sh -> XSHG
+func Market(prefix string) (string, error) {
+	switch strings.ToLower(prefix) {
+	case "sh":
+		return "XSHG", nil
+	case "sz":
+		return "XSHE", nil
+	default:
+		return "", fmt.Errorf("unknown market: %s", prefix)
+	}
+}
+
 // DecodeFile decodes a bar data file that has been encoded in any of
 // the supported formats. It detects file format by the file extension.
 func DecodeFile(filepath string) (*Dataset, error) {
@@ -170,14 +185,10 @@ func DecodeFile(filepath string) (*Dataset, error) {
 		return nil, err
 	}
 
-	market, symbol, ext := path.Base(filepath)[0:2], path.Base(filepath)[2:8], path.Base(filepath)[8:]
-	switch market {
-	case "sh":
-		market = "XSHG"
-	case "sz":
-		market = "XSHE"
-	default:
-		return nil, fmt.Errorf("unknown market: %s", market)
+	prefix, symbol, ext := path.Base(filepath)[0:2], path.Base(filepath)[2:8], path.Base(filepath)[8:]
+	market, err := Market(prefix)
+	if err != nil {
+		return nil, err
 	}
 
 	var barSize uint
@@ -211,3 +222,105 @@ func DecodeFile(filepath string) (*Dataset, error) {
 
 	return &Dataset{market, symbol, barSize, bars}, nil
 }
+
+// EncodeFile encodes a dataset in the format of the file extension, the inverse of DecodeFile.
+// The name of the file must start with the market prefix and the symbol, eg: sh600104.day.
+// The bar size of the dataset must match the format and the bars of minute formats must be
+// between 2004 and 2035, the years that fit in their date field.
+func EncodeFile(filepath string, dataset *Dataset) error {
+	ext := path.Ext(filepath)
+	var barSize uint
+	switch ext {
+	case ".day":
+		barSize = 1440
+	case ".5", ".lc5":
+		barSize = 5
+	case ".lc1":
+		barSize = 1
+	default:
+		return fmt.Errorf("unsupported file: %s", filepath)
+	}
+
+	// DecodeFile reads the market and the symbol from the name, eg: sh600104.day
+	if name := path.Base(filepath); len(name) != 8+len(ext) {
+		return fmt.Errorf("invalid file name, expected a market and a symbol, eg: sh600104%s: %s", ext, filepath)
+	} else if _, err := Market(name[0:2]); err != nil {
+		return err
+	}
+
+	if dataset.BarSize != barSize {
+		return fmt.Errorf("cannot encode %d min bars in %s", dataset.BarSize, filepath)
+	}
+
+	f, err := os.Create(filepath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for _, bar := range dataset.Bars {
+		record, err := encodeBar(ext, bar)
+		if err != nil {
+			return err
+		}
+
+		if err := binary.Write(w, binary.LittleEndian, record); err != nil {
+			return err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func encodeBar(ext string, bar Bar) (interface{}, error) {
+	t := bar.Time().In(tz)
+	if ext == ".day" {
+		return &dayBar{
+			RawDate:     uint32(t.Year()*10000 + int(t.Month())*100 + t.Day()),
+			RawOpen:     cents(bar.Open()),
+			RawHigh:     cents(bar.High()),
+			RawLow:      cents(bar.Low()),
+			RawClose:    cents(bar.Close()),
+			RawTurnover: bar.Turnover(),
+			RawVolume:   bar.Volume(),
+		}, nil
+	}
+
+	if t.Year() < 2004 || t.Year() > 2004+31 {
+		return nil, fmt.Errorf("cannot encode the date of a minute bar: %s", t)
+	}
+
+	date := uint16(t.Year()-2004)<<11 | uint16(int(t.Month())*100+t.Day())
+	minutes := uint16(t.Hour()*60 + t.Minute())
+	if ext == ".5" {
+		return &fiveBar{
+			RawDate:     date,
+			RawTime:     minutes,
+			RawOpen:     cents(bar.Open()),
+			RawHigh:     cents(bar.High()),
+			RawLow:      cents(bar.Low()),
+			RawClose:    cents(bar.Close()),
+			RawTurnover: bar.Turnover(),
+			RawVolume:   bar.Volume(),
+		}, nil
+	}
+
+	return &lcnBar{
+		RawDate:     date,
+		RawTime:     minutes,
+		RawOpen:     bar.Open(),
+		RawHigh:     bar.High(),
+		RawLow:      bar.Low(),
+		RawClose:    bar.Close(),
+		RawTurnover: bar.Turnover(),
+		RawVolume:   bar.Volume(),
+	}, nil
+}
+
+func cents(price float32) uint32 {
+	return uint32(math.Round(float64(price) * 100))
+}
@@ -1,6 +1,7 @@
 package tdx_local
 
 import (
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -69,3 +70,59 @@ func TestDecodeFile(t *testing.T) {
 		}
 	}
 }
+
+func TestEncodeFile(t *testing.T) {
+	for _, file := range []string{"sh600104.day", "sh600104.lc1", "sh600104.lc5", "sh600104.5"} {
+		dataset, err := DecodeFile(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		output := filepath.Join(t.TempDir(), file)
+		if err := EncodeFile(output, dataset); err != nil {
+			t.Fatal(err)
+		}
+
+		encoded, err := DecodeFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(encoded.Bars) != len(dataset.Bars) || encoded.BarSize != dataset.BarSize {
+			t.Fatalf("unexpected dataset (file: %s)\ngot: %d bars of %d min\nwant: %d bars of %d min\n", file,
+				len(encoded.Bars), encoded.BarSize, len(dataset.Bars), dataset.BarSize)
+		}
+
+		for i, bar := range dataset.Bars {
+			got := encoded.Bars[i]
+			if got.Time() != bar.Time() || !eq(got.Open(), bar.Open()) || !eq(got.High(), bar.High()) ||
+				!eq(got.Low(), bar.Low()) || !eq(got.Close(), bar.Close()) || got.Volume() != bar.Volume() ||
+				!eq(got.Turnover(), bar.Turnover()) {
+				t.Fatalf("unexpected bar %d (file: %s)\ngot: %s %.2f %.2f %.2f %.2f %d\nwant: %s %.2f %.2f %.2f %.2f %d\n",
+					i, file, got.Time(), got.Open(), got.High(), got.Low(), got.Close(), got.Volume(),
+					bar.Time(), bar.Open(), bar.High(), bar.Low(), bar.Close(), bar.Volume())
+			}
+		}
+	}
+
+	dataset, err := DecodeFile("testdata/sh600104.day")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := EncodeFile(filepath.Join(t.TempDir(), "sh600104.lc5"), dataset); err == nil {
+		t.Error("expected error for daily bars in a minute file")
+	}
+}
+
+func TestMarket(t *testing.T) {
+	for prefix, expected := range map[string]string{"sh": "XSHG", "SZ": "XSHE"} {
+		market, err := Market(prefix)
+		if err != nil || market != expected {
+			t.Errorf("unexpected market (prefix: %s)\ngot: %s %v\nwant: %s\n", prefix, market, err, expected)
+		}
+	}
+
+	if _, err := Market("hk"); err == nil {
+		t.Error("expected error for unknown market")
+	}
+}
@@ -0,0 +1,190 @@
+package exchange
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/xhit/go-str2duration/v2"
+
+	"github.com/ezquant/azbot/azbot/calendar"
+	"github.com/ezquant/azbot/azbot/exchange/tdx_local"
+	"github.com/ezquant/azbot/azbot/model"
+)
+
+// TDXQuote is the quote asset of securities loaded by TDXFeed
+const TDXQuote = "CNY"
+
+var tdxLocation = calendar.Shanghai
+
+// tdxSource is a data file of a vipdoc directory
+type tdxSource struct {
+	dir       string
+	extension string
+	timeframe string
+}
+
+// tdxSources are ordered from the largest to the smallest timeframe
+var tdxSources = []tdxSource{
+	{dir: "lday", extension: ".day", timeframe: "1d"},
+	{dir: "fzline", extension: ".lc5", timeframe: "5m"},
+	{dir: "fzline", extension: ".5", timeframe: "5m"},
+	{dir: "minline", extension: ".lc1", timeframe: "1m"},
+}
+
+// TDXFeed is a data feed of A-shares stored by TongDaXin (通达信) in a vipdoc directory
+type TDXFeed struct {
+	CSVFeed
+	Dir string
+}
+
+// TDXPair returns the pair of a TDX symbol, eg: sh600104 -> SH600104CNY
+func TDXPair(symbol string) string {
+	return strings.ToUpper(symbol) + TDXQuote
+}
+
+// NewTDXFeed creates a new data feed from a TDX vipdoc directory and resample to the target timeframe.
+// Symbols are the names of the data files without extension, eg: sh600104. For each symbol the largest
+// timeframe available that fits the target is used (lday, fzline or minline) and the pair is registered
+// as the symbol quoted in CNY, see TDXPair.
+func NewTDXFeed(vipdoc, targetTimeframe string, symbols ...string) (*TDXFeed, error) {
+	feed := &TDXFeed{
+		CSVFeed: CSVFeed{
+			Feeds:               make(map[string]PairFeed),
+			CandlePairTimeFrame: make(map[string][]model.Candle),
+			Calendar:            calendar.AShare(),
+		},
+		Dir: vipdoc,
+	}
+
+	target, err := str2duration.ParseDuration(targetTimeframe)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, symbol := range symbols {
+		pair := TDXPair(symbol)
+		source, file, err := findTDXSource(vipdoc, strings.ToLower(symbol), target)
+		if err != nil {
+			return nil, err
+		}
+
+		pairFeed, candles, err := ReadTDXCandles(file)
+		if err != nil {
+			return nil, err
+		}
+
+		RegisterPair(pair, strings.ToUpper(symbol), TDXQuote)
+		feed.Feeds[pair] = pairFeed
+		feed.CandlePairTimeFrame[feed.feedTimeframeKey(pair, source.timeframe)] = candles
+
+		err = feed.resample(pair, source.timeframe, targetTimeframe)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return feed, nil
+}
+
+// ReadTDXCandles decodes a TDX data file (.day, .lc5, .5 or .lc1), the pair and the timeframe of the
+// returned feed are taken from the file, eg: sh600104.day is SH600104CNY in 1d
+func ReadTDXCandles(file string) (PairFeed, []model.Candle, error) {
+	dataset, err := tdx_local.DecodeFile(file)
+	if err != nil {
+		return PairFeed{}, nil, err
+	}
+
+	feed := PairFeed{
+		Pair:      TDXPair(strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))),
+		File:      file,
+		Timeframe: fmt.Sprintf("%dm", dataset.BarSize),
+	}
+	if dataset.BarSize >= 24*60 {
+		feed.Timeframe = "1d"
+	}
+
+	cal := calendar.AShare()
+	candles := make([]model.Candle, 0, len(dataset.Bars))
+	for _, bar := range dataset.Bars {
+		candles = append(candles, tdxCandle(cal, feed.Pair, bar, dataset.BarSize))
+	}
+	return feed, candles, nil
+}
+
+// findTDXSource returns the file with the largest timeframe that can be resampled to the target
+func findTDXSource(vipdoc, symbol string, target time.Duration) (tdxSource, string, error) {
+	if len(symbol) < 2 {
+		return tdxSource{}, "", fmt.Errorf("invalid symbol: %s", symbol)
+	}
+
+	for _, source := range tdxSources {
+		duration, err := str2duration.ParseDuration(source.timeframe)
+		if err != nil {
+			return tdxSource{}, "", err
+		}
+
+		if target < duration || target%duration != 0 {
+			continue
+		}
+
+		file := filepath.Join(vipdoc, symbol[:2], source.dir, symbol+source.extension)
+		if _, err := os.Stat(file); err == nil {
+			return source, file, nil
+		}
+	}
+
+	return tdxSource{}, "", fmt.Errorf("%w: no data for %s in %s", ErrInsufficientData, symbol, vipdoc)
+}
+
+// tdxPrice rounds a decoded price to the tick size of funds (0.001), eg: 31.049999 -> 31.05
+func tdxPrice(value float32) float64 {
+	return math.Round(float64(value)*1000) / 1000
+}
+
+// tdxCandle converts a bar to a candle, the candle time is the beginning of the period
+// (TDX bars are labeled with the close time) and daily candles start at midnight, Beijing time
+func tdxCandle(cal *calendar.Calendar, pair string, bar tdx_local.Bar, barSize uint) model.Candle {
+	local := bar.Time().In(tdxLocation)
+	start := local.Add(-time.Duration(barSize) * time.Minute)
+	switch {
+	case barSize >= 24*60:
+		start = time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, tdxLocation)
+	case !cal.IsOpen(start):
+		// some files label the first minute of the afternoon session as 13:00
+		start = cal.NextOpen(start)
+	}
+
+	return model.Candle{
+		Pair:      pair,
+		Time:      start.UTC(),
+		UpdatedAt: start.UTC(),
+		Open:      tdxPrice(bar.Open()),
+		High:      tdxPrice(bar.High()),
+		Low:       tdxPrice(bar.Low()),
+		Close:     tdxPrice(bar.Close()),
+		Volume:    float64(bar.Volume()),
+		Complete:  true,
+		Metadata:  map[string]float64{"turnover": float64(bar.Turnover())},
+	}
+}
+
+// AssetsInfo returns the trading limits of A-shares, orders are placed in board lots of 100 shares
+func (t TDXFeed) AssetsInfo(pair string) model.AssetInfo {
+	asset, quote := SplitAssetQuote(pair)
+	return model.AssetInfo{
+		BaseAsset:          asset,
+		QuoteAsset:         quote,
+		MinPrice:           0.01,
+		MaxPrice:           math.MaxFloat64,
+		MinQuantity:        100,
+		MaxQuantity:        math.MaxFloat64,
+		StepSize:           100,
+		TickSize:           0.01,
+		QuotePrecision:     2,
+		BaseAssetPrecision: 0,
+	}
+}
@@ -0,0 +1,90 @@
+package exchange
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// vipdoc creates a TDX directory tree with the given data files from tdx_local testdata
+func vipdoc(t *testing.T, files ...string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for _, file := range files {
+		content, err := os.ReadFile(filepath.Join("tdx_local/testdata", filepath.Base(file)))
+		require.NoError(t, err)
+
+		target := filepath.Join(dir, file)
+		require.NoError(t, os.MkdirAll(filepath.Dir(target), 0755))
+		require.NoError(t, os.WriteFile(target, content, 0644))
+	}
+	return dir
+}
+
+func TestNewTDXFeed(t *testing.T) {
+	t.Run("resample minutes", func(t *testing.T) {
+		feed, err := NewTDXFeed(vipdoc(t, "sh/minline/sh600104.lc1"), "1h", "sh600104")
+		require.NoError(t, err)
+		require.Equal(t, "1m", feed.Feeds["SH600104CNY"].Timeframe)
+
+		var starts []string
+		for _, candle := range feed.CandlePairTimeFrame["SH600104CNY--1h"] {
+			if candle.Complete {
+				starts = append(starts, candle.Time.In(tdxLocation).Format("15:04"))
+			}
+		}
+		require.Equal(t, []string{"09:30", "10:30", "13:00", "14:00"}, starts)
+
+		asset, quote := SplitAssetQuote("SH600104CNY")
+		require.Equal(t, "SH600104", asset)
+		require.Equal(t, "CNY", quote)
+	})
+
+	t.Run("resample to 5m aligned with lc5", func(t *testing.T) {
+		feed, err := NewTDXFeed(vipdoc(t, "sh/minline/sh600104.lc1"), "5m", "sh600104")
+		require.NoError(t, err)
+
+		expected, err := NewTDXFeed(vipdoc(t, "sh/fzline/sh600104.lc5"), "5m", "sh600104")
+		require.NoError(t, err)
+		require.Equal(t, "5m", expected.Feeds["SH600104CNY"].Timeframe)
+
+		var complete int
+		for _, candle := range feed.CandlePairTimeFrame["SH600104CNY--5m"] {
+			if !candle.Complete {
+				continue
+			}
+
+			other := expected.CandlePairTimeFrame["SH600104CNY--5m"][complete]
+			require.Equal(t, other.Time, candle.Time)
+			complete++
+		}
+		require.Equal(t, 48, complete)
+	})
+
+	t.Run("daily data", func(t *testing.T) {
+		feed, err := NewTDXFeed(vipdoc(t, "sh/lday/sh600104.day", "sh/minline/sh600104.lc1"), "1d", "sh600104")
+		require.NoError(t, err)
+		require.Equal(t, "1d", feed.Feeds["SH600104CNY"].Timeframe)
+
+		candles, err := feed.CandlesByPeriod(context.Background(), "SH600104CNY", "1d",
+			feed.CandlePairTimeFrame["SH600104CNY--1d"][0].Time,
+			feed.CandlePairTimeFrame["SH600104CNY--1d"][0].Time)
+		require.NoError(t, err)
+		require.Len(t, candles, 1)
+		require.Equal(t, "2017-06-30 00:00", candles[0].Time.In(tdxLocation).Format("2006-01-02 15:04"))
+		require.Equal(t, 30.6, candles[0].Open)
+		require.Equal(t, 31.11, candles[0].High)
+		require.Equal(t, 30.5, candles[0].Low)
+		require.Equal(t, 31.05, candles[0].Close)
+		require.Equal(t, 20422074.0, candles[0].Volume)
+	})
+
+	t.Run("missing data", func(t *testing.T) {
+		_, err := NewTDXFeed(vipdoc(t, "sh/lday/sh600104.day"), "5m", "sh600104")
+		require.ErrorIs(t, err, ErrInsufficientData)
+	})
+}
@@ -0,0 +1,238 @@
+package exchange
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ezquant/azbot/azbot/model"
+	"github.com/ezquant/azbot/azbot/tools/log"
+)
+
+// Adjustment is the method used to adjust prices for corporate actions
+type Adjustment string
+
+const (
+	// AdjustNone keeps raw prices, as stored by TDX
+	AdjustNone Adjustment = "none"
+	// AdjustForward (前复权) keeps the latest prices and adjusts the history before each ex-date
+	AdjustForward Adjustment = "forward"
+	// AdjustBackward (后复权) keeps the first prices and adjusts the history from each ex-date
+	AdjustBackward Adjustment = "backward"
+)
+
+// CorporateAction is an ex-rights/ex-dividend (除权除息) event of a security, amounts are per share
+type CorporateAction struct {
+	Pair        string
+	Date        time.Time // ex-date, beginning of the day in Beijing time
+	Cash        float64   // cash dividend
+	Bonus       float64   // bonus and transferred shares
+	Rights      float64   // rights issue shares
+	RightsPrice float64   // subscription price of the rights issue
+}
+
+// ratio returns the adjustment factor of prices before the ex-date given the previous close
+func (a CorporateAction) ratio(prevClose float64) float64 {
+	exPrice := (prevClose - a.Cash + a.RightsPrice*a.Rights) / (1 + a.Bonus + a.Rights)
+	return exPrice / prevClose
+}
+
+// LoadCorporateActions reads ex-rights/ex-dividend events from a CSV file with the columns of TDX gbbq data,
+// as exported by pytdx: symbol, date, category, fenhong, peigujia, songzhuangu and peigu. Amounts are per 10
+// shares and only rows of category 1 (除权除息) are loaded, the category column is optional. Dates are in the
+// format 2006-01-02 or 20060102 and symbols are TDX file names, eg: sh600104.
+//
+// The gbbq file of a TDX client is encrypted, it must be exported to CSV before loading.
+func LoadCorporateActions(file string) ([]CorporateAction, error) {
+	csvFile, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer csvFile.Close()
+
+	lines, err := csv.NewReader(csvFile).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("%w: empty file %s", ErrInsufficientData, file)
+	}
+
+	headers := make(map[string]int)
+	for i, header := range lines[0] {
+		headers[strings.ToLower(strings.TrimSpace(header))] = i
+	}
+
+	for _, header := range []string{"symbol", "date"} {
+		if _, ok := headers[header]; !ok {
+			return nil, fmt.Errorf("missing column %s in %s", header, file)
+		}
+	}
+
+	value := func(line []string, header string) (float64, error) {
+		index, ok := headers[header]
+		if !ok || strings.TrimSpace(line[index]) == "" {
+			return 0, nil
+		}
+		return strconv.ParseFloat(strings.TrimSpace(line[index]), 64)
+	}
+
+	actions := make([]CorporateAction, 0, len(lines)-1)
+	for _, line := range lines[1:] {
+		category, err := value(line, "category")
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := headers["category"]; ok && category != 1 {
+			continue
+		}
+
+		date, err := parseActionDate(line[headers["date"]])
+		if err != nil {
+			return nil, err
+		}
+
+		symbol := strings.TrimSpace(line[headers["symbol"]])
+		action := CorporateAction{
+			Pair: TDXPair(symbol),
+			Date: date,
+		}
+		RegisterPair(action.Pair, strings.ToUpper(symbol), TDXQuote)
+
+		fields := map[string]*float64{
+			"fenhong":     &action.Cash,
+			"songzhuangu": &action.Bonus,
+			"peigu":       &action.Rights,
+			"peigujia":    &action.RightsPrice,
+		}
+		for header, field := range fields {
+			*field, err = value(line, header)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// amounts of gbbq data are per 10 shares, except the subscription price
+		action.Cash /= 10
+		action.Bonus /= 10
+		action.Rights /= 10
+
+		actions = append(actions, action)
+	}
+
+	sort.SliceStable(actions, func(i, j int) bool {
+		return actions[i].Date.Before(actions[j].Date)
+	})
+
+	return actions, nil
+}
+
+func parseActionDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{time.DateOnly, "20060102"} {
+		date, err := time.ParseInLocation(layout, value, tdxLocation)
+		if err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date: %s", value)
+}
+
+// AdjustCandles returns a copy of the candles with prices adjusted for the corporate actions of the pair.
+// The adjustment factor of each action is given by the ex-rights price of the last close before the ex-date.
+// Volume is kept as traded.
+func AdjustCandles(candles []model.Candle, actions []CorporateAction, mode Adjustment) []model.Candle {
+	adjusted := make([]model.Candle, len(candles))
+	copy(adjusted, candles)
+	if mode == AdjustNone || len(candles) == 0 {
+		return adjusted
+	}
+
+	pair := candles[0].Pair
+	for _, action := range actions {
+		if action.Pair != pair {
+			continue
+		}
+
+		exIndex := sort.Search(len(candles), func(i int) bool {
+			return !candles[i].Time.Before(action.Date)
+		})
+		if exIndex == 0 || exIndex == len(candles) {
+			continue
+		}
+
+		ratio := action.ratio(candles[exIndex-1].Close)
+		start, end := 0, exIndex
+		if mode == AdjustBackward {
+			start, end, ratio = exIndex, len(candles), 1/ratio
+		}
+
+		for i := start; i < end; i++ {
+			adjusted[i].Open *= ratio
+			adjusted[i].High *= ratio
+			adjusted[i].Low *= ratio
+			adjusted[i].Close *= ratio
+		}
+	}
+
+	return adjusted
+}
+
+// Adjust adjusts the prices of all timeframes of the feed for corporate actions.
+// Use raw prices when dividends are credited by the paper wallet, see WithPaperCorporateActions.
+func (t *TDXFeed) Adjust(actions []CorporateAction, mode Adjustment) {
+	for key, candles := range t.CandlePairTimeFrame {
+		t.CandlePairTimeFrame[key] = AdjustCandles(candles, actions, mode)
+	}
+}
+
+// WithPaperCorporateActions credits cash dividends and bonus shares to long positions on the ex-date.
+// Rights issues must be subscribed by the strategy and are not applied.
+func WithPaperCorporateActions(actions []CorporateAction) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.actions = make(map[string][]CorporateAction)
+		for _, action := range actions {
+			wallet.actions[action.Pair] = append(wallet.actions[action.Pair], action)
+		}
+
+		for pair := range wallet.actions {
+			sort.SliceStable(wallet.actions[pair], func(i, j int) bool {
+				return wallet.actions[pair][i].Date.Before(wallet.actions[pair][j].Date)
+			})
+		}
+	}
+}
+
+// applyCorporateActions applies actions with ex-date reached by the candle
+func (p *PaperWallet) applyCorporateActions(candle model.Candle) {
+	actions := p.actions[candle.Pair]
+	for len(actions) > 0 && !candle.Time.Before(actions[0].Date) {
+		action := actions[0]
+		actions = actions[1:]
+
+		asset, quote := SplitAssetQuote(candle.Pair)
+		info, ok := p.assets[asset]
+		if !ok || info.Free+info.Lock <= 0 {
+			continue
+		}
+
+		if _, ok := p.assets[quote]; !ok {
+			p.assets[quote] = &assetInfo{}
+		}
+
+		shares := info.Free + info.Lock
+		p.assets[quote].Free += action.Cash * shares
+		info.Free += action.Bonus * shares
+		p.avgLongPrice[candle.Pair] = (p.avgLongPrice[candle.Pair] - action.Cash) / (1 + action.Bonus)
+
+		log.Infof("[CORPORATE ACTION] %s %s: dividend = %.2f %s, bonus = %.2f %s",
+			candle.Pair, action.Date.Format(time.DateOnly), action.Cash*shares, quote, action.Bonus*shares, asset)
+	}
+	p.actions[candle.Pair] = actions
+}
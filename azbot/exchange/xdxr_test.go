@@ -0,0 +1,91 @@
+package exchange
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ezquant/azbot/azbot/model"
+)
+
+func TestLoadCorporateActions(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "gbbq.csv")
+	content := "symbol,date,category,fenhong,peigujia,songzhuangu,peigu\n" +
+		"sh600104,2021-07-01,1,10,0,5,0\n" +
+		"sh600104,20210801,5,0,0,0,0\n" +
+		"sz000001,20210601,1,2,8,0,3\n"
+	require.NoError(t, os.WriteFile(file, []byte(content), 0644))
+
+	actions, err := LoadCorporateActions(file)
+	require.NoError(t, err)
+	require.Equal(t, []CorporateAction{
+		{
+			Pair: "SZ000001CNY", Date: time.Date(2021, 6, 1, 0, 0, 0, 0, tdxLocation),
+			Cash: 0.2, Rights: 0.3, RightsPrice: 8,
+		},
+		{
+			Pair: "SH600104CNY", Date: time.Date(2021, 7, 1, 0, 0, 0, 0, tdxLocation),
+			Cash: 1, Bonus: 0.5,
+		},
+	}, actions)
+
+	t.Run("missing column", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "gbbq.csv")
+		require.NoError(t, os.WriteFile(file, []byte("date,fenhong\n2021-07-01,10\n"), 0644))
+		_, err := LoadCorporateActions(file)
+		require.Error(t, err)
+	})
+}
+
+func TestAdjustCandles(t *testing.T) {
+	day := time.Date(2021, 6, 30, 0, 0, 0, 0, tdxLocation)
+	candles := []model.Candle{
+		{Pair: "SH600104CNY", Time: day.AddDate(0, 0, -1), Open: 10, High: 10, Low: 10, Close: 10},
+		{Pair: "SH600104CNY", Time: day, Open: 10, High: 11, Low: 9, Close: 10},
+		{Pair: "SH600104CNY", Time: day.AddDate(0, 0, 1), Open: 6, High: 6, Low: 6, Close: 6},
+	}
+	actions := []CorporateAction{
+		{Pair: "SH600104CNY", Date: day.AddDate(0, 0, 1), Cash: 1, Bonus: 0.5},
+		{Pair: "SZ000001CNY", Date: day, Cash: 1},
+	}
+
+	require.Equal(t, candles, AdjustCandles(candles, actions, AdjustNone))
+
+	forward := AdjustCandles(candles, actions, AdjustForward)
+	require.InDelta(t, 6, forward[0].Close, 1e-9)
+	require.InDelta(t, 6.6, forward[1].High, 1e-9)
+	require.InDelta(t, 5.4, forward[1].Low, 1e-9)
+	require.Equal(t, 6.0, forward[2].Close)
+	require.Equal(t, 10.0, candles[0].Close)
+
+	backward := AdjustCandles(candles, actions, AdjustBackward)
+	require.Equal(t, 10.0, backward[0].Close)
+	require.Equal(t, 11.0, backward[1].High)
+	require.InDelta(t, 10, backward[2].Close, 1e-9)
+}
+
+func TestPaperWallet_CorporateActions(t *testing.T) {
+	RegisterPair("SH600104CNY", "SH600104", "CNY")
+	day := time.Date(2021, 7, 1, 0, 0, 0, 0, tdxLocation)
+	wallet := NewPaperWallet(context.Background(), "CNY", WithPaperAsset("CNY", 0),
+		WithPaperAsset("SH600104", 100), WithPaperCorporateActions([]CorporateAction{
+			{Pair: "SH600104CNY", Date: day, Cash: 1, Bonus: 0.5},
+		}))
+
+	wallet.OnCandle(model.Candle{Pair: "SH600104CNY", Time: day.AddDate(0, 0, -1), Close: 10})
+	require.Equal(t, 0.0, wallet.assets["CNY"].Free)
+	require.Equal(t, 100.0, wallet.assets["SH600104"].Free)
+
+	wallet.OnCandle(model.Candle{Pair: "SH600104CNY", Time: day.Add(10 * time.Hour), Close: 6})
+	require.Equal(t, 100.0, wallet.assets["CNY"].Free)
+	require.Equal(t, 150.0, wallet.assets["SH600104"].Free)
+
+	// actions are applied once
+	wallet.OnCandle(model.Candle{Pair: "SH600104CNY", Time: day.AddDate(0, 0, 1), Close: 6})
+	require.Equal(t, 100.0, wallet.assets["CNY"].Free)
+	require.Equal(t, 150.0, wallet.assets["SH600104"].Free)
+}
@@ -0,0 +1,326 @@
+// Package history inspects and repairs historical candles stored in data files, eg: the CSV files of the
+// download command or the vipdoc files of TongDaXin (通达信).
+package history
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xhit/go-str2duration/v2"
+
+	"github.com/ezquant/azbot/azbot/calendar"
+	"github.com/ezquant/azbot/azbot/exchange"
+	"github.com/ezquant/azbot/azbot/exchange/tdx_local"
+	"github.com/ezquant/azbot/azbot/model"
+)
+
+// Format is the encoding of a data file, detected by its extension
+type Format string
+
+const (
+	// FormatCSV is the format of the download command, with optional headers and metadata columns
+	FormatCSV Format = "csv"
+	// FormatTDX are the binary files of TongDaXin: .day (1d), .lc5 and .5 (5m) and .lc1 (1m)
+	FormatTDX Format = "tdx"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported format")
+
+// FormatOf returns the format of a file by its extension
+func FormatOf(file string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".day", ".lc5", ".5", ".lc1":
+		return FormatTDX, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, file)
+}
+
+// Series are the candles of a pair in a timeframe, in the order of the data file
+type Series struct {
+	Pair      string
+	Timeframe string
+	Candles   []model.Candle
+	// Calendar skips the closed sessions when looking for gaps, time is continuous when nil
+	Calendar *calendar.Calendar
+}
+
+type Option func(*Series)
+
+// WithPair sets the pair of CSV files, the name of the file without extension by default
+func WithPair(pair string) Option {
+	return func(s *Series) {
+		s.Pair = pair
+	}
+}
+
+// WithTimeframe sets the timeframe of CSV files, detected from the interval between candles by default
+func WithTimeframe(timeframe string) Option {
+	return func(s *Series) {
+		s.Timeframe = timeframe
+	}
+}
+
+// WithCalendar sets the trading sessions of the candles, TDX files use calendar.AShare by default
+func WithCalendar(cal *calendar.Calendar) Option {
+	return func(s *Series) {
+		s.Calendar = cal
+	}
+}
+
+// Load reads the candles of a CSV or TDX file
+func Load(file string, options ...Option) (*Series, error) {
+	format, err := FormatOf(file)
+	if err != nil {
+		return nil, err
+	}
+
+	series := &Series{}
+	for _, option := range options {
+		option(series)
+	}
+
+	var feed exchange.PairFeed
+	switch format {
+	case FormatTDX:
+		feed, series.Candles, err = exchange.ReadTDXCandles(file)
+		if err != nil {
+			return nil, err
+		}
+
+		series.Timeframe = feed.Timeframe
+		if series.Calendar == nil {
+			series.Calendar = calendar.AShare()
+		}
+	default:
+		feed.Pair = series.Pair
+		if feed.Pair == "" {
+			feed.Pair = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		}
+
+		feed.File = file
+		series.Candles, err = exchange.ReadCSVCandles(feed)
+		if err != nil {
+			return nil, err
+		}
+	}
+	series.Pair = feed.Pair
+
+	if series.Timeframe == "" {
+		series.Timeframe, err = detectTimeframe(series.Candles)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+
+	if _, err := series.interval(); err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+// detectTimeframe returns the most frequent interval between consecutive candles
+func detectTimeframe(candles []model.Candle) (string, error) {
+	count := make(map[time.Duration]int)
+	var interval time.Duration
+	for i := 1; i < len(candles); i++ {
+		diff := candles[i].Time.Sub(candles[i-1].Time)
+		if diff <= 0 {
+			continue
+		}
+
+		count[diff]++
+		if count[diff] > count[interval] || count[diff] == count[interval] && diff < interval {
+			interval = diff
+		}
+	}
+
+	if interval == 0 {
+		return "", errors.New("cannot detect the timeframe, at least two candles are required")
+	}
+	return formatTimeframe(interval), nil
+}
+
+// formatTimeframe returns the timeframe of a duration in the largest unit that divides it, eg: 1h, 1d or 1w
+func formatTimeframe(interval time.Duration) string {
+	week := 7 * 24 * time.Hour
+	switch {
+	case interval%week == 0:
+		return fmt.Sprintf("%dw", interval/week)
+	case interval%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", interval/(24*time.Hour))
+	case interval%time.Hour == 0:
+		return fmt.Sprintf("%dh", interval/time.Hour)
+	case interval%time.Minute == 0:
+		return fmt.Sprintf("%dm", interval/time.Minute)
+	}
+	return fmt.Sprintf("%ds", interval/time.Second)
+}
+
+func (s Series) interval() (time.Duration, error) {
+	interval, err := str2duration.ParseDuration(s.Timeframe)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeframe %s: %w", s.Timeframe, err)
+	}
+	if interval <= 0 {
+		return 0, fmt.Errorf("invalid timeframe: %s", s.Timeframe)
+	}
+	return interval, nil
+}
+
+// next returns the time of the candle after t, skipping the closed sessions of the calendar
+func (s Series) next(t time.Time, interval time.Duration) time.Time {
+	switch {
+	case s.Calendar == nil || interval >= 7*24*time.Hour:
+		return t.Add(interval)
+	case interval >= 24*time.Hour:
+		next := t.AddDate(0, 0, 1)
+		// holidays lists are finite, the loop ends in the first trading day after them
+		for !s.Calendar.IsTradingDay(next) {
+			next = next.AddDate(0, 0, 1)
+		}
+		return next
+	default:
+		return s.Calendar.NextOpen(t.Add(interval))
+	}
+}
+
+// sort orders the candles by time, of the candles with the same time only the last one is kept
+func (s *Series) sort() {
+	sort.SliceStable(s.Candles, func(i, j int) bool {
+		return s.Candles[i].Time.Before(s.Candles[j].Time)
+	})
+
+	candles := s.Candles[:0]
+	for _, candle := range s.Candles {
+		if last := len(candles) - 1; last >= 0 && candles[last].Time.Equal(candle.Time) {
+			candles[last] = candle
+			continue
+		}
+		candles = append(candles, candle)
+	}
+	s.Candles = candles
+}
+
+// Save writes the candles in the format of the file extension. TDX files must match the timeframe
+// of their extension and are named after the symbol, eg: sh600104.day.
+func (s Series) Save(file string) error {
+	format, err := FormatOf(file)
+	if err != nil {
+		return err
+	}
+
+	if format == FormatTDX {
+		return s.saveTDX(file)
+	}
+	return s.saveCSV(file)
+}
+
+func (s Series) saveCSV(file string) error {
+	var metadata []string
+	for _, candle := range s.Candles {
+		for key := range candle.Metadata {
+			if !contains(metadata, key) {
+				metadata = append(metadata, key)
+			}
+		}
+	}
+	sort.Strings(metadata)
+
+	output, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	writer := csv.NewWriter(output)
+	err = writer.Write(append([]string{"time", "open", "close", "low", "high", "volume"}, metadata...))
+	if err != nil {
+		return err
+	}
+
+	for _, candle := range s.Candles {
+		record := []string{
+			strconv.FormatInt(candle.Time.Unix(), 10),
+			formatFloat(candle.Open),
+			formatFloat(candle.Close),
+			formatFloat(candle.Low),
+			formatFloat(candle.High),
+			formatFloat(candle.Volume),
+		}
+		for _, key := range metadata {
+			record = append(record, formatFloat(candle.Metadata[key]))
+		}
+
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return output.Close()
+}
+
+func (s Series) saveTDX(file string) error {
+	interval, err := s.interval()
+	if err != nil {
+		return err
+	}
+
+	dataset := &tdx_local.Dataset{BarSize: uint(interval / time.Minute)}
+	for _, candle := range s.Candles {
+		if candle.Volume < 0 || candle.Volume > math.MaxUint32 {
+			return fmt.Errorf("cannot encode the volume %v of %s in %s", candle.Volume, candle.Time, file)
+		}
+		dataset.Bars = append(dataset.Bars, tdxBar{candle: candle, interval: interval})
+	}
+	return tdx_local.EncodeFile(file, dataset)
+}
+
+// tdxBar is a candle as a TDX bar, labeled with the close time: 15:00 for daily bars and the end of the
+// period for intraday bars, Beijing time
+type tdxBar struct {
+	candle   model.Candle
+	interval time.Duration
+}
+
+func (b tdxBar) Time() time.Time {
+	local := b.candle.Time.In(calendar.Shanghai)
+	if b.interval >= 24*time.Hour {
+		return time.Date(local.Year(), local.Month(), local.Day(), 15, 0, 0, 0, calendar.Shanghai)
+	}
+	return local.Add(b.interval)
+}
+
+func (b tdxBar) Open() float32     { return float32(b.candle.Open) }
+func (b tdxBar) High() float32     { return float32(b.candle.High) }
+func (b tdxBar) Low() float32      { return float32(b.candle.Low) }
+func (b tdxBar) Close() float32    { return float32(b.candle.Close) }
+func (b tdxBar) Volume() uint32    { return uint32(math.Round(b.candle.Volume)) }
+func (b tdxBar) Turnover() float32 { return float32(b.candle.Metadata["turnover"]) }
+
+// formatFloat rounds to the precision of the CSV feed, removing the noise of sums, eg: resampled volumes
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(math.Round(value*1e8)/1e8, 'f', -1, 64)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,243 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ezquant/azbot/azbot/calendar"
+	"github.com/ezquant/azbot/azbot/model"
+)
+
+var start = time.Date(2021, 5, 13, 0, 0, 0, 0, time.UTC)
+
+// hourly returns candles at the given hours after start, with the price of the hour
+func hourly(hours ...int) []model.Candle {
+	var candles []model.Candle
+	for _, hour := range hours {
+		price := float64(100 + hour)
+		candles = append(candles, model.Candle{
+			Pair:      "BTCUSDT",
+			Time:      start.Add(time.Duration(hour) * time.Hour),
+			UpdatedAt: start.Add(time.Duration(hour) * time.Hour),
+			Open:      price,
+			High:      price + 1,
+			Low:       price - 1,
+			Close:     price + 0.5,
+			Volume:    10,
+			Complete:  true,
+		})
+	}
+	return candles
+}
+
+func TestLoad(t *testing.T) {
+	series, err := Load("../../testdata/btc-1d-header.csv", WithPair("BTCUSDT"))
+	require.NoError(t, err)
+	require.Equal(t, "BTCUSDT", series.Pair)
+	require.Equal(t, "1d", series.Timeframe)
+	require.Equal(t, 1.1, series.Candles[0].Metadata["lsr"])
+
+	series, err = Load("../../testdata/btc-1h.csv")
+	require.NoError(t, err)
+	require.Equal(t, "btc-1h", series.Pair)
+	require.Equal(t, "1h", series.Timeframe)
+
+	series, err = Load("../exchange/tdx_local/testdata/sh600104.lc5")
+	require.NoError(t, err)
+	require.Equal(t, "SH600104CNY", series.Pair)
+	require.Equal(t, "5m", series.Timeframe)
+	require.NotNil(t, series.Calendar)
+
+	_, err = Load("../../testdata/btc-1h.json")
+	require.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestSeries_Info(t *testing.T) {
+	series := Series{Pair: "BTCUSDT", Timeframe: "1h", Candles: hourly(0, 1, 4, 4, 3, 5, 9)}
+
+	info, err := series.Info()
+	require.NoError(t, err)
+	require.Equal(t, 7, info.Count)
+	require.Equal(t, start, info.Start)
+	require.Equal(t, start.Add(9*time.Hour), info.End)
+	require.Equal(t, 1, info.Duplicates)
+	require.Equal(t, 1, info.Unordered)
+	require.Equal(t, 4, info.Missing)
+	require.Equal(t, []Gap{
+		{After: start.Add(time.Hour), Before: start.Add(3 * time.Hour), Missing: 1},
+		{After: start.Add(5 * time.Hour), Before: start.Add(9 * time.Hour), Missing: 3},
+	}, info.Gaps)
+
+	t.Run("calendar", func(t *testing.T) {
+		// Friday, Monday and Wednesday
+		var candles []model.Candle
+		for _, day := range []int{5, 8, 10} {
+			date := time.Date(2024, 1, day, 0, 0, 0, 0, calendar.Shanghai).UTC()
+			candles = append(candles, model.Candle{Time: date, Open: 1, High: 1, Low: 1, Close: 1, Volume: 1})
+		}
+
+		series := Series{Timeframe: "1d", Candles: candles, Calendar: calendar.AShare()}
+		info, err := series.Info()
+		require.NoError(t, err)
+		require.Equal(t, 1, info.Missing, "weekends are not gaps")
+
+		series.Calendar = nil
+		info, err = series.Info()
+		require.NoError(t, err)
+		require.Equal(t, 3, info.Missing)
+	})
+}
+
+func TestSeries_Validate(t *testing.T) {
+	candles := hourly(0, 1, 1, 0, 2, 3, 4, 5)
+	candles[4].High = 90
+	candles[5].Low = 200
+	candles[6].Volume = 0
+	candles[7].Close = -1
+
+	series := Series{Timeframe: "1h", Candles: candles}
+	var kinds []IssueKind
+	for _, issue := range series.Validate() {
+		kinds = append(kinds, issue.Kind)
+	}
+	require.Equal(t, []IssueKind{
+		IssueDuplicate, IssueUnordered, IssueOHLC, IssueOHLC, IssueZeroVolume, IssueInvalidValue,
+	}, kinds)
+
+	require.Empty(t, Series{Timeframe: "1h", Candles: hourly(0, 1, 5)}.Validate())
+}
+
+func TestSeries_Fill(t *testing.T) {
+	t.Run("previous", func(t *testing.T) {
+		series := Series{Timeframe: "1h", Candles: hourly(3, 0, 0, 1)}
+		series.Candles[2].Close = 99
+
+		inserted, err := series.Fill(FillPrevious, 0)
+		require.NoError(t, err)
+		require.Equal(t, 1, inserted)
+		require.Len(t, series.Candles, 4)
+		require.Equal(t, 99.0, series.Candles[0].Close, "last duplicate is kept")
+		require.Equal(t, start.Add(2*time.Hour), series.Candles[2].Time)
+		require.Equal(t, 101.5, series.Candles[2].Open)
+		require.Equal(t, 101.5, series.Candles[2].Close)
+		require.Zero(t, series.Candles[2].Volume)
+	})
+
+	t.Run("linear", func(t *testing.T) {
+		series := Series{Timeframe: "1h", Candles: hourly(0, 4)}
+		series.Candles[0].Close = 100
+		series.Candles[1].Open = 104
+
+		inserted, err := series.Fill(FillLinear, 0)
+		require.NoError(t, err)
+		require.Equal(t, 3, inserted)
+
+		var closes []float64
+		for _, candle := range series.Candles[1:4] {
+			closes = append(closes, candle.Close)
+		}
+		require.Equal(t, []float64{101, 102, 103}, closes)
+		require.Equal(t, 101.0, series.Candles[2].Open)
+		require.Equal(t, 102.0, series.Candles[2].High)
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		series := Series{Timeframe: "1h", Candles: hourly(0, 2, 6)}
+		inserted, err := series.Fill(FillNext, 2)
+		require.NoError(t, err)
+		require.Equal(t, 1, inserted)
+		require.Len(t, series.Candles, 4)
+		require.Equal(t, 102.0, series.Candles[1].Close)
+	})
+
+	t.Run("invalid policy", func(t *testing.T) {
+		series := Series{Timeframe: "1h", Candles: hourly(0, 2)}
+		_, err := series.Fill("zero", 0)
+		require.Error(t, err)
+	})
+}
+
+func TestSeries_Resample(t *testing.T) {
+	series := Series{Pair: "BTCUSDT", Timeframe: "1h", Candles: hourly(1, 0, 2, 3, 4)}
+
+	resampled, err := series.Resample("2h")
+	require.NoError(t, err)
+	require.Equal(t, "2h", resampled.Timeframe)
+	require.Len(t, resampled.Candles, 2)
+	require.Equal(t, start, resampled.Candles[0].Time)
+	require.Equal(t, 100.0, resampled.Candles[0].Open)
+	require.Equal(t, 101.5, resampled.Candles[0].Close)
+	require.Equal(t, 20.0, resampled.Candles[0].Volume)
+
+	_, err = series.Resample("30m")
+	require.Error(t, err)
+	_, err = series.Resample("90m")
+	require.Error(t, err)
+}
+
+func TestMerge(t *testing.T) {
+	first := &Series{Pair: "BTCUSDT", Timeframe: "1h", Candles: hourly(0, 1, 2, 3)}
+	second := &Series{Pair: "BTCUSDT", Timeframe: "1h", Candles: hourly(2, 3, 4)}
+	second.Candles[0].Close = 99
+
+	merged, overlaps, err := Merge(first, second)
+	require.NoError(t, err)
+	require.Equal(t, 2, overlaps)
+	require.Len(t, merged.Candles, 5)
+	require.Equal(t, 99.0, merged.Candles[2].Close)
+
+	_, _, err = Merge(first, &Series{Timeframe: "1d"})
+	require.Error(t, err)
+}
+
+func TestSeries_Save(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("csv", func(t *testing.T) {
+		series, err := Load("../../testdata/btc-1d-header.csv", WithPair("BTCUSDT"))
+		require.NoError(t, err)
+
+		file := filepath.Join(dir, "btc.csv")
+		require.NoError(t, series.Save(file))
+
+		saved, err := Load(file, WithPair("BTCUSDT"))
+		require.NoError(t, err)
+		require.Equal(t, series.Candles, saved.Candles)
+
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		require.Contains(t, string(data), "time,open,close,low,high,volume,lsr,trades\n")
+	})
+
+	t.Run("tdx", func(t *testing.T) {
+		for _, name := range []string{"sh600104.day", "sh600104.lc5", "sh600104.lc1"} {
+			series, err := Load(filepath.Join("../exchange/tdx_local/testdata", name))
+			require.NoError(t, err)
+
+			csvFile := filepath.Join(dir, name+".csv")
+			require.NoError(t, series.Save(csvFile))
+			converted, err := Load(csvFile, WithTimeframe(series.Timeframe))
+			require.NoError(t, err)
+
+			tdxFile := filepath.Join(dir, name)
+			require.NoError(t, converted.Save(tdxFile))
+			saved, err := Load(tdxFile)
+			require.NoError(t, err)
+
+			require.Len(t, saved.Candles, len(series.Candles))
+			for i, candle := range series.Candles {
+				require.Equal(t, candle.Time, saved.Candles[i].Time, name)
+				require.Equal(t, candle.Close, saved.Candles[i].Close, name)
+				require.Equal(t, candle.Volume, saved.Candles[i].Volume, name)
+			}
+		}
+
+		series, err := Load("../exchange/tdx_local/testdata/sh600104.day")
+		require.NoError(t, err)
+		require.Error(t, series.Save(filepath.Join(dir, "sh600104.lc1")), "timeframe of the extension")
+	})
+}
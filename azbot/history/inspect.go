@@ -0,0 +1,165 @@
+package history
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Gap is an interval without the expected candles between two consecutive candles
+type Gap struct {
+	// After and Before are the times of the candles around the gap
+	After   time.Time
+	Before  time.Time
+	Missing int
+}
+
+// Info summarizes the candles of a series
+type Info struct {
+	Pair       string
+	Timeframe  string
+	Start      time.Time
+	End        time.Time
+	Count      int
+	Missing    int
+	Gaps       []Gap
+	Duplicates int
+	// Unordered is the number of candles older than the previous one in the file
+	Unordered int
+}
+
+// Info returns the range, the gaps and the duplicated candles of the series. Gaps skip the closed sessions
+// of the calendar, eg: weekends of A-shares are not gaps.
+func (s Series) Info() (Info, error) {
+	info := Info{
+		Pair:      s.Pair,
+		Timeframe: s.Timeframe,
+		Count:     len(s.Candles),
+	}
+
+	for i := 1; i < len(s.Candles); i++ {
+		switch {
+		case s.Candles[i].Time.Equal(s.Candles[i-1].Time):
+			info.Duplicates++
+		case s.Candles[i].Time.Before(s.Candles[i-1].Time):
+			info.Unordered++
+		}
+	}
+
+	sorted := Series{Pair: s.Pair, Timeframe: s.Timeframe, Calendar: s.Calendar}
+	sorted.Candles = append(sorted.Candles, s.Candles...)
+	sorted.sort()
+	if len(sorted.Candles) == 0 {
+		return info, nil
+	}
+
+	info.Start = sorted.Candles[0].Time
+	info.End = sorted.Candles[len(sorted.Candles)-1].Time
+
+	gaps, err := sorted.gaps()
+	if err != nil {
+		return info, err
+	}
+
+	info.Gaps = gaps
+	for _, gap := range gaps {
+		info.Missing += gap.Missing
+	}
+	return info, nil
+}
+
+// gaps returns the gaps between the candles, they must be sorted
+func (s Series) gaps() ([]Gap, error) {
+	interval, err := s.interval()
+	if err != nil {
+		return nil, err
+	}
+
+	var gaps []Gap
+	for i := 1; i < len(s.Candles); i++ {
+		gap := Gap{After: s.Candles[i-1].Time, Before: s.Candles[i].Time}
+		for t := s.next(gap.After, interval); t.Before(gap.Before); t = s.next(t, interval) {
+			gap.Missing++
+		}
+
+		if gap.Missing > 0 {
+			gaps = append(gaps, gap)
+		}
+	}
+	return gaps, nil
+}
+
+// IssueKind classifies the issues found by Validate
+type IssueKind string
+
+const (
+	IssueInvalidValue IssueKind = "invalid-value"
+	IssueOHLC         IssueKind = "ohlc"
+	IssueZeroVolume   IssueKind = "zero-volume"
+	IssueDuplicate    IssueKind = "duplicate"
+	IssueUnordered    IssueKind = "unordered"
+)
+
+// Issue is an inconsistency of a candle
+type Issue struct {
+	Index   int
+	Time    time.Time
+	Kind    IssueKind
+	Message string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("#%d %s [%s] %s", i.Index, i.Time.Format(time.RFC3339), i.Kind, i.Message)
+}
+
+// Validate returns the candles with invalid prices, high and low that don't contain open and close,
+// zero or negative volume, and times that are duplicated or out of order
+func (s Series) Validate() []Issue {
+	var issues []Issue
+	for i, candle := range s.Candles {
+		issue := func(kind IssueKind, format string, args ...interface{}) {
+			issues = append(issues, Issue{Index: i, Time: candle.Time, Kind: kind, Message: fmt.Sprintf(format, args...)})
+		}
+
+		if i > 0 {
+			switch previous := s.Candles[i-1].Time; {
+			case candle.Time.Equal(previous):
+				issue(IssueDuplicate, "same time as the previous candle")
+			case candle.Time.Before(previous):
+				issue(IssueUnordered, "older than the previous candle %s", previous.Format(time.RFC3339))
+			}
+		}
+
+		valid := true
+		for _, value := range []float64{candle.Open, candle.High, candle.Low, candle.Close, candle.Volume} {
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				valid = false
+			}
+		}
+		if !valid {
+			issue(IssueInvalidValue, "not a number: %v %v %v %v %v",
+				candle.Open, candle.High, candle.Low, candle.Close, candle.Volume)
+			continue
+		}
+
+		switch {
+		case candle.Open <= 0 || candle.High <= 0 || candle.Low <= 0 || candle.Close <= 0:
+			issue(IssueInvalidValue, "non-positive price: open %v, high %v, low %v, close %v",
+				candle.Open, candle.High, candle.Low, candle.Close)
+		case candle.High < candle.Low:
+			issue(IssueOHLC, "high %v is lower than low %v", candle.High, candle.Low)
+		case candle.High < math.Max(candle.Open, candle.Close):
+			issue(IssueOHLC, "high %v is lower than open %v or close %v", candle.High, candle.Open, candle.Close)
+		case candle.Low > math.Min(candle.Open, candle.Close):
+			issue(IssueOHLC, "low %v is higher than open %v or close %v", candle.Low, candle.Open, candle.Close)
+		}
+
+		switch {
+		case candle.Volume < 0:
+			issue(IssueInvalidValue, "negative volume %v", candle.Volume)
+		case candle.Volume == 0:
+			issue(IssueZeroVolume, "zero volume")
+		}
+	}
+	return issues
+}
@@ -0,0 +1,159 @@
+package history
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ezquant/azbot/azbot/exchange"
+	"github.com/ezquant/azbot/azbot/model"
+)
+
+// FillPolicy defines the prices of the candles inserted in gaps, all of them have zero volume
+type FillPolicy string
+
+const (
+	// FillPrevious inserts flat candles at the close of the candle before the gap
+	FillPrevious FillPolicy = "previous"
+	// FillNext inserts flat candles at the open of the candle after the gap
+	FillNext FillPolicy = "next"
+	// FillLinear interpolates the price from the close before the gap to the open after it
+	FillLinear FillPolicy = "linear"
+)
+
+// FillPolicies are the supported policies, the first one is the default
+var FillPolicies = []FillPolicy{FillPrevious, FillNext, FillLinear}
+
+// Fill sorts the candles, removes the duplicates keeping the last one of the file, and inserts the
+// missing candles of gaps up to limit candles (0 fills all gaps). It returns the number of candles inserted.
+func (s *Series) Fill(policy FillPolicy, limit int) (int, error) {
+	if !containsPolicy(policy) {
+		return 0, fmt.Errorf("invalid fill policy %s, expected one of %v", policy, FillPolicies)
+	}
+
+	interval, err := s.interval()
+	if err != nil {
+		return 0, err
+	}
+
+	s.sort()
+	gaps, err := s.gaps()
+	if err != nil {
+		return 0, err
+	}
+
+	var inserted int
+	candles := make([]model.Candle, 0, len(s.Candles))
+	for i, candle := range s.Candles {
+		if len(gaps) > 0 && i > 0 && gaps[0].Before.Equal(candle.Time) {
+			gap := gaps[0]
+			gaps = gaps[1:]
+
+			if limit <= 0 || gap.Missing <= limit {
+				candles = append(candles, s.fillGap(policy, gap, s.Candles[i-1], candle, interval)...)
+				inserted += gap.Missing
+			}
+		}
+		candles = append(candles, candle)
+	}
+	s.Candles = candles
+
+	return inserted, nil
+}
+
+func (s Series) fillGap(policy FillPolicy, gap Gap, before, after model.Candle, interval time.Duration) []model.Candle {
+	candles := make([]model.Candle, 0, gap.Missing)
+	price := before.Close
+	for t := s.next(gap.After, interval); t.Before(gap.Before); t = s.next(t, interval) {
+		candle := model.Candle{
+			Pair:      s.Pair,
+			Time:      t,
+			UpdatedAt: t,
+			Open:      price,
+			Complete:  true,
+		}
+
+		switch policy {
+		case FillNext:
+			candle.Open = after.Open
+			price = after.Open
+		case FillLinear:
+			step := float64(len(candles)+1) / float64(gap.Missing+1)
+			price = before.Close + (after.Open-before.Close)*step
+		}
+
+		candle.Close = price
+		candle.High = math.Max(candle.Open, candle.Close)
+		candle.Low = math.Min(candle.Open, candle.Close)
+		candles = append(candles, candle)
+	}
+	return candles
+}
+
+func containsPolicy(policy FillPolicy) bool {
+	for _, p := range FillPolicies {
+		if p == policy {
+			return true
+		}
+	}
+	return false
+}
+
+// Resample aggregates the candles to a larger timeframe, multiple of the timeframe of the series, with the
+// logic of the backtest feeds. Candles are aggregated by the sessions of the calendar when it is set.
+func (s Series) Resample(timeframe string) (*Series, error) {
+	source, err := s.interval()
+	if err != nil {
+		return nil, err
+	}
+
+	resampled := &Series{Pair: s.Pair, Timeframe: timeframe, Calendar: s.Calendar}
+	target, err := resampled.interval()
+	if err != nil {
+		return nil, err
+	}
+
+	if target <= source || target%source != 0 {
+		return nil, fmt.Errorf("cannot resample %s to %s, the timeframe must be a larger multiple", s.Timeframe,
+			timeframe)
+	}
+
+	sorted := Series{Pair: s.Pair, Timeframe: s.Timeframe, Calendar: s.Calendar}
+	sorted.Candles = append(sorted.Candles, s.Candles...)
+	sorted.sort()
+
+	resampled.Candles, err = exchange.Resample(s.Calendar, sorted.Candles, s.Timeframe, timeframe)
+	if err != nil {
+		return nil, err
+	}
+	return resampled, nil
+}
+
+// Merge concatenates series of the same timeframe, eg: files downloaded in different periods. Candles are
+// sorted by time and, when files overlap, the candles of the last series are kept. It returns the merged
+// series, with the pair and the calendar of the first one, and the number of overlapping candles replaced.
+func Merge(series ...*Series) (*Series, int, error) {
+	if len(series) == 0 {
+		return nil, 0, fmt.Errorf("no series to merge")
+	}
+
+	merged := &Series{Pair: series[0].Pair, Timeframe: series[0].Timeframe, Calendar: series[0].Calendar}
+	var count int
+	for _, s := range series {
+		if s.Timeframe != merged.Timeframe {
+			return nil, 0, fmt.Errorf("cannot merge %s candles of %s with %s candles of %s", s.Timeframe, s.Pair,
+				merged.Timeframe, merged.Pair)
+		}
+
+		// each series is deduplicated before, only candles that overlap other series are counted
+		deduplicated := *s
+		deduplicated.Candles = append([]model.Candle(nil), s.Candles...)
+		deduplicated.sort()
+
+		count += len(deduplicated.Candles)
+		merged.Candles = append(merged.Candles, deduplicated.Candles...)
+	}
+	merged.sort()
+
+	return merged, count - len(merged.Candles), nil
+}
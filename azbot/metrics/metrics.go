@@ -0,0 +1,368 @@
+// Package metrics computes risk and return statistics from an equity curve and a list of trade results.
+package metrics
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/xhit/go-str2duration/v2"
+
+	"github.com/ezquant/azbot/azbot/exchange"
+)
+
+const year = 365 * 24 * time.Hour
+
+var ErrInsufficientData = errors.New("insufficient data to compute metrics")
+
+// Metrics is a set of risk and return statistics of a backtest
+type Metrics struct {
+	TotalReturn      float64
+	AnnualizedReturn float64
+	Volatility       float64
+	Sharpe           float64
+	Sortino          float64
+	Calmar           float64
+	Omega            float64
+	UlcerIndex       float64
+
+	MaxDrawdown         float64
+	MaxDrawdownDuration time.Duration
+
+	// ActivePeriods is the fraction of periods in which the equity changed. It is not the time in market,
+	// see the Exposure of the backtest report, that counts the candles with an open position.
+	ActivePeriods float64
+
+	Trades     int
+	WinRate    float64
+	Expectancy float64
+	// Periods is the number of equity samples used, after removing duplicated timestamps
+	Periods        int
+	PeriodsPerYear float64
+}
+
+type parameters struct {
+	riskFreeRate   float64
+	omegaThreshold float64
+	periodsPerYear float64
+}
+
+type Option func(*parameters)
+
+// WithRiskFreeRate sets the annual risk free rate used by Sharpe and Sortino ratios, eg: 0.02 (default 0)
+func WithRiskFreeRate(rate float64) Option {
+	return func(p *parameters) {
+		p.riskFreeRate = rate
+	}
+}
+
+// WithOmegaThreshold sets the per period return threshold of the Omega ratio (default 0)
+func WithOmegaThreshold(threshold float64) Option {
+	return func(p *parameters) {
+		p.omegaThreshold = threshold
+	}
+}
+
+// WithPeriodsPerYear overrides the number of periods per year derived from the timeframe.
+// Use it for markets that do not trade 24/7, eg: 252 for daily candles of stocks.
+func WithPeriodsPerYear(periods float64) Option {
+	return func(p *parameters) {
+		p.periodsPerYear = periods
+	}
+}
+
+// PeriodsPerYear returns how many candles of a given timeframe exist in one year of continuous trading
+func PeriodsPerYear(timeframe string) (float64, error) {
+	duration, err := str2duration.ParseDuration(timeframe)
+	if err != nil {
+		return 0, err
+	}
+	return float64(year) / float64(duration), nil
+}
+
+// Compute calculates all metrics from an equity curve sampled at the given timeframe and the profit of each trade
+func Compute(equity []exchange.AssetValue, trades []float64, timeframe string, options ...Option) (Metrics, error) {
+	params := &parameters{}
+	for _, option := range options {
+		option(params)
+	}
+
+	if params.periodsPerYear == 0 {
+		periods, err := PeriodsPerYear(timeframe)
+		if err != nil {
+			return Metrics{}, err
+		}
+		params.periodsPerYear = periods
+	}
+
+	times, values := uniqueByTime(equity)
+	if len(values) < 2 {
+		return Metrics{}, ErrInsufficientData
+	}
+
+	returns := Returns(values)
+	annualReturn := AnnualizedReturn(values, params.periodsPerYear)
+	maxDrawdown := MaxDrawdown(values)
+
+	return Metrics{
+		TotalReturn:         values[len(values)-1]/values[0] - 1,
+		AnnualizedReturn:    annualReturn,
+		Volatility:          Volatility(returns, params.periodsPerYear),
+		Sharpe:              Sharpe(returns, params.riskFreeRate, params.periodsPerYear),
+		Sortino:             Sortino(returns, params.riskFreeRate, params.periodsPerYear),
+		Calmar:              Calmar(annualReturn, maxDrawdown),
+		Omega:               Omega(returns, params.omegaThreshold),
+		UlcerIndex:          UlcerIndex(values),
+		MaxDrawdown:         maxDrawdown,
+		MaxDrawdownDuration: MaxDrawdownDuration(times, values),
+		ActivePeriods:       ActivePeriods(returns),
+		Trades:              len(trades),
+		WinRate:             WinRate(trades),
+		Expectancy:          Expectancy(trades),
+		Periods:             len(values),
+		PeriodsPerYear:      params.periodsPerYear,
+	}, nil
+}
+
+// uniqueByTime keeps the last value of each timestamp, the paper wallet stores one sample per pair
+func uniqueByTime(equity []exchange.AssetValue) ([]time.Time, []float64) {
+	times := make([]time.Time, 0, len(equity))
+	values := make([]float64, 0, len(equity))
+	for _, value := range equity {
+		if last := len(times) - 1; last >= 0 && times[last].Equal(value.Time) {
+			values[last] = value.Value
+			continue
+		}
+		times = append(times, value.Time)
+		values = append(values, value.Value)
+	}
+	return times, values
+}
+
+// Returns converts an equity curve into simple returns between consecutive samples
+func Returns(equity []float64) []float64 {
+	if len(equity) < 2 {
+		return nil
+	}
+
+	returns := make([]float64, 0, len(equity)-1)
+	for i := 1; i < len(equity); i++ {
+		if equity[i-1] == 0 {
+			returns = append(returns, 0)
+			continue
+		}
+		returns = append(returns, equity[i]/equity[i-1]-1)
+	}
+	return returns
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	var total float64
+	for _, value := range values {
+		total += value
+	}
+	return total / float64(len(values))
+}
+
+// stdDev returns the sample standard deviation
+func stdDev(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+
+	avg := mean(values)
+	var sum float64
+	for _, value := range values {
+		sum += (value - avg) * (value - avg)
+	}
+	return math.Sqrt(sum / float64(len(values)-1))
+}
+
+// AnnualizedReturn returns the compound annual growth rate of an equity curve
+func AnnualizedReturn(equity []float64, periodsPerYear float64) float64 {
+	if len(equity) < 2 || equity[0] <= 0 || equity[len(equity)-1] <= 0 {
+		return 0
+	}
+
+	years := float64(len(equity)-1) / periodsPerYear
+	return math.Pow(equity[len(equity)-1]/equity[0], 1/years) - 1
+}
+
+// Volatility returns the annualized standard deviation of returns
+func Volatility(returns []float64, periodsPerYear float64) float64 {
+	return stdDev(returns) * math.Sqrt(periodsPerYear)
+}
+
+// Sharpe returns the annualized Sharpe ratio given an annual risk free rate
+func Sharpe(returns []float64, riskFreeRate, periodsPerYear float64) float64 {
+	excess := make([]float64, len(returns))
+	for i, value := range returns {
+		excess[i] = value - riskFreeRate/periodsPerYear
+	}
+
+	deviation := stdDev(excess)
+	if deviation == 0 {
+		return 0
+	}
+	return mean(excess) / deviation * math.Sqrt(periodsPerYear)
+}
+
+// Sortino returns the annualized Sortino ratio, which only penalizes the volatility of negative returns
+func Sortino(returns []float64, riskFreeRate, periodsPerYear float64) float64 {
+	if len(returns) == 0 {
+		return 0
+	}
+
+	target := riskFreeRate / periodsPerYear
+	var (
+		excess   float64
+		downside float64
+	)
+	for _, value := range returns {
+		excess += value - target
+		if value < target {
+			downside += (value - target) * (value - target)
+		}
+	}
+
+	downsideDeviation := math.Sqrt(downside / float64(len(returns)))
+	if downsideDeviation == 0 {
+		return 0
+	}
+	return excess / float64(len(returns)) / downsideDeviation * math.Sqrt(periodsPerYear)
+}
+
+// Calmar returns the annualized return divided by the absolute max drawdown
+func Calmar(annualizedReturn, maxDrawdown float64) float64 {
+	if maxDrawdown == 0 {
+		return 0
+	}
+	return annualizedReturn / math.Abs(maxDrawdown)
+}
+
+// Omega returns the ratio between gains and losses relative to a threshold return
+func Omega(returns []float64, threshold float64) float64 {
+	var gains, losses float64
+	for _, value := range returns {
+		if value > threshold {
+			gains += value - threshold
+		} else {
+			losses += threshold - value
+		}
+	}
+
+	if losses == 0 {
+		return 0
+	}
+	return gains / losses
+}
+
+// drawdowns returns the percentage distance of each sample from the previous peak (zero or negative values)
+func drawdowns(equity []float64) []float64 {
+	result := make([]float64, len(equity))
+	peak := math.Inf(-1)
+	for i, value := range equity {
+		peak = math.Max(peak, value)
+		if peak > 0 {
+			result[i] = value/peak - 1
+		}
+	}
+	return result
+}
+
+// MaxDrawdown returns the largest peak to trough decline as a negative fraction, eg: -0.2 for 20%
+func MaxDrawdown(equity []float64) float64 {
+	var maxDrawdown float64
+	for _, value := range drawdowns(equity) {
+		maxDrawdown = math.Min(maxDrawdown, value)
+	}
+	return maxDrawdown
+}
+
+// MaxDrawdownDuration returns the longest time spent below a previous equity peak
+func MaxDrawdownDuration(times []time.Time, equity []float64) time.Duration {
+	var (
+		longest time.Duration
+		peak    = math.Inf(-1)
+		start   time.Time
+	)
+
+	for i, value := range equity {
+		if value >= peak {
+			peak = value
+			start = times[i]
+			continue
+		}
+
+		if duration := times[i].Sub(start); duration > longest {
+			longest = duration
+		}
+	}
+	return longest
+}
+
+// UlcerIndex returns the quadratic mean of percentage drawdowns, measuring depth and duration of declines
+func UlcerIndex(equity []float64) float64 {
+	if len(equity) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, value := range drawdowns(equity) {
+		sum += (value * 100) * (value * 100)
+	}
+	return math.Sqrt(sum / float64(len(equity)))
+}
+
+// ActivePeriods returns the fraction of periods with non-zero returns
+func ActivePeriods(returns []float64) float64 {
+	if len(returns) == 0 {
+		return 0
+	}
+
+	var active int
+	for _, value := range returns {
+		if value != 0 {
+			active++
+		}
+	}
+	return float64(active) / float64(len(returns))
+}
+
+// WinRate returns the fraction of profitable trades
+func WinRate(trades []float64) float64 {
+	if len(trades) == 0 {
+		return 0
+	}
+
+	var wins int
+	for _, profit := range trades {
+		if profit > 0 {
+			wins++
+		}
+	}
+	return float64(wins) / float64(len(trades))
+}
+
+// Expectancy returns the expected profit per trade: winRate * avgWin - lossRate * avgLoss
+func Expectancy(trades []float64) float64 {
+	if len(trades) == 0 {
+		return 0
+	}
+
+	var wins, losses []float64
+	for _, profit := range trades {
+		if profit > 0 {
+			wins = append(wins, profit)
+		} else {
+			losses = append(losses, profit)
+		}
+	}
+
+	winRate := WinRate(trades)
+	return winRate*mean(wins) + (1-winRate)*mean(losses)
+}
@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ezquant/azbot/azbot/exchange"
+)
+
+func equityCurve(values ...float64) []exchange.AssetValue {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	equity := make([]exchange.AssetValue, len(values))
+	for i, value := range values {
+		equity[i] = exchange.AssetValue{Time: start.AddDate(0, 0, i), Value: value}
+	}
+	return equity
+}
+
+func TestPeriodsPerYear(t *testing.T) {
+	periods, err := PeriodsPerYear("1d")
+	require.NoError(t, err)
+	require.Equal(t, 365.0, periods)
+
+	periods, err = PeriodsPerYear("1h")
+	require.NoError(t, err)
+	require.Equal(t, 365.0*24, periods)
+
+	_, err = PeriodsPerYear("invalid")
+	require.Error(t, err)
+}
+
+func TestReturns(t *testing.T) {
+	returns := Returns([]float64{100, 110, 99})
+	require.Len(t, returns, 2)
+	require.InDelta(t, 0.1, returns[0], 1e-9)
+	require.InDelta(t, -0.1, returns[1], 1e-9)
+	require.Nil(t, Returns([]float64{100}))
+}
+
+func TestDrawdown(t *testing.T) {
+	equity := []float64{100, 120, 90, 60, 130, 117}
+	require.InDelta(t, -0.5, MaxDrawdown(equity), 1e-9)
+
+	times := make([]time.Time, len(equity))
+	for i := range times {
+		times[i] = time.Date(2021, 1, 1+i, 0, 0, 0, 0, time.UTC)
+	}
+	require.Equal(t, 2*24*time.Hour, MaxDrawdownDuration(times, equity))
+
+	expected := math.Sqrt((25*25 + 50*50 + 10*10) / 6.0)
+	require.InDelta(t, expected, UlcerIndex(equity), 1e-9)
+}
+
+func TestRatios(t *testing.T) {
+	returns := []float64{0.01, -0.02, 0.03, 0.01}
+
+	avg := 0.0075
+	deviation := math.Sqrt((0.0025*0.0025 + 0.0275*0.0275 + 0.0225*0.0225 + 0.0025*0.0025) / 3)
+	require.InDelta(t, avg/deviation*math.Sqrt(365), Sharpe(returns, 0, 365), 1e-9)
+	require.InDelta(t, deviation*math.Sqrt(365), Volatility(returns, 365), 1e-9)
+
+	downside := math.Sqrt(0.02 * 0.02 / 4)
+	require.InDelta(t, avg/downside*math.Sqrt(365), Sortino(returns, 0, 365), 1e-9)
+
+	require.InDelta(t, 0.05/0.02, Omega(returns, 0), 1e-9)
+	require.InDelta(t, 2.5, Calmar(0.5, -0.2), 1e-9)
+	require.Equal(t, 0.0, Sharpe([]float64{0.01, 0.01}, 0, 365))
+}
+
+func TestTrades(t *testing.T) {
+	trades := []float64{10, -5, 20, -5}
+	require.Equal(t, 0.5, WinRate(trades))
+	require.InDelta(t, 0.5*15+0.5*-5, Expectancy(trades), 1e-9)
+	require.Equal(t, 0.0, Expectancy(nil))
+}
+
+func TestCompute(t *testing.T) {
+	t.Run("annualized return", func(t *testing.T) {
+		values := make([]float64, 366)
+		for i := range values {
+			values[i] = 100 * math.Pow(1.1, float64(i)/365)
+		}
+
+		metrics, err := Compute(equityCurve(values...), []float64{10}, "1d")
+		require.NoError(t, err)
+		require.InDelta(t, 0.1, metrics.AnnualizedReturn, 1e-9)
+		require.InDelta(t, 0.1, metrics.TotalReturn, 1e-9)
+		require.Equal(t, 0.0, metrics.MaxDrawdown)
+		require.Equal(t, 1.0, metrics.ActivePeriods)
+		require.Equal(t, 1, metrics.Trades)
+		require.Equal(t, 366, metrics.Periods)
+	})
+
+	t.Run("duplicated timestamps", func(t *testing.T) {
+		equity := equityCurve(100, 100, 50, 50)
+		equity[1].Time = equity[0].Time
+		equity[1].Value = 200
+
+		metrics, err := Compute(equity, nil, "1d", WithPeriodsPerYear(252))
+		require.NoError(t, err)
+		require.Equal(t, 3, metrics.Periods)
+		require.Equal(t, 252.0, metrics.PeriodsPerYear)
+		require.InDelta(t, -0.75, metrics.MaxDrawdown, 1e-9)
+		require.InDelta(t, 0.5, metrics.ActivePeriods, 1e-9)
+	})
+
+	t.Run("insufficient data", func(t *testing.T) {
+		_, err := Compute(equityCurve(100), nil, "1d")
+		require.ErrorIs(t, err, ErrInsufficientData)
+	})
+}
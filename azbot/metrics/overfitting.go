@@ -0,0 +1,217 @@
+package metrics
+
+import (
+	"errors"
+	"math"
+
+	"github.com/ezquant/azbot/azbot/exchange"
+)
+
+// eulerGamma is the Euler-Mascheroni constant used by the expected maximum of Sharpe ratios
+const eulerGamma = 0.5772156649015329
+
+// DefaultSplits is the number of blocks of the combinatorially symmetric cross-validation
+const DefaultSplits = 16
+
+var ErrInvalidSplits = errors.New("splits must be an even number greater than zero")
+
+// EquityReturns returns the returns of an equity curve, samples with the same time are merged
+func EquityReturns(equity []exchange.AssetValue) []float64 {
+	_, values := uniqueByTime(equity)
+	return Returns(values)
+}
+
+// Skewness returns the sample skewness of the values, zero for a normal distribution
+func Skewness(values []float64) float64 {
+	avg, deviation := mean(values), populationStdDev(values)
+	if deviation == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, value := range values {
+		sum += math.Pow((value-avg)/deviation, 3)
+	}
+	return sum / float64(len(values))
+}
+
+// Kurtosis returns the sample kurtosis of the values (not the excess), three for a normal distribution
+func Kurtosis(values []float64) float64 {
+	avg, deviation := mean(values), populationStdDev(values)
+	if deviation == 0 {
+		return 3
+	}
+
+	var sum float64
+	for _, value := range values {
+		sum += math.Pow((value-avg)/deviation, 4)
+	}
+	return sum / float64(len(values))
+}
+
+func populationStdDev(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	avg := mean(values)
+	var sum float64
+	for _, value := range values {
+		sum += (value - avg) * (value - avg)
+	}
+	return math.Sqrt(sum / float64(len(values)))
+}
+
+// normalCDF returns the cumulative distribution function of the standard normal distribution
+func normalCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+// normalQuantile returns the inverse of the cumulative distribution function of the standard normal distribution
+func normalQuantile(p float64) float64 {
+	return math.Sqrt2 * math.Erfinv(2*p-1)
+}
+
+// ProbabilisticSharpe returns the probability that the true Sharpe ratio of the returns is greater than the
+// benchmark, adjusted by the length, skewness and kurtosis of the returns. Both ratios are per period.
+func ProbabilisticSharpe(returns []float64, benchmark float64) float64 {
+	if len(returns) < 2 {
+		return 0
+	}
+
+	sharpe := Sharpe(returns, 0, 1)
+	variance := 1 - Skewness(returns)*sharpe + (Kurtosis(returns)-1)/4*sharpe*sharpe
+	if variance <= 0 {
+		return 0
+	}
+	return normalCDF((sharpe - benchmark) * math.Sqrt(float64(len(returns)-1)) / math.Sqrt(variance))
+}
+
+// ExpectedMaxSharpe returns the expected maximum Sharpe ratio of independent trials without skill,
+// given the variance of the Sharpe ratios of the trials
+func ExpectedMaxSharpe(trials int, variance float64) float64 {
+	if trials < 2 || variance <= 0 {
+		return 0
+	}
+
+	n := float64(trials)
+	return math.Sqrt(variance) * ((1-eulerGamma)*normalQuantile(1-1/n) + eulerGamma*normalQuantile(1-1/(n*math.E)))
+}
+
+// DeflatedSharpe returns the probability that the selected returns have a positive Sharpe ratio after
+// correcting for the selection bias of testing many trials (Bailey and López de Prado).
+// Sharpes are the per period Sharpe ratios of all trials, including the selected one.
+func DeflatedSharpe(returns []float64, sharpes []float64) float64 {
+	variance := stdDev(sharpes) * stdDev(sharpes)
+	return ProbabilisticSharpe(returns, ExpectedMaxSharpe(len(sharpes), variance))
+}
+
+// PBO returns the Probability of Backtest Overfitting using the combinatorially symmetric cross-validation:
+// the returns of the trials are split in blocks and, for each half of the blocks, the best trial in sample is
+// ranked out of sample against the other trials. It is the fraction of combinations where the best trial in
+// sample is below the median out of sample. Returns are the series of each trial, aligned by time and truncated
+// to the shortest one.
+func PBO(returns [][]float64, splits int) (float64, error) {
+	if splits <= 0 || splits%2 != 0 {
+		return 0, ErrInvalidSplits
+	}
+
+	size := math.MaxInt
+	for _, series := range returns {
+		size = min(size, len(series))
+	}
+	if len(returns) < 2 || size < splits*2 {
+		return 0, ErrInsufficientData
+	}
+
+	// sums and sums of squares of the returns of each trial by block, the last block keeps the remainder
+	type moments struct {
+		count      float64
+		sum, total float64
+	}
+	blocks := make([][]moments, splits)
+	for block := range blocks {
+		blocks[block] = make([]moments, len(returns))
+		first, last := block*size/splits, (block+1)*size/splits
+		for trial, series := range returns {
+			for _, value := range series[first:last] {
+				blocks[block][trial].count++
+				blocks[block][trial].sum += value
+				blocks[block][trial].total += value * value
+			}
+		}
+	}
+
+	sharpe := func(m moments) float64 {
+		if m.count < 2 {
+			return 0
+		}
+		variance := (m.total - m.sum*m.sum/m.count) / (m.count - 1)
+		if variance <= 0 {
+			return 0
+		}
+		return m.sum / m.count / math.Sqrt(variance)
+	}
+
+	var combinations, overfit int
+	inSample := make([]moments, len(returns))
+	outOfSample := make([]moments, len(returns))
+	eachCombination(splits, splits/2, func(selected []bool) {
+		for trial := range returns {
+			inSample[trial], outOfSample[trial] = moments{}, moments{}
+			for block := range blocks {
+				target := &outOfSample[trial]
+				if selected[block] {
+					target = &inSample[trial]
+				}
+				target.count += blocks[block][trial].count
+				target.sum += blocks[block][trial].sum
+				target.total += blocks[block][trial].total
+			}
+		}
+
+		best, bestSharpe := 0, math.Inf(-1)
+		for trial := range returns {
+			if value := sharpe(inSample[trial]); value > bestSharpe {
+				best, bestSharpe = trial, value
+			}
+		}
+
+		// relative rank of the best trial out of sample, the logit is not positive below the median
+		rank := 1
+		selectedSharpe := sharpe(outOfSample[best])
+		for trial := range returns {
+			if trial != best && sharpe(outOfSample[trial]) < selectedSharpe {
+				rank++
+			}
+		}
+		omega := float64(rank) / float64(len(returns)+1)
+		if math.Log(omega/(1-omega)) <= 0 {
+			overfit++
+		}
+		combinations++
+	})
+
+	return float64(overfit) / float64(combinations), nil
+}
+
+// eachCombination calls fn with every selection of k of n elements
+func eachCombination(n, k int, fn func(selected []bool)) {
+	selected := make([]bool, n)
+	var visit func(index, remaining int)
+	visit = func(index, remaining int) {
+		if remaining == 0 {
+			fn(selected)
+			return
+		}
+		if n-index < remaining {
+			return
+		}
+
+		selected[index] = true
+		visit(index+1, remaining-1)
+		selected[index] = false
+		visit(index+1, remaining)
+	}
+	visit(0, k)
+}
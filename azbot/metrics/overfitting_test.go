@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func noise(rng *rand.Rand, size int, drift float64) []float64 {
+	returns := make([]float64, size)
+	for i := range returns {
+		returns[i] = drift + rng.NormFloat64()*0.01
+	}
+	return returns
+}
+
+func TestMoments(t *testing.T) {
+	require.InDelta(t, 0, Skewness([]float64{1, 2, 3}), 1e-9)
+	require.Greater(t, Skewness([]float64{0, 0, 0, 10}), 0.0)
+	require.InDelta(t, 1, Kurtosis([]float64{-1, 1}), 1e-9)
+	require.Equal(t, 3.0, Kurtosis([]float64{1, 1}))
+
+	returns := EquityReturns(equityCurve(100, 110, 99))
+	require.InDeltaSlice(t, []float64{0.1, -0.1}, returns, 1e-9)
+}
+
+func TestDeflatedSharpe(t *testing.T) {
+	require.InDelta(t, 1.959964, normalQuantile(0.975), 1e-6)
+	require.InDelta(t, 0.975, normalCDF(1.959964), 1e-6)
+
+	// expected maximum of 1000 standard normal Sharpe ratios
+	require.InDelta(t, 3.25, ExpectedMaxSharpe(1000, 1), 0.01)
+	require.Equal(t, 0.0, ExpectedMaxSharpe(1, 1))
+
+	rng := rand.New(rand.NewSource(42))
+	returns := noise(rng, 500, 0.002)
+	psr := ProbabilisticSharpe(returns, 0)
+	require.Greater(t, psr, 0.95)
+
+	// the same returns are less significant when they are the best of many trials
+	sharpes := make([]float64, 100)
+	for i := range sharpes {
+		sharpes[i] = Sharpe(noise(rng, 500, 0), 0, 1)
+	}
+	sharpes[0] = Sharpe(returns, 0, 1)
+	dsr := DeflatedSharpe(returns, sharpes)
+	require.Less(t, dsr, psr)
+	require.Greater(t, dsr, 0.0)
+
+	require.Equal(t, 0.0, ProbabilisticSharpe([]float64{0.01}, 0))
+}
+
+func TestPBO(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+
+	t.Run("skill", func(t *testing.T) {
+		returns := [][]float64{noise(rng, 800, 0.003)}
+		for i := 0; i < 9; i++ {
+			returns = append(returns, noise(rng, 800, 0))
+		}
+
+		pbo, err := PBO(returns, DefaultSplits)
+		require.NoError(t, err)
+		require.Less(t, pbo, 0.05)
+	})
+
+	t.Run("noise", func(t *testing.T) {
+		// without skill the best trial in sample is below the median out of sample half of the time
+		var total float64
+		for sample := 0; sample < 20; sample++ {
+			var returns [][]float64
+			for i := 0; i < 20; i++ {
+				returns = append(returns, noise(rng, 800, 0))
+			}
+
+			pbo, err := PBO(returns, 8)
+			require.NoError(t, err)
+			total += pbo
+		}
+		require.InDelta(t, 0.5, total/20, 0.1)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := PBO([][]float64{{0.1}, {0.2}}, 3)
+		require.ErrorIs(t, err, ErrInvalidSplits)
+
+		_, err = PBO([][]float64{noise(rng, 100, 0)}, 4)
+		require.ErrorIs(t, err, ErrInsufficientData)
+
+		_, err = PBO([][]float64{noise(rng, 100, 0), noise(rng, 5, 0)}, 4)
+		require.ErrorIs(t, err, ErrInsufficientData)
+	})
+}
+
+func TestEachCombination(t *testing.T) {
+	var count int
+	eachCombination(6, 3, func(selected []bool) {
+		var chosen int
+		for _, value := range selected {
+			if value {
+				chosen++
+			}
+		}
+		require.Equal(t, 3, chosen)
+		count++
+	})
+	require.Equal(t, 20, count)
+}
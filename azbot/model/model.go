@@ -54,6 +54,9 @@ type Dataframe struct {
 
 	// Custom user metadata
 	Metadata map[string]Series[float64]
+
+	// Timeframes are the dataframes of additional timeframes of a multi-timeframe strategy, eg: Timeframes["1d"]
+	Timeframes map[string]*Dataframe
 }
 
 func (df Dataframe) Sample(positions int) Dataframe {
@@ -40,9 +40,20 @@ type Order struct {
 	Price      float64         `db:"price" json:"price"`
 	Quantity   float64         `db:"quantity" json:"quantity"`
 
+	// ExecutedQuantity is the filled part of Quantity, orders can be filled in many trades
+	ExecutedQuantity float64 `db:"executed_quantity" json:"executed_quantity"`
+
+	// AvgPrice is the volume-weighted price of the executed quantity, it differs from Price and Stop
+	// when the order is filled with gaps or slippage
+	AvgPrice float64 `db:"avg_price" json:"avg_price"`
+
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
 
+	// Commission charged for the order, in FeeAsset
+	Fee      float64 `db:"fee" json:"fee"`
+	FeeAsset string  `db:"fee_asset" json:"fee_asset"`
+
 	// OCO Orders only
 	Stop    *float64 `db:"stop" json:"stop"`
 	GroupID *int64   `db:"group_id" json:"group_id"`
@@ -53,6 +64,27 @@ type Order struct {
 	Candle   Candle  `json:"-" gorm:"-"`
 }
 
+// Executed returns the filled quantity of the order, filled orders without
+// executed quantity (legacy records) are considered fully executed
+func (o Order) Executed() float64 {
+	if o.Status == OrderStatusTypeFilled && o.ExecutedQuantity == 0 {
+		return o.Quantity
+	}
+	return o.ExecutedQuantity
+}
+
+// FillPrice returns the average price of the executed quantity, orders without average price
+// (legacy records) are valued at the stop price for stop orders and at the order price otherwise
+func (o Order) FillPrice() float64 {
+	if o.AvgPrice > 0 {
+		return o.AvgPrice
+	}
+	if (o.Type == OrderTypeStopLoss || o.Type == OrderTypeStopLossLimit) && o.Stop != nil {
+		return *o.Stop
+	}
+	return o.Price
+}
+
 func (o Order) String() string {
 	return fmt.Sprintf("[%s] %s %s | ID: %d, Type: %s, %f x $%f (~$%.f)",
 		o.Status, o.Side, o.Pair, o.ID, o.Type, o.Quantity, o.Price, o.Quantity*o.Price)
@@ -22,3 +22,26 @@ func TestOrder_String(t *testing.T) {
 	}
 	require.Equal(t, "[FILLED] SELL BNBUSDT | ID: 1, Type: LIMIT, 1.000000 x $10.000000 (~$10)", order.String())
 }
+
+func TestOrder_Executed(t *testing.T) {
+	order := Order{Status: OrderStatusTypeFilled, Quantity: 2}
+	require.Equal(t, 2.0, order.Executed())
+
+	order = Order{Status: OrderStatusTypePartiallyFilled, Quantity: 2, ExecutedQuantity: 0.5}
+	require.Equal(t, 0.5, order.Executed())
+
+	order = Order{Status: OrderStatusTypeNew, Quantity: 2}
+	require.Equal(t, 0.0, order.Executed())
+}
+
+func TestOrder_FillPrice(t *testing.T) {
+	stop := 90.0
+	order := Order{Type: OrderTypeStopLoss, Price: 95, Stop: &stop}
+	require.Equal(t, 90.0, order.FillPrice())
+
+	order.AvgPrice = 80
+	require.Equal(t, 80.0, order.FillPrice())
+
+	order = Order{Type: OrderTypeLimit, Price: 100}
+	require.Equal(t, 100.0, order.FillPrice())
+}
@@ -0,0 +1,303 @@
+// Package montecarlo simulates alternative paths of a backtest from its trades, to measure how fragile a
+// strategy is: the order of the trades, missed trades and worse fills change the final equity and drawdowns.
+package montecarlo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+
+	"github.com/ezquant/azbot/azbot/metrics"
+)
+
+// Method is how the trades of each simulated path are drawn
+type Method string
+
+const (
+	// Reshuffle uses every trade once in random order, the final equity only changes by skips and fills
+	Reshuffle Method = "reshuffle"
+	// Bootstrap draws the same number of trades with replacement
+	Bootstrap Method = "bootstrap"
+)
+
+var (
+	ErrNoTrades      = errors.New("no trades to simulate")
+	ErrNoEquity      = errors.New("initial equity must be positive")
+	ErrUnknownMethod = errors.New("unknown simulation method")
+)
+
+// Trade is a closed trade of the backtest
+type Trade struct {
+	// Profit is the result of the trade in quote currency
+	Profit float64
+	// Notional is the value of each fill of the trade, it scales the fill noise
+	Notional float64
+}
+
+type parameters struct {
+	simulations int
+	method      Method
+	skip        float64
+	deviation   float64
+	ruin        float64
+	seed        int64
+	equity      []float64
+}
+
+type Option func(*parameters)
+
+// WithSimulations sets the number of simulated paths (default 1000)
+func WithSimulations(simulations int) Option {
+	return func(p *parameters) {
+		p.simulations = simulations
+	}
+}
+
+// WithMethod sets how trades are drawn: Reshuffle (default) or Bootstrap
+func WithMethod(method Method) Option {
+	return func(p *parameters) {
+		p.method = method
+	}
+}
+
+// WithSkipProbability skips each trade with the given probability, eg: 0.1 to miss one of ten trades
+func WithSkipProbability(probability float64) Option {
+	return func(p *parameters) {
+		p.skip = probability
+	}
+}
+
+// WithFillNoise perturbs the entry and exit fills of each trade, the price of each fill of the notional value
+// of the trade moves by a normal relative error with the given standard deviation, eg: 0.001 for 10 bps
+func WithFillNoise(deviation float64) Option {
+	return func(p *parameters) {
+		p.deviation = deviation
+	}
+}
+
+// WithRuin sets the drawdown from the peak that ruins a path, eg: 0.5 (default) for a loss of 50%
+func WithRuin(drawdown float64) Option {
+	return func(p *parameters) {
+		p.ruin = drawdown
+	}
+}
+
+// WithSeed sets the seed of the random generator, the same seed gives the same paths (default 0)
+func WithSeed(seed int64) Option {
+	return func(p *parameters) {
+		p.seed = seed
+	}
+}
+
+// WithEquity sets the equity curve of the backtest, the max drawdown of the original path is measured on it,
+// including the losses of open positions, instead of at trade closes
+func WithEquity(equity []float64) Option {
+	return func(p *parameters) {
+		p.equity = equity
+	}
+}
+
+// Path is the result of the backtest or of one simulation. MaxDrawdown is negative, eg: -0.2 for 20%.
+type Path struct {
+	FinalEquity float64
+	MaxDrawdown float64
+	Ruined      bool
+}
+
+// Distribution contains the sorted values of a statistic over all simulated paths
+type Distribution struct {
+	Values []float64
+}
+
+func newDistribution(values []float64) Distribution {
+	sort.Float64s(values)
+	return Distribution{Values: values}
+}
+
+// Mean returns the average of the values
+func (d Distribution) Mean() float64 {
+	if len(d.Values) == 0 {
+		return 0
+	}
+
+	var total float64
+	for _, value := range d.Values {
+		total += value
+	}
+	return total / float64(len(d.Values))
+}
+
+// Percentile returns the value below which a fraction p of the values falls, eg: 0.05 for the 5th percentile.
+// Values between samples are linearly interpolated.
+func (d Distribution) Percentile(p float64) float64 {
+	if len(d.Values) == 0 {
+		return 0
+	}
+
+	position := math.Min(math.Max(p, 0), 1) * float64(len(d.Values)-1)
+	lower := int(math.Floor(position))
+	upper := int(math.Ceil(position))
+	weight := position - float64(lower)
+	return d.Values[lower]*(1-weight) + d.Values[upper]*weight
+}
+
+// Interval returns the bounds of the centered interval with the given confidence, eg: 0.95
+func (d Distribution) Interval(confidence float64) (float64, float64) {
+	tail := (1 - confidence) / 2
+	return d.Percentile(tail), d.Percentile(1 - tail)
+}
+
+// Report is the result of a Monte Carlo simulation
+type Report struct {
+	Method      Method
+	Simulations int
+	// Original is the path of the backtest trades in the order they were closed, without skips or noise.
+	// Its max drawdown is measured on the equity curve when it is given with WithEquity.
+	Original    Path
+	FinalEquity Distribution
+	MaxDrawdown Distribution
+	// RiskOfRuin is the fraction of paths that reached the ruin drawdown
+	RiskOfRuin float64
+}
+
+// Simulate runs the simulations from the closed trades of the backtest, in the order they were closed. All paths,
+// and the original one, start with the initial equity and are valued when trades are closed, so their drawdowns
+// don't include the losses of open positions. Use WithEquity to measure the drawdown of the backtest.
+func Simulate(trades []Trade, initial float64, options ...Option) (Report, error) {
+	params := &parameters{
+		simulations: 1000,
+		method:      Reshuffle,
+		ruin:        0.5,
+	}
+	for _, option := range options {
+		option(params)
+	}
+
+	if len(trades) == 0 {
+		return Report{}, ErrNoTrades
+	}
+	if initial <= 0 {
+		return Report{}, ErrNoEquity
+	}
+	if params.method != Reshuffle && params.method != Bootstrap {
+		return Report{}, fmt.Errorf("%w: %s", ErrUnknownMethod, params.method)
+	}
+
+	profits := make([]float64, len(trades))
+	for i, trade := range trades {
+		profits[i] = trade.Profit
+	}
+
+	report := Report{
+		Method:      params.method,
+		Simulations: params.simulations,
+		Original:    walk(params, profits, initial),
+	}
+
+	if len(params.equity) > 0 {
+		report.Original.MaxDrawdown = metrics.MaxDrawdown(params.equity)
+		report.Original.Ruined = report.Original.Ruined || report.Original.MaxDrawdown <= -params.ruin
+	}
+
+	rng := rand.New(rand.NewSource(params.seed))
+	finals := make([]float64, 0, params.simulations)
+	drawdowns := make([]float64, 0, params.simulations)
+	var ruined int
+	for i := 0; i < params.simulations; i++ {
+		path := simulate(rng, params, trades, initial)
+		finals = append(finals, path.FinalEquity)
+		drawdowns = append(drawdowns, path.MaxDrawdown)
+		if path.Ruined {
+			ruined++
+		}
+	}
+
+	report.FinalEquity = newDistribution(finals)
+	report.MaxDrawdown = newDistribution(drawdowns)
+	if params.simulations > 0 {
+		report.RiskOfRuin = float64(ruined) / float64(params.simulations)
+	}
+	return report, nil
+}
+
+// simulate returns one path of the trades starting with the initial equity
+func simulate(rng *rand.Rand, params *parameters, trades []Trade, initial float64) Path {
+	order := make([]Trade, len(trades))
+	switch params.method {
+	case Bootstrap:
+		for i := range order {
+			order[i] = trades[rng.Intn(len(trades))]
+		}
+	default:
+		for i, index := range rng.Perm(len(trades)) {
+			order[i] = trades[index]
+		}
+	}
+
+	profits := make([]float64, 0, len(order))
+	for _, trade := range order {
+		if params.skip > 0 && rng.Float64() < params.skip {
+			continue
+		}
+
+		// entry and exit fills move independently
+		profit := trade.Profit
+		if params.deviation > 0 {
+			profit += trade.Notional * params.deviation * (rng.NormFloat64() + rng.NormFloat64())
+		}
+		profits = append(profits, profit)
+	}
+	return walk(params, profits, initial)
+}
+
+// walk returns the path of the profits added in order to the initial equity
+func walk(params *parameters, profits []float64, initial float64) Path {
+	value, peak := initial, initial
+	path := Path{}
+	for _, profit := range profits {
+		value += profit
+		peak = math.Max(peak, value)
+		if peak > 0 {
+			path.MaxDrawdown = math.Min(path.MaxDrawdown, value/peak-1)
+		}
+		if value <= 0 || path.MaxDrawdown <= -params.ruin {
+			path.Ruined = true
+		}
+	}
+	path.FinalEquity = value
+	return path
+}
+
+// String returns a table with the percentiles of the final equity and max drawdown
+func (r Report) String() string {
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetHeader([]string{"", "Backtest", "Mean", "5%", "25%", "50%", "75%", "95%"})
+
+	row := func(name string, original float64, distribution Distribution, format string) []string {
+		values := []string{name, fmt.Sprintf(format, original), fmt.Sprintf(format, distribution.Mean())}
+		for _, p := range []float64{0.05, 0.25, 0.5, 0.75, 0.95} {
+			values = append(values, fmt.Sprintf(format, distribution.Percentile(p)))
+		}
+		return values
+	}
+
+	drawdowns := Distribution{Values: make([]float64, len(r.MaxDrawdown.Values))}
+	for i, value := range r.MaxDrawdown.Values {
+		drawdowns.Values[i] = value * 100
+	}
+
+	table.Append(row("Final Equity", r.Original.FinalEquity, r.FinalEquity, "%.2f"))
+	table.Append(row("Max Drawdown %", r.Original.MaxDrawdown*100, drawdowns, "%.2f"))
+	table.Render()
+
+	low, high := r.FinalEquity.Interval(0.95)
+	return fmt.Sprintf("Monte Carlo (%s, %d simulations)\n%sSimulated paths are valued at trade closes\n"+
+		"Final Equity 95%% CI: [%.2f, %.2f]\nRisk of Ruin: %.2f%%\n",
+		r.Method, r.Simulations, tableString.String(), low, high, r.RiskOfRuin*100)
+}
@@ -0,0 +1,117 @@
+package montecarlo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// closedTrades returns trades with the given profits and a notional of 1000
+func closedTrades(profits ...float64) []Trade {
+	trades := make([]Trade, len(profits))
+	for i, profit := range profits {
+		trades[i] = Trade{Profit: profit, Notional: 1000}
+	}
+	return trades
+}
+
+func TestDistribution(t *testing.T) {
+	distribution := newDistribution([]float64{5, 1, 4, 2, 3})
+	require.Equal(t, []float64{1, 2, 3, 4, 5}, distribution.Values)
+	require.Equal(t, 3.0, distribution.Mean())
+	require.Equal(t, 1.0, distribution.Percentile(0))
+	require.Equal(t, 3.0, distribution.Percentile(0.5))
+	require.Equal(t, 1.4, distribution.Percentile(0.1))
+
+	low, high := distribution.Interval(0.5)
+	require.Equal(t, 2.0, low)
+	require.Equal(t, 4.0, high)
+
+	require.Equal(t, 0.0, Distribution{}.Percentile(0.5))
+}
+
+func TestSimulate(t *testing.T) {
+	trades := closedTrades(100, -50, 200, -150, 100)
+
+	t.Run("reshuffle", func(t *testing.T) {
+		report, err := Simulate(trades, 1000, WithSimulations(200), WithSeed(1))
+		require.NoError(t, err)
+		require.Equal(t, 200, report.Simulations)
+		require.Len(t, report.FinalEquity.Values, 200)
+
+		// the backtest path is valued at the close of its trades, as the simulated paths
+		require.Equal(t, 1200.0, report.Original.FinalEquity)
+		require.InDelta(t, 1100.0/1250-1, report.Original.MaxDrawdown, 1e-9)
+
+		// the order does not change the final equity, only the drawdowns
+		require.Equal(t, 1200.0, report.FinalEquity.Percentile(0))
+		require.Equal(t, 1200.0, report.FinalEquity.Percentile(1))
+		require.Equal(t, 0.0, report.RiskOfRuin)
+
+		// the worst path starts with both losses
+		require.InDelta(t, -0.2, report.MaxDrawdown.Percentile(0), 1e-9)
+		require.LessOrEqual(t, report.MaxDrawdown.Percentile(1), 0.0)
+		require.Contains(t, report.String(), "Risk of Ruin")
+	})
+
+	t.Run("equity curve", func(t *testing.T) {
+		// the equity drops to 800 with an open position before the first trade is closed with profit
+		equity := []float64{1000, 800, 1100, 1050, 1250, 1100, 1200}
+		report, err := Simulate(trades, 1000, WithEquity(equity), WithSimulations(10), WithSeed(1))
+		require.NoError(t, err)
+		require.Equal(t, 1200.0, report.Original.FinalEquity)
+		require.InDelta(t, -0.2, report.Original.MaxDrawdown, 1e-9)
+		require.False(t, report.Original.Ruined)
+	})
+
+	t.Run("bootstrap", func(t *testing.T) {
+		report, err := Simulate(trades, 1000, WithMethod(Bootstrap), WithSimulations(500), WithSeed(1))
+		require.NoError(t, err)
+		require.Less(t, report.FinalEquity.Percentile(0), 1200.0)
+		require.Greater(t, report.FinalEquity.Percentile(1), 1200.0)
+		require.InDelta(t, 1200, report.FinalEquity.Mean(), 20)
+	})
+
+	t.Run("skip and fill noise", func(t *testing.T) {
+		report, err := Simulate(trades, 1000, WithSkipProbability(1), WithSimulations(10))
+		require.NoError(t, err)
+		require.Equal(t, 1000.0, report.FinalEquity.Percentile(0))
+		require.Equal(t, 1000.0, report.FinalEquity.Percentile(1))
+
+		report, err = Simulate(trades, 1000, WithFillNoise(0.01), WithSimulations(100))
+		require.NoError(t, err)
+		require.Less(t, report.FinalEquity.Percentile(0), 1200.0)
+		require.Greater(t, report.FinalEquity.Percentile(1), 1200.0)
+
+		// the noise of each trade is proportional to its own notional
+		small := append(closedTrades(100, -50, 200, -150), Trade{Profit: 100, Notional: 10})
+		for i := range small[:4] {
+			small[i].Notional = 0
+		}
+		report, err = Simulate(small, 1000, WithFillNoise(0.01), WithSimulations(100))
+		require.NoError(t, err)
+		require.InDelta(t, 1200, report.FinalEquity.Percentile(0), 1)
+		require.InDelta(t, 1200, report.FinalEquity.Percentile(1), 1)
+	})
+
+	t.Run("risk of ruin", func(t *testing.T) {
+		report, err := Simulate(closedTrades(-300, -300, 100), 1000, WithRuin(0.5), WithSimulations(50))
+		require.NoError(t, err)
+		require.Equal(t, 1.0, report.RiskOfRuin)
+
+		report, err = Simulate(closedTrades(-300, 100), 1000, WithRuin(0.5), WithSimulations(50))
+		require.NoError(t, err)
+		require.Equal(t, 0.0, report.RiskOfRuin)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := Simulate(nil, 1000)
+		require.ErrorIs(t, err, ErrNoTrades)
+
+		_, err = Simulate(trades, 0)
+		require.ErrorIs(t, err, ErrNoEquity)
+
+		_, err = Simulate(trades, 1000, WithMethod("unknown"))
+		require.ErrorIs(t, err, ErrUnknownMethod)
+	})
+}
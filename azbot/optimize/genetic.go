@@ -0,0 +1,81 @@
+package optimize
+
+import (
+	"math/rand"
+	"sort"
+)
+
+type genetic struct {
+	space      Space
+	trials     int
+	seed       int64
+	population int
+	mutation   float64
+	tournament int
+}
+
+// Genetic evolves a population of the best trials: the first generation is random and the next parameters are
+// children of parents selected by tournament, with uniform crossover and random mutation of each parameter.
+func Genetic(space Space, trials int, seed int64) Sampler {
+	return genetic{
+		space:      space,
+		trials:     trials,
+		seed:       seed,
+		population: max(10, min(50, trials/5)),
+		mutation:   0.1,
+		tournament: 3,
+	}
+}
+
+func (g genetic) Sample(history []Trial, n int) []Params {
+	if len(history) < g.population {
+		return Random(g.space, min(g.trials, g.population), g.seed).Sample(history, n)
+	}
+
+	rng := newRand(g.seed, history)
+	seen := seenTrials(history)
+	size := g.space.Size()
+
+	// the population are the best trials evaluated so far
+	population := append([]Trial(nil), history...)
+	sort.SliceStable(population, func(i, j int) bool {
+		return population[i].Score > population[j].Score
+	})
+	population = population[:g.population]
+
+	var batch []Params
+	for attempt := 0; len(batch) < remaining(g.trials, history, n) && attempt < maxAttempts*n; attempt++ {
+		if size > 0 && len(seen) >= size {
+			break
+		}
+
+		first, second := g.selectParent(rng, population), g.selectParent(rng, population)
+		child := make(Params, len(g.space))
+		for _, dimension := range g.space {
+			switch {
+			case rng.Float64() < g.mutation:
+				child[dimension.Name] = dimension.sample(rng)
+			case rng.Intn(2) == 0:
+				child[dimension.Name] = first.Params[dimension.Name]
+			default:
+				child[dimension.Name] = second.Params[dimension.Name]
+			}
+		}
+
+		if unique(seen, child) {
+			batch = append(batch, child)
+		}
+	}
+	return batch
+}
+
+// selectParent returns the best of random trials of the population
+func (g genetic) selectParent(rng *rand.Rand, population []Trial) Trial {
+	best := population[rng.Intn(len(population))]
+	for i := 1; i < g.tournament; i++ {
+		if candidate := population[rng.Intn(len(population))]; candidate.Score > best.Score {
+			best = candidate
+		}
+	}
+	return best
+}
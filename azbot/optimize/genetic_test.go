@@ -0,0 +1,18 @@
+package optimize
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenetic(t *testing.T) {
+	history, best := search(t, Genetic(space, 150, 3), 10)
+	require.Len(t, history, 150)
+	requireValid(t, history)
+	require.Greater(t, best, -0.1)
+
+	// reproducible with the seed
+	again, _ := search(t, Genetic(space, 150, 3), 10)
+	require.Equal(t, history, again)
+}
@@ -0,0 +1,319 @@
+// Package optimize samples parameters of strategies from a search space, eg: grid search or TPE.
+package optimize
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// Type of a dimension of the search space
+type Type string
+
+const (
+	TypeInt   Type = "int"
+	TypeFloat Type = "float"
+	TypeBool  Type = "bool"
+	TypeEnum  Type = "enum"
+)
+
+// Dimension is a parameter of the search space. Numeric values are in the interval [Min, Max] and multiples
+// of Step from Min, a float without step is continuous. Values are the choices of enums.
+type Dimension struct {
+	Name   string
+	Type   Type
+	Min    float64
+	Max    float64
+	Step   float64
+	Values []interface{}
+}
+
+// Space is the search space of the parameters
+type Space []Dimension
+
+// Params are the values of the parameters by name: int, float64, bool or a value of an enum
+type Params map[string]interface{}
+
+// Key returns a representation of the parameters sorted by name, eg: to find duplicated trials
+func (p Params) Key() string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var builder strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&builder, "%s=%v;", name, p[name])
+	}
+	return builder.String()
+}
+
+// Trial is the score of evaluated parameters, higher is better. Failed trials have a -Inf score.
+type Trial struct {
+	Params Params
+	Score  float64
+}
+
+// Sampler proposes parameters to evaluate given the trials evaluated so far. Samplers with the same seed and
+// history return the same parameters, so a search can be resumed from stored trials.
+type Sampler interface {
+	// Sample returns up to n parameters to evaluate, none when the budget of trials is exhausted
+	Sample(history []Trial, n int) []Params
+}
+
+// DefaultTrials is the budget of samplers other than the grid when trials is not positive
+const DefaultTrials = 100
+
+// New returns the sampler of a method: grid, random, tpe or genetic. Trials is the budget of evaluations
+// (zero is all combinations for the grid) and seed makes the search reproducible.
+func New(method string, space Space, trials int, seed int64) (Sampler, error) {
+	method = strings.ToLower(method)
+	if trials <= 0 && method != "" && method != "grid" {
+		trials = DefaultTrials
+	}
+
+	switch method {
+	case "", "grid":
+		return Grid(space, trials), nil
+	case "random":
+		return Random(space, trials, seed), nil
+	case "tpe", "bayesian":
+		return TPE(space, trials, seed), nil
+	case "genetic", "ga":
+		return Genetic(space, trials, seed), nil
+	default:
+		return nil, fmt.Errorf("unknown search method: %s", method)
+	}
+}
+
+// choices returns the discrete values of the dimension, nil for continuous floats
+func (d Dimension) choices() []interface{} {
+	switch d.Type {
+	case TypeBool:
+		return []interface{}{false, true}
+	case TypeEnum:
+		return d.Values
+	case TypeInt:
+		step := math.Max(1, math.Round(d.Step))
+		var values []interface{}
+		for v := math.Round(d.Min); v <= d.Max; v += step {
+			values = append(values, int(v))
+		}
+		return values
+	case TypeFloat:
+		if d.Max <= d.Min {
+			return []interface{}{d.Min}
+		}
+		if d.Step <= 0 {
+			return nil
+		}
+		var values []interface{}
+		for i := 0; d.Min+float64(i)*d.Step <= d.Max+d.Step*1e-9; i++ {
+			values = append(values, d.round(d.Min+float64(i)*d.Step))
+		}
+		return values
+	}
+	return nil
+}
+
+// round snaps a numeric value to the step and bounds of the dimension
+func (d Dimension) round(value float64) float64 {
+	value = math.Min(math.Max(value, d.Min), d.Max)
+	if d.Step > 0 {
+		value = d.Min + math.Round((value-d.Min)/d.Step)*d.Step
+		if value > d.Max+d.Step*1e-9 {
+			value -= d.Step
+		}
+	}
+	// remove floating point errors, eg: 0.1 + 0.2 = 0.30000000000000004
+	return math.Round(value*1e9) / 1e9
+}
+
+// value converts a numeric value of the dimension to the type of the parameter
+func (d Dimension) value(value float64) interface{} {
+	value = d.round(value)
+	if d.Type == TypeInt {
+		return int(math.Round(value))
+	}
+	return value
+}
+
+// numeric returns the value of a numeric parameter as float
+func numeric(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// sample returns a uniform random value of the dimension
+func (d Dimension) sample(rng *rand.Rand) interface{} {
+	if choices := d.choices(); len(choices) > 0 {
+		return choices[rng.Intn(len(choices))]
+	}
+	return d.value(d.Min + rng.Float64()*(d.Max-d.Min))
+}
+
+// Size returns the number of combinations of the space, zero when a dimension is continuous
+func (s Space) Size() int {
+	size := 1
+	for _, dimension := range s {
+		choices := dimension.choices()
+		if len(choices) == 0 {
+			return 0
+		}
+		size *= len(choices)
+	}
+	return size
+}
+
+func (s Space) sample(rng *rand.Rand) Params {
+	params := make(Params, len(s))
+	for _, dimension := range s {
+		params[dimension.Name] = dimension.sample(rng)
+	}
+	return params
+}
+
+// newRand returns a generator that depends on the seed and the number of evaluated trials
+func newRand(seed int64, history []Trial) *rand.Rand {
+	return rand.New(rand.NewSource(seed + int64(len(history))*7919))
+}
+
+// remaining returns the number of parameters to sample given the budget
+func remaining(trials int, history []Trial, n int) int {
+	if trials <= 0 {
+		return n
+	}
+	return max(0, min(n, trials-len(history)))
+}
+
+// unique adds params to the batch when they were not evaluated or sampled before
+func unique(seen map[string]bool, params Params) bool {
+	key := params.Key()
+	if seen[key] {
+		return false
+	}
+	seen[key] = true
+	return true
+}
+
+func seenTrials(history []Trial) map[string]bool {
+	seen := make(map[string]bool, len(history))
+	for _, trial := range history {
+		seen[trial.Params.Key()] = true
+	}
+	return seen
+}
+
+// maxAttempts limits the attempts to find parameters not evaluated before in small spaces
+const maxAttempts = 100
+
+type grid struct {
+	space  Space
+	trials int
+
+	values [][]interface{}
+	total  int
+	// cursor is the index of the next combination and evaluated the length of the history it continues
+	cursor    int
+	evaluated int
+	// seen are the evaluated combinations after the cursor
+	seen map[string]bool
+}
+
+// Grid evaluates all combinations of the space in order, up to trials when it is positive.
+// Continuous floats are sampled in 10 steps. The sampler continues from the last combination when the
+// history has the trials of the previous batch, so it must not be shared by concurrent searches.
+func Grid(space Space, trials int) Sampler {
+	return &grid{space: space, trials: trials}
+}
+
+func (g *grid) init() {
+	g.values = make([][]interface{}, len(g.space))
+	g.total = 1
+	for i, dimension := range g.space {
+		g.values[i] = dimension.choices()
+		if len(g.values[i]) == 0 {
+			dimension.Step = (dimension.Max - dimension.Min) / 10
+			g.values[i] = dimension.choices()
+		}
+		g.total *= len(g.values[i])
+	}
+}
+
+// combination returns the parameters of the index, the last dimension changes first
+func (g *grid) combination(index int) Params {
+	params := make(Params, len(g.space))
+	for i := len(g.space) - 1; i >= 0; i-- {
+		params[g.space[i].Name] = g.values[i][index%len(g.values[i])]
+		index /= len(g.values[i])
+	}
+	return params
+}
+
+func (g *grid) Sample(history []Trial, n int) []Params {
+	// a history that doesn't continue the previous batch is searched from the first combination, evaluated
+	// combinations are skipped since trials may be stored out of order by concurrent workers
+	if g.values == nil || len(history) != g.evaluated {
+		if g.values == nil {
+			g.init()
+		}
+		g.cursor = 0
+		g.seen = seenTrials(history)
+	}
+
+	size := remaining(g.trials, history, n)
+
+	var batch []Params
+	for ; g.cursor < g.total && len(batch) < size; g.cursor++ {
+		params := g.combination(g.cursor)
+		if len(g.seen) > 0 {
+			if key := params.Key(); g.seen[key] {
+				delete(g.seen, key)
+				continue
+			}
+		}
+		batch = append(batch, params)
+	}
+
+	g.evaluated = len(history) + len(batch)
+	return batch
+}
+
+type random struct {
+	space  Space
+	trials int
+	seed   int64
+}
+
+// Random samples uniform parameters of the space
+func Random(space Space, trials int, seed int64) Sampler {
+	return random{space: space, trials: trials, seed: seed}
+}
+
+func (r random) Sample(history []Trial, n int) []Params {
+	rng := newRand(r.seed, history)
+	seen := seenTrials(history)
+	size := r.space.Size()
+
+	var batch []Params
+	for attempt := 0; len(batch) < remaining(r.trials, history, n) && attempt < maxAttempts*n; attempt++ {
+		if size > 0 && len(seen) >= size {
+			break
+		}
+
+		if params := r.space.sample(rng); unique(seen, params) {
+			batch = append(batch, params)
+		}
+	}
+	return batch
+}
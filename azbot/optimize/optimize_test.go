@@ -0,0 +1,142 @@
+package optimize
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var space = Space{
+	{Name: "fast", Type: TypeInt, Min: 2, Max: 20, Step: 2},
+	{Name: "risk", Type: TypeFloat, Min: 0.1, Max: 1, Step: 0.1},
+	{Name: "stop", Type: TypeBool},
+	{Name: "ma", Type: TypeEnum, Values: []interface{}{"ema", "sma", "wma"}},
+}
+
+// objective has the maximum of 0 in fast=12, risk=0.3, stop=true and ma=sma
+func objective(params Params) float64 {
+	fast := float64(params["fast"].(int))
+	risk := params["risk"].(float64)
+	score := -math.Pow(fast-12, 2)/100 - math.Pow(risk-0.3, 2)*10
+	if !params["stop"].(bool) {
+		score -= 0.5
+	}
+	if params["ma"] != "sma" {
+		score -= 0.5
+	}
+	return score
+}
+
+// search evaluates the sampler in batches and returns the history and the best score
+func search(t *testing.T, sampler Sampler, batch int) ([]Trial, float64) {
+	t.Helper()
+
+	var history []Trial
+	best := math.Inf(-1)
+	for {
+		params := sampler.Sample(history, batch)
+		if len(params) == 0 {
+			return history, best
+		}
+		require.LessOrEqual(t, len(params), batch)
+
+		for _, p := range params {
+			score := objective(p)
+			best = math.Max(best, score)
+			history = append(history, Trial{Params: p, Score: score})
+		}
+	}
+}
+
+func requireValid(t *testing.T, history []Trial) {
+	t.Helper()
+
+	seen := make(map[string]bool)
+	for _, trial := range history {
+		require.False(t, seen[trial.Params.Key()], "duplicated trial %v", trial.Params)
+		seen[trial.Params.Key()] = true
+
+		fast := trial.Params["fast"].(int)
+		require.True(t, fast >= 2 && fast <= 20 && fast%2 == 0, fast)
+
+		risk := trial.Params["risk"].(float64)
+		require.True(t, risk >= 0.1 && risk <= 1, risk)
+		require.InDelta(t, math.Round(risk*10)/10, risk, 1e-9)
+		require.Contains(t, []interface{}{"ema", "sma", "wma"}, trial.Params["ma"])
+	}
+}
+
+func TestGrid(t *testing.T) {
+	require.Equal(t, 10*10*2*3, space.Size())
+
+	history, best := search(t, Grid(space, 0), 7)
+	require.Len(t, history, space.Size())
+	require.Zero(t, best)
+	requireValid(t, history)
+
+	require.Equal(t, Params{"fast": 2, "risk": 0.1, "stop": false, "ma": "ema"}, history[0].Params)
+	require.Equal(t, Params{"fast": 2, "risk": 0.1, "stop": false, "ma": "sma"}, history[1].Params)
+	require.Equal(t, Params{"fast": 20, "risk": 1.0, "stop": true, "ma": "wma"}, history[len(history)-1].Params)
+
+	history, _ = search(t, Grid(space, 50), 7)
+	require.Len(t, history, 50)
+
+	t.Run("resume", func(t *testing.T) {
+		history, _ := search(t, Grid(space, 0), 7)
+
+		// trials stored out of order are skipped
+		restored := append(append([]Trial(nil), history[5:10]...), history[:3]...)
+		sampler := Grid(space, 0)
+		params := sampler.Sample(restored, 4)
+		require.Equal(t, []Params{history[3].Params, history[4].Params, history[10].Params, history[11].Params},
+			params)
+
+		for _, p := range params {
+			restored = append(restored, Trial{Params: p})
+		}
+		require.Equal(t, []Params{history[12].Params}, sampler.Sample(restored, 1))
+
+		// same history returns the same parameters
+		require.Equal(t, params, Grid(space, 0).Sample(restored[:8], 4))
+	})
+
+	t.Run("continuous float", func(t *testing.T) {
+		params := Grid(Space{{Name: "x", Type: TypeFloat, Min: 0, Max: 1}}, 0).Sample(nil, 100)
+		require.Len(t, params, 11)
+		require.Equal(t, 0.3, params[3]["x"])
+	})
+}
+
+func TestRandom(t *testing.T) {
+	history, _ := search(t, Random(space, 100, 42), 8)
+	require.Len(t, history, 100)
+	requireValid(t, history)
+
+	// same seed and history return the same parameters
+	require.Equal(t, Random(space, 100, 42).Sample(history[:10], 5), Random(space, 100, 42).Sample(history[:10], 5))
+	require.NotEqual(t, Random(space, 100, 1).Sample(nil, 5), Random(space, 100, 2).Sample(nil, 5))
+
+	t.Run("small space", func(t *testing.T) {
+		sampler := Random(Space{{Name: "stop", Type: TypeBool}}, 10, 1)
+		params := sampler.Sample(nil, 4)
+		require.Len(t, params, 2)
+		require.Empty(t, sampler.Sample([]Trial{{Params: params[0]}, {Params: params[1]}}, 4))
+	})
+}
+
+func TestNew(t *testing.T) {
+	for _, method := range []string{"", "grid", "random", "TPE", "genetic"} {
+		sampler, err := New(method, space, 0, 1)
+		require.NoError(t, err)
+		require.NotEmpty(t, sampler.Sample(nil, 1))
+	}
+
+	sampler, err := New("random", space, 0, 1)
+	require.NoError(t, err)
+	history, _ := search(t, sampler, 10)
+	require.Len(t, history, DefaultTrials)
+
+	_, err = New("annealing", space, 0, 1)
+	require.Error(t, err)
+}
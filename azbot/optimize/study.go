@@ -0,0 +1,308 @@
+package optimize
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StudyTrial is a trial stored in the study database. Params and Metrics are JSON objects and Error is
+// the message of failed trials.
+type StudyTrial struct {
+	ID     uint   `gorm:"primarykey"`
+	Study  string `gorm:"index"`
+	Number int
+	Params string
+	Score  float64
+	// Invalid is true when the score is NaN or infinite, the score is stored as 0 and restored as -Inf
+	Invalid   bool
+	Metrics   string
+	Duration  time.Duration
+	Error     string
+	CreatedAt time.Time
+}
+
+// Parameters decodes the parameters with the types of the dimensions of the space,
+// numbers of unknown parameters are float64
+func (t StudyTrial) Parameters(space Space) (Params, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(t.Params), &raw); err != nil {
+		return nil, err
+	}
+
+	types := make(map[string]Type, len(space))
+	for _, dimension := range space {
+		types[dimension.Name] = dimension.Type
+	}
+
+	params := make(Params, len(raw))
+	for name, value := range raw {
+		if number, ok := value.(float64); ok && types[name] == TypeInt {
+			value = int(math.Round(number))
+		}
+		params[name] = value
+	}
+	return params, nil
+}
+
+// Values decodes the metrics of the trial
+func (t StudyTrial) Values() (map[string]float64, error) {
+	metrics := make(map[string]float64)
+	if t.Metrics == "" {
+		return metrics, nil
+	}
+	err := json.Unmarshal([]byte(t.Metrics), &metrics)
+	return metrics, err
+}
+
+// Failed returns true when the evaluation of the trial failed
+func (t StudyTrial) Failed() bool {
+	return t.Error != ""
+}
+
+// Value returns the score of the trial, -Inf for failed trials and invalid scores as in a search
+func (t StudyTrial) Value() float64 {
+	if t.Failed() || t.Invalid {
+		return math.Inf(-1)
+	}
+	return t.Score
+}
+
+// Study stores the trials of an optimization, so it can be resumed after a crash
+type Study struct {
+	db   *gorm.DB
+	name string
+	mtx  *sync.Mutex
+}
+
+// OpenStudy opens the study with the given name in a SQL database. Example of usage:
+//
+//	import "github.com/glebarez/sqlite"
+//	study, err := optimize.OpenStudy(sqlite.Open("study.db"), "CrossEMA", &gorm.Config{})
+func OpenStudy(dialect gorm.Dialector, name string, opts ...gorm.Option) (*Study, error) {
+	db, err := gorm.Open(dialect, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.AutoMigrate(&StudyTrial{}); err != nil {
+		return nil, err
+	}
+
+	return &Study{db: db, name: name, mtx: new(sync.Mutex)}, nil
+}
+
+// Name returns the name of the study
+func (s *Study) Name() string {
+	return s.name
+}
+
+// WithName returns a study with another name in the same database, eg: a window of a walk-forward
+func (s *Study) WithName(name string) *Study {
+	return &Study{db: s.db, name: name, mtx: s.mtx}
+}
+
+// Studies returns the names of the studies in the database
+func (s *Study) Studies() ([]string, error) {
+	var names []string
+	result := s.db.Model(&StudyTrial{}).Distinct("study").Order("study").Pluck("study", &names)
+	return names, result.Error
+}
+
+// Reset removes the trials of the study
+func (s *Study) Reset() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.db.Where("study = ?", s.name).Delete(&StudyTrial{}).Error
+}
+
+// Add stores an evaluated trial, trialErr is the error of failed trials. It is safe for concurrent use.
+func (s *Study) Add(params Params, score float64, metrics map[string]float64, duration time.Duration,
+	trialErr error) (StudyTrial, error) {
+
+	paramsData, err := json.Marshal(params)
+	if err != nil {
+		return StudyTrial{}, err
+	}
+
+	trial := StudyTrial{
+		Study:    s.name,
+		Params:   string(paramsData),
+		Score:    score,
+		Duration: duration,
+	}
+
+	if trialErr != nil || math.IsNaN(score) || math.IsInf(score, 0) {
+		trial.Score = 0
+		trial.Invalid = trialErr == nil
+	}
+
+	if trialErr != nil {
+		trial.Error = trialErr.Error()
+	} else {
+		metricsData, err := json.Marshal(finite(metrics))
+		if err != nil {
+			return StudyTrial{}, err
+		}
+		trial.Metrics = string(metricsData)
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var count int64
+	if err := s.db.Model(&StudyTrial{}).Where("study = ?", s.name).Count(&count).Error; err != nil {
+		return StudyTrial{}, err
+	}
+	trial.Number = int(count) + 1
+
+	return trial, s.db.Create(&trial).Error
+}
+
+// Trials returns the trials of the study in the order they were stored
+func (s *Study) Trials() ([]StudyTrial, error) {
+	var trials []StudyTrial
+	result := s.db.Where("study = ?", s.name).Order("number").Find(&trials)
+	return trials, result.Error
+}
+
+// Top returns the n trials with the highest score, failed trials and invalid scores are ignored
+func (s *Study) Top(n int) ([]StudyTrial, error) {
+	var trials []StudyTrial
+	result := s.db.Where("study = ? AND error = ? AND invalid = ?", s.name, "", false).
+		Order("score DESC, number").Limit(n).Find(&trials)
+	return trials, result.Error
+}
+
+// History returns the trials to resume a search with a Sampler, in the order they were stored. Trials are
+// scored by the given metric, so a study can be resumed with another objective, or by the stored score when
+// metric is empty. Failed trials and metrics not stored (invalid values) have a -Inf score.
+func (s *Study) History(space Space, metric string) ([]Trial, error) {
+	trials, err := s.Trials()
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]Trial, 0, len(trials))
+	for _, trial := range trials {
+		params, err := trial.Parameters(space)
+		if err != nil {
+			return nil, err
+		}
+
+		score := trial.Value()
+		if metric != "" && !trial.Failed() {
+			metrics, err := trial.Values()
+			if err != nil {
+				return nil, err
+			}
+
+			var ok bool
+			if score, ok = metrics[metric]; !ok {
+				score = math.Inf(-1)
+			}
+		}
+
+		history = append(history, Trial{Params: params, Score: score})
+	}
+	return history, nil
+}
+
+// WriteCSV writes the trials with a column for each parameter and metric
+func WriteCSV(w io.Writer, trials []StudyTrial) error {
+	var params, metrics []string
+	rows := make([]struct {
+		params  map[string]interface{}
+		metrics map[string]float64
+	}, len(trials))
+
+	seen := make(map[string]bool)
+	for i, trial := range trials {
+		if err := json.Unmarshal([]byte(trial.Params), &rows[i].params); err != nil {
+			return err
+		}
+
+		var err error
+		rows[i].metrics, err = trial.Values()
+		if err != nil {
+			return err
+		}
+
+		for name := range rows[i].params {
+			if !seen["p:"+name] {
+				seen["p:"+name] = true
+				params = append(params, name)
+			}
+		}
+		for name := range rows[i].metrics {
+			if !seen["m:"+name] {
+				seen["m:"+name] = true
+				metrics = append(metrics, name)
+			}
+		}
+	}
+	sort.Strings(params)
+	sort.Strings(metrics)
+
+	writer := csv.NewWriter(w)
+	header := append([]string{"number", "score", "duration", "error"}, params...)
+	if err := writer.Write(append(header, metrics...)); err != nil {
+		return err
+	}
+
+	for i, trial := range trials {
+		score := strconv.FormatFloat(trial.Score, 'f', -1, 64)
+		if trial.Invalid {
+			score = ""
+		}
+
+		record := []string{
+			strconv.Itoa(trial.Number),
+			score,
+			trial.Duration.String(),
+			trial.Error,
+		}
+		for _, name := range params {
+			value, ok := rows[i].params[name]
+			if !ok {
+				record = append(record, "")
+				continue
+			}
+			record = append(record, fmt.Sprint(value))
+		}
+		for _, name := range metrics {
+			value, ok := rows[i].metrics[name]
+			if !ok {
+				record = append(record, "")
+				continue
+			}
+			record = append(record, strconv.FormatFloat(value, 'f', -1, 64))
+		}
+
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// finite removes NaN and infinite metrics that are not supported by JSON
+func finite(metrics map[string]float64) map[string]float64 {
+	result := make(map[string]float64, len(metrics))
+	for name, value := range metrics {
+		if !math.IsNaN(value) && !math.IsInf(value, 0) {
+			result[name] = value
+		}
+	}
+	return result
+}
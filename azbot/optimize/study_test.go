@@ -0,0 +1,128 @@
+package optimize
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openStudy(t *testing.T, file, name string) *Study {
+	t.Helper()
+
+	study, err := OpenStudy(sqlite.Open(file), name, &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+	return study
+}
+
+func TestStudy(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "study.db")
+	study := openStudy(t, file, "CrossEMA")
+
+	_, err := study.Add(Params{"fast": 10, "risk": 0.2, "ma": "ema"}, 1.5,
+		map[string]float64{"sharpe": 1.5, "return": 0.2, "nan": math.NaN()}, time.Second, nil)
+	require.NoError(t, err)
+	_, err = study.Add(Params{"fast": 12, "risk": 0.3, "ma": "sma"}, 0, nil, time.Second, errors.New("no data"))
+	require.NoError(t, err)
+	trial, err := study.Add(Params{"fast": 14, "risk": 0.3, "ma": "sma"}, 2, nil, time.Second, nil)
+	require.NoError(t, err)
+	require.Equal(t, 3, trial.Number)
+
+	// trials of other studies are isolated
+	_, err = study.WithName("other").Add(Params{"fast": 2}, 10, nil, time.Second, nil)
+	require.NoError(t, err)
+
+	t.Run("resume", func(t *testing.T) {
+		study := openStudy(t, file, "CrossEMA")
+
+		history, err := study.History(space, "")
+		require.NoError(t, err)
+		require.Len(t, history, 3)
+		require.Equal(t, Params{"fast": 10, "risk": 0.2, "ma": "ema"}, history[0].Params)
+		require.Equal(t, 1.5, history[0].Score)
+		require.True(t, math.IsInf(history[1].Score, -1))
+
+		// trials are scored again with another objective
+		history, err = study.History(space, "return")
+		require.NoError(t, err)
+		require.Equal(t, 0.2, history[0].Score)
+		require.True(t, math.IsInf(history[1].Score, -1))
+		require.True(t, math.IsInf(history[2].Score, -1))
+
+		// sampler skips the stored trials
+		params := Grid(space, 5).Sample(history, 10)
+		require.Len(t, params, 2)
+	})
+
+	t.Run("top", func(t *testing.T) {
+		top, err := study.Top(5)
+		require.NoError(t, err)
+		require.Len(t, top, 2)
+		require.Equal(t, 3, top[0].Number)
+		require.Equal(t, 1, top[1].Number)
+
+		metrics, err := top[1].Values()
+		require.NoError(t, err)
+		require.Equal(t, map[string]float64{"sharpe": 1.5, "return": 0.2}, metrics)
+	})
+
+	t.Run("export", func(t *testing.T) {
+		trials, err := study.Trials()
+		require.NoError(t, err)
+
+		buffer := bytes.NewBuffer(nil)
+		require.NoError(t, WriteCSV(buffer, trials))
+		require.Equal(t, "number,score,duration,error,fast,ma,risk,return,sharpe\n"+
+			"1,1.5,1s,,10,ema,0.2,0.2,1.5\n"+
+			"2,0,1s,no data,12,sma,0.3,,\n"+
+			"3,2,1s,,14,sma,0.3,,\n", buffer.String())
+	})
+
+	t.Run("studies", func(t *testing.T) {
+		names, err := study.Studies()
+		require.NoError(t, err)
+		require.Equal(t, []string{"CrossEMA", "other"}, names)
+
+		require.NoError(t, study.WithName("other").Reset())
+		names, err = study.Studies()
+		require.NoError(t, err)
+		require.Equal(t, []string{"CrossEMA"}, names)
+	})
+}
+
+func TestStudy_invalidScore(t *testing.T) {
+	study := openStudy(t, filepath.Join(t.TempDir(), "study.db"), "CrossEMA")
+
+	for _, score := range []float64{1, math.NaN(), -1, math.Inf(1)} {
+		_, err := study.Add(Params{"fast": 10}, score, map[string]float64{"sharpe": score}, time.Second, nil)
+		require.NoError(t, err)
+	}
+
+	// invalid scores are restored as -Inf, as they are scored in a search
+	history, err := study.History(space, "")
+	require.NoError(t, err)
+	require.Equal(t, 1.0, history[0].Score)
+	require.True(t, math.IsInf(history[1].Score, -1))
+	require.Equal(t, -1.0, history[2].Score)
+	require.True(t, math.IsInf(history[3].Score, -1))
+
+	history, err = study.History(space, "sharpe")
+	require.NoError(t, err)
+	require.Equal(t, 1.0, history[0].Score)
+	require.True(t, math.IsInf(history[1].Score, -1))
+	require.Equal(t, -1.0, history[2].Score)
+	require.True(t, math.IsInf(history[3].Score, -1))
+
+	top, err := study.Top(5)
+	require.NoError(t, err)
+	require.Len(t, top, 2)
+	require.Equal(t, 1, top[0].Number)
+	require.Equal(t, 3, top[1].Number)
+}
@@ -0,0 +1,173 @@
+package optimize
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+type tpe struct {
+	space      Space
+	trials     int
+	seed       int64
+	startup    int
+	gamma      float64
+	candidates int
+}
+
+// TPE is a Bayesian optimization with Tree-structured Parzen Estimators. After random startup trials, the
+// trials are split in good and bad by score and each parameter is sampled from candidates that maximize the
+// ratio between the density of good and bad values.
+func TPE(space Space, trials int, seed int64) Sampler {
+	return tpe{
+		space:      space,
+		trials:     trials,
+		seed:       seed,
+		startup:    max(10, trials/10),
+		gamma:      0.25,
+		candidates: 24,
+	}
+}
+
+func (t tpe) Sample(history []Trial, n int) []Params {
+	if len(history) < t.startup {
+		return Random(t.space, min(t.trials, t.startup), t.seed).Sample(history, n)
+	}
+
+	rng := newRand(t.seed, history)
+	seen := seenTrials(history)
+	size := t.space.Size()
+
+	sorted := append([]Trial(nil), history...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Score > sorted[j].Score
+	})
+	split := max(1, int(math.Ceil(t.gamma*float64(len(sorted)))))
+	good, bad := sorted[:split], sorted[split:]
+
+	var batch []Params
+	for attempt := 0; len(batch) < remaining(t.trials, history, n) && attempt < maxAttempts*n; attempt++ {
+		if size > 0 && len(seen) >= size {
+			break
+		}
+
+		params := make(Params, len(t.space))
+		for _, dimension := range t.space {
+			params[dimension.Name] = t.sampleDimension(rng, dimension, good, bad)
+		}
+
+		// duplicated proposals are replaced by random parameters to keep exploring
+		if !unique(seen, params) {
+			params = t.space.sample(rng)
+			if !unique(seen, params) {
+				continue
+			}
+		}
+		batch = append(batch, params)
+	}
+	return batch
+}
+
+func (t tpe) sampleDimension(rng *rand.Rand, dimension Dimension, good, bad []Trial) interface{} {
+	if dimension.Type == TypeBool || dimension.Type == TypeEnum {
+		return t.sampleChoice(rng, dimension, good, bad)
+	}
+
+	goodValues := values(dimension, good)
+	badValues := values(dimension, bad)
+	width := dimension.Max - dimension.Min
+	if width <= 0 || len(goodValues) == 0 {
+		return dimension.sample(rng)
+	}
+
+	goodBandwidth := bandwidth(width, len(goodValues))
+	badBandwidth := bandwidth(width, len(badValues))
+
+	var best float64
+	bestScore := math.Inf(-1)
+	for i := 0; i < t.candidates; i++ {
+		// sample from the good density: a random good value plus gaussian noise
+		center := goodValues[rng.Intn(len(goodValues))]
+		candidate := math.Min(math.Max(center+rng.NormFloat64()*goodBandwidth, dimension.Min), dimension.Max)
+
+		score := math.Log(parzen(candidate, goodValues, goodBandwidth, width)) -
+			math.Log(parzen(candidate, badValues, badBandwidth, width))
+		if score > bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+	return dimension.value(best)
+}
+
+func (t tpe) sampleChoice(rng *rand.Rand, dimension Dimension, good, bad []Trial) interface{} {
+	choices := dimension.choices()
+	if len(choices) == 0 {
+		return nil
+	}
+
+	// counts with a prior of one observation per choice
+	count := func(trials []Trial) []float64 {
+		counts := make([]float64, len(choices))
+		for i := range counts {
+			counts[i] = 1
+		}
+		for _, trial := range trials {
+			for i, choice := range choices {
+				if trial.Params[dimension.Name] == choice {
+					counts[i]++
+				}
+			}
+		}
+		return counts
+	}
+
+	goodCounts, badCounts := count(good), count(bad)
+	var goodTotal, badTotal float64
+	for i := range choices {
+		goodTotal += goodCounts[i]
+		badTotal += badCounts[i]
+	}
+
+	best := rng.Intn(len(choices))
+	bestScore := math.Inf(-1)
+	for i := 0; i < t.candidates; i++ {
+		// sample a choice with the probability of the good trials
+		target := rng.Float64() * goodTotal
+		index := 0
+		for ; index < len(choices)-1 && target >= goodCounts[index]; index++ {
+			target -= goodCounts[index]
+		}
+
+		score := math.Log(goodCounts[index]/goodTotal) - math.Log(badCounts[index]/badTotal)
+		if score > bestScore {
+			best, bestScore = index, score
+		}
+	}
+	return choices[best]
+}
+
+// values returns the numeric values of the dimension in the trials
+func values(dimension Dimension, trials []Trial) []float64 {
+	result := make([]float64, 0, len(trials))
+	for _, trial := range trials {
+		if value, ok := numeric(trial.Params[dimension.Name]); ok {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// bandwidth of the gaussian kernels, it shrinks with the number of observations
+func bandwidth(width float64, observations int) float64 {
+	return width / math.Max(1, math.Pow(float64(observations), 0.2)) / 4
+}
+
+// parzen returns the density of x in a mixture of gaussians centered in the values and a uniform prior
+func parzen(x float64, values []float64, bandwidth, width float64) float64 {
+	density := 1 / width
+	for _, value := range values {
+		z := (x - value) / bandwidth
+		density += math.Exp(-z*z/2) / (bandwidth * math.Sqrt(2*math.Pi))
+	}
+	return density / float64(len(values)+1)
+}
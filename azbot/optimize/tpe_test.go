@@ -0,0 +1,22 @@
+package optimize
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTPE(t *testing.T) {
+	history, best := search(t, TPE(space, 80, 7), 4)
+	require.Len(t, history, 80)
+	requireValid(t, history)
+
+	// the same budget finds better parameters than the random search
+	_, random := search(t, Random(space, 80, 7), 4)
+	require.Greater(t, best, random)
+	require.Greater(t, best, -0.1)
+
+	// reproducible with the seed
+	again, _ := search(t, TPE(space, 80, 7), 4)
+	require.Equal(t, history, again)
+}
@@ -18,13 +18,24 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// Trade is the result of a closed trade
+type Trade struct {
+	Pair   string
+	Time   time.Time
+	Profit float64
+	// Notional is the value of the fills that closed the trade
+	Notional float64
+}
+
 type summary struct {
 	Pair      string
 	WinLong   []float64
 	WinShort  []float64
 	LoseLong  []float64
 	LoseShort []float64
-	Volume    float64
+	// Trades contains the closed trades in the order they were closed
+	Trades []Trade
+	Volume float64
 }
 
 func (s summary) Win() []float64 {
@@ -109,6 +120,15 @@ const (
 	StatusError   Status = "error"
 )
 
+// partialTrade accumulates the profit of an order filled in many executions
+type partialTrade struct {
+	value    float64
+	percent  float64
+	quantity float64
+	notional float64
+	fee      float64
+}
+
 type Controller struct {
 	mtx            sync.Mutex
 	ctx            context.Context
@@ -118,6 +138,7 @@ type Controller struct {
 	notifier       service.Notifier
 	Results        map[string]*summary
 	lastPrice      map[string]float64
+	partialTrades  map[int64]partialTrade
 	tickerInterval time.Duration
 	finish         chan bool
 	status         Status
@@ -133,6 +154,7 @@ func NewController(ctx context.Context, exchange service.Exchange, storage stora
 		orderFeed:      orderFeed,
 		lastPrice:      make(map[string]float64),
 		Results:        make(map[string]*summary),
+		partialTrades:  make(map[int64]partialTrade),
 		tickerInterval: time.Second,
 		finish:         make(chan bool),
 	}
@@ -146,11 +168,31 @@ func (c *Controller) OnCandle(candle model.Candle) {
 	c.lastPrice[candle.Pair] = candle.Close
 }
 
+// feeValue returns the commission of the order in the quote asset, commissions paid in other
+// assets (eg: BNB) are not considered
+func feeValue(order model.Order) float64 {
+	asset, quote := exchange.SplitAssetQuote(order.Pair)
+	switch order.FeeAsset {
+	case quote, "":
+		return order.Fee
+	case asset:
+		return order.Fee * order.FillPrice()
+	}
+	return 0
+}
+
+// calculateProfit returns the profit of `o.Quantity` executed by the order, net of the fees paid to open
+// and close the position. Previous executions of the same order are considered part of the position.
 func (c *Controller) calculateProfit(o *model.Order) (value, percent float64, err error) {
-	// get filled orders before the current order
+	// get executed orders before the current order
 	orders, err := c.storage.Orders(
 		storage.WithUpdateAtBeforeOrEqual(o.UpdatedAt),
-		storage.WithStatus(model.OrderStatusTypeFilled),
+		storage.WithStatusIn(
+			model.OrderStatusTypeFilled,
+			model.OrderStatusTypePartiallyFilled,
+			model.OrderStatusTypeCanceled,
+			model.OrderStatusTypePendingCancel,
+		),
 		storage.WithPair(o.Pair),
 	)
 	if err != nil {
@@ -162,34 +204,42 @@ func (c *Controller) calculateProfit(o *model.Order) (value, percent float64, er
 	avgPriceShort := 0.0
 
 	for _, order := range orders {
-		// skip current order
+		executed := order.Executed()
+		price := order.FillPrice()
+		fee := feeValue(*order)
+
+		// skip current execution, the average price and the fee of the order include it
 		if o.ID == order.ID {
+			executed -= o.Quantity
+			if executed > 0 {
+				price = (price*order.Executed() - o.FillPrice()*o.Quantity) / executed
+				fee -= feeValue(*o)
+			}
+		}
+
+		if executed <= 0 {
 			continue
 		}
 
-		// calculate avg price
-		price := order.Price
-		if order.Type == model.OrderTypeStopLoss || order.Type == model.OrderTypeStopLossLimit {
-			price = *order.Stop
+		// the fee paid to open the position is part of its cost
+		if order.Side == model.SideTypeBuy {
+			price += fee / executed
+		} else {
+			price -= fee / executed
 		}
 
-		var diff = order.Quantity
+		var diff = executed
 		if order.Side == model.SideTypeSell {
-			diff = -order.Quantity
+			diff = -executed
 		}
 
 		if order.Side == model.SideTypeBuy && quantity+diff >= 0 {
-			avgPriceLong = (order.Quantity*price + avgPriceLong*math.Abs(quantity)) / (order.Quantity + math.Abs(quantity))
+			avgPriceLong = (executed*price + avgPriceLong*math.Abs(quantity)) / (executed + math.Abs(quantity))
 		} else if order.Side == model.SideTypeSell && quantity+diff <= 0 {
-			avgPriceShort = (order.Quantity*price + avgPriceShort*math.Abs(quantity)) / (order.Quantity + math.Abs(quantity))
-		}
-
-		if order.Side == model.SideTypeBuy {
-			quantity += order.Quantity
-		} else {
-			quantity -= order.Quantity
+			avgPriceShort = (executed*price + avgPriceShort*math.Abs(quantity)) / (executed + math.Abs(quantity))
 		}
 
+		quantity += diff
 	}
 
 	if quantity == 0 {
@@ -198,21 +248,15 @@ func (c *Controller) calculateProfit(o *model.Order) (value, percent float64, er
 
 	if o.Side == model.SideTypeBuy && quantity < 0 {
 		// profit short
-		price := o.Price
-		if o.Type == model.OrderTypeStopLoss || o.Type == model.OrderTypeStopLossLimit {
-			price = *o.Stop
-		}
-		profitValue := (avgPriceShort - price) * o.Quantity
+		price := o.FillPrice()
+		profitValue := (avgPriceShort-price)*o.Quantity - feeValue(*o)
 		return profitValue, profitValue / o.Quantity / avgPriceShort, nil
 	}
 
 	if o.Side == model.SideTypeSell && quantity > 0 {
 		// profit long
-		price := o.Price
-		if o.Type == model.OrderTypeStopLoss || o.Type == model.OrderTypeStopLossLimit {
-			price = *o.Stop
-		}
-		profitValue := (price - avgPriceLong) * o.Quantity
+		price := o.FillPrice()
+		profitValue := (price-avgPriceLong)*o.Quantity - feeValue(*o)
 		return profitValue, profitValue / o.Quantity / avgPriceLong, nil
 	}
 
@@ -233,8 +277,21 @@ func (c *Controller) notifyError(err error) {
 	}
 }
 
-func (c *Controller) processTrade(order *model.Order) {
-	if order.Status != model.OrderStatusTypeFilled {
+// executionPrice returns the average price of the quantity executed by the order since the last update,
+// previous executions are taken from the partial trade of the order
+func (c *Controller) executionPrice(order *model.Order, executed float64) float64 {
+	trade := c.partialTrades[order.ID]
+	previous := order.Executed() - executed
+	if previous <= 0 || trade.quantity <= 0 {
+		return order.FillPrice()
+	}
+	return (order.FillPrice()*order.Executed() - trade.notional/trade.quantity*previous) / executed
+}
+
+// processTrade registers the volume and profit of the quantity executed by the order since the last update.
+// Orders filled in many executions are registered as a single trade when the order is finished.
+func (c *Controller) processTrade(order *model.Order, executed float64) {
+	if _, ok := c.partialTrades[order.ID]; !ok && executed <= 0 {
 		return
 	}
 
@@ -243,19 +300,53 @@ func (c *Controller) processTrade(order *model.Order) {
 		c.Results[order.Pair] = &summary{Pair: order.Pair}
 	}
 
-	// register order volume
-	c.Results[order.Pair].Volume += order.Price * order.Quantity
+	if executed > 0 {
+		execution := *order
+		execution.Quantity = executed
+		execution.AvgPrice = c.executionPrice(order, executed)
+		execution.Fee = order.Fee - c.partialTrades[order.ID].fee
 
-	profitValue, profit, err := c.calculateProfit(order)
-	if err != nil {
-		c.notifyError(err)
+		// register order volume
+		c.Results[order.Pair].Volume += execution.AvgPrice * executed
+
+		profitValue, profit, err := c.calculateProfit(&execution)
+		if err != nil {
+			c.notifyError(err)
+			return
+		}
+
+		trade := c.partialTrades[order.ID]
+		trade.value += profitValue
+		trade.percent += profit * executed
+		trade.quantity += executed
+		trade.notional += execution.AvgPrice * executed
+		trade.fee = order.Fee
+		c.partialTrades[order.ID] = trade
+	}
+
+	// wait until the order is finished
+	switch order.Status {
+	case model.OrderStatusTypeNew, model.OrderStatusTypePartiallyFilled, model.OrderStatusTypePendingCancel:
 		return
 	}
 
+	trade := c.partialTrades[order.ID]
+	delete(c.partialTrades, order.ID)
+
+	profitValue := trade.value
+	profit := trade.percent / trade.quantity
 	order.Profit = profit
 	if profitValue == 0 {
 		return
-	} else if profitValue > 0 {
+	}
+
+	c.Results[order.Pair].Trades = append(c.Results[order.Pair].Trades, Trade{
+		Pair:     order.Pair,
+		Time:     order.UpdatedAt,
+		Profit:   profitValue,
+		Notional: trade.notional,
+	})
+	if profitValue > 0 {
 		if order.Side == model.SideTypeBuy {
 			c.Results[order.Pair].WinLong = append(c.Results[order.Pair].WinLong, profitValue)
 		} else {
@@ -290,7 +381,10 @@ func (c *Controller) updateOrders() {
 	}
 
 	// For each pending order, check for updates
-	var updatedOrders []model.Order
+	var (
+		updatedOrders []model.Order
+		executed      []float64
+	)
 	for _, order := range orders {
 		excOrder, err := c.exchange.Order(order.Pair, order.ExchangeID)
 		if err != nil {
@@ -298,8 +392,8 @@ func (c *Controller) updateOrders() {
 			continue
 		}
 
-		// no status change
-		if excOrder.Status == order.Status {
+		// no status change or new executions
+		if excOrder.Status == order.Status && excOrder.ExecutedQuantity == order.ExecutedQuantity {
 			continue
 		}
 
@@ -312,10 +406,11 @@ func (c *Controller) updateOrders() {
 
 		log.Infof("[ORDER %s] %s", excOrder.Status, excOrder)
 		updatedOrders = append(updatedOrders, excOrder)
+		executed = append(executed, excOrder.Executed()-order.Executed())
 	}
 
-	for _, processOrder := range updatedOrders {
-		c.processTrade(&processOrder)
+	for i, processOrder := range updatedOrders {
+		c.processTrade(&processOrder, executed[i])
 		c.orderFeed.Publish(processOrder, false)
 	}
 }
@@ -360,6 +455,11 @@ func (c *Controller) Position(pair string) (asset, quote float64, err error) {
 	return c.exchange.Position(pair)
 }
 
+// AssetsInfo returns the trading limits of the pair in the exchange
+func (c *Controller) AssetsInfo(pair string) model.AssetInfo {
+	return c.exchange.AssetsInfo(pair)
+}
+
 func (c *Controller) LastQuote(pair string) (float64, error) {
 	return c.exchange.LastQuote(c.ctx, pair)
 }
@@ -439,7 +539,7 @@ func (c *Controller) CreateOrderMarketQuote(side model.SideType, pair string, am
 	}
 
 	// calculate profit
-	c.processTrade(&order)
+	c.processTrade(&order, order.Executed())
 	go c.orderFeed.Publish(order, true)
 	log.Infof("[ORDER CREATED] %s", order)
 	return order, err
@@ -463,7 +563,7 @@ func (c *Controller) CreateOrderMarket(side model.SideType, pair string, size fl
 	}
 
 	// calculate profit
-	c.processTrade(&order)
+	c.processTrade(&order, order.Executed())
 	go c.orderFeed.Publish(order, true)
 	log.Infof("[ORDER CREATED] %s", order)
 	return order, err
@@ -154,6 +154,40 @@ func TestController_calculateProfit(t *testing.T) {
 	})
 }
 
+func TestController_PartialFill(t *testing.T) {
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 3000),
+		exchange.WithPaperLiquidity(0.1))
+	controller := NewController(ctx, wallet, storage, NewOrderFeed())
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	wallet.OnCandle(model.Candle{Time: start, Pair: "BTCUSDT", Close: 1000, Volume: 100})
+
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 2)
+	require.NoError(t, err)
+
+	sellOrder, err := controller.CreateOrderLimit(model.SideTypeSell, "BTCUSDT", 2, 1500)
+	require.NoError(t, err)
+
+	// half of the order is executed in each candle
+	wallet.OnCandle(model.Candle{Time: start.Add(time.Hour), Pair: "BTCUSDT", Close: 1500, High: 1500, Volume: 10})
+	controller.updateOrders()
+
+	order, err := controller.Order("BTCUSDT", sellOrder.ExchangeID)
+	require.NoError(t, err)
+	require.Equal(t, model.OrderStatusTypePartiallyFilled, order.Status)
+	require.Equal(t, 3500.0, controller.Results["BTCUSDT"].Volume)
+	require.Empty(t, controller.Results["BTCUSDT"].Win())
+
+	wallet.OnCandle(model.Candle{Time: start.Add(2 * time.Hour), Pair: "BTCUSDT", Close: 1500, High: 1500, Volume: 10})
+	controller.updateOrders()
+
+	// executions are registered as a single trade
+	require.Equal(t, []float64{1000}, controller.Results["BTCUSDT"].Win())
+	require.Equal(t, 5000.0, controller.Results["BTCUSDT"].Volume)
+}
+
 func TestController_PositionValue(t *testing.T) {
 	storage, err := storage.FromMemory()
 	require.NoError(t, err)
@@ -196,3 +230,85 @@ func TestController_Position(t *testing.T) {
 	assert.Equal(t, 1.0, asset)
 	assert.Equal(t, 1500.0, quote)
 }
+
+func TestController_Trades(t *testing.T) {
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 3000))
+	controller := NewController(ctx, wallet, storage, NewOrderFeed())
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// win, loss and win, closed in this order
+	for i, exit := range []float64{1100, 900, 1200} {
+		entry := model.Candle{Time: start.Add(time.Duration(2*i) * time.Hour), Pair: "BTCUSDT", Close: 1000}
+		wallet.OnCandle(entry)
+		_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+
+		wallet.OnCandle(model.Candle{Time: entry.Time.Add(time.Hour), Pair: "BTCUSDT", Close: exit})
+		_, err = controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+		require.NoError(t, err)
+	}
+
+	var profits, notionals []float64
+	for _, trade := range controller.Results["BTCUSDT"].Trades {
+		profits = append(profits, trade.Profit)
+		notionals = append(notionals, trade.Notional)
+	}
+	require.Equal(t, []float64{100, -100, 200}, profits)
+	require.Equal(t, []float64{1100, 900, 1200}, notionals)
+	require.Equal(t, []float64{100, 200}, controller.Results["BTCUSDT"].Win())
+}
+
+func TestController_StopGap(t *testing.T) {
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 3000),
+		exchange.WithPaperFillPolicy(exchange.FillPolicyPessimistic))
+	controller := NewController(ctx, wallet, storage, NewOrderFeed())
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	wallet.OnCandle(model.Candle{Time: start, Pair: "BTCUSDT", Open: 100, High: 100, Low: 100, Close: 100})
+
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+
+	_, err = controller.CreateOrderOCO(model.SideTypeSell, "BTCUSDT", 1, 150, 90, 90)
+	require.NoError(t, err)
+
+	// candle opens below the stop, the order is executed in the open price
+	wallet.OnCandle(model.Candle{Time: start.Add(time.Hour), Pair: "BTCUSDT", Open: 80, High: 82, Low: 75, Close: 78})
+	controller.updateOrders()
+
+	require.Equal(t, []float64{-20}, controller.Results["BTCUSDT"].Lose())
+	require.Len(t, controller.Results["BTCUSDT"].Trades, 1)
+	require.Equal(t, 80.0, controller.Results["BTCUSDT"].Trades[0].Notional)
+	require.Equal(t, 180.0, controller.Results["BTCUSDT"].Volume)
+}
+
+func TestController_FeesAndSlippage(t *testing.T) {
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 3000),
+		exchange.WithPaperFee(0.001, 0.001), exchange.WithPaperSlippage(exchange.FixedSlippage{BPS: 10}))
+	controller := NewController(ctx, wallet, storage, NewOrderFeed())
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	wallet.OnCandle(model.Candle{Time: start, Pair: "BTCUSDT", Close: 1000})
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+
+	wallet.OnCandle(model.Candle{Time: start.Add(time.Hour), Pair: "BTCUSDT", Close: 1100})
+	_, err = controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+	require.NoError(t, err)
+
+	// bought at 1001 and sold at 1098.9, minus 0.1% of fees in each side
+	account, err := wallet.Account()
+	require.NoError(t, err)
+	_, quote := account.Balance("BTC", "USDT")
+	require.Len(t, controller.Results["BTCUSDT"].Trades, 1)
+	require.InDelta(t, 95.8001, controller.Results["BTCUSDT"].Trades[0].Profit, 1e-9)
+	require.InDelta(t, quote.Free-3000, controller.Results["BTCUSDT"].Profit(), 1e-9)
+}
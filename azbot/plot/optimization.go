@@ -0,0 +1,192 @@
+package plot
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/ezquant/azbot/azbot/exchange"
+)
+
+// OptimizationTrial is a combination of parameters evaluated by an optimization
+type OptimizationTrial struct {
+	Params map[string]interface{}
+	Score  float64
+}
+
+// OptimizationReport is the content of the HTML report of an optimization
+type OptimizationReport struct {
+	Strategy  string
+	Objective string
+	Trials    []OptimizationTrial
+	// Best and Median are the equity curves of the best trial and of the trial with the median score
+	Best   []exchange.AssetValue
+	Median []exchange.AssetValue
+}
+
+// Heatmap is the best score for each combination of the values of two parameters. With a single
+// parameter, Y is empty and the heatmap has one row
+type Heatmap struct {
+	X       string       `json:"x"`
+	Y       string       `json:"y"`
+	XValues []string     `json:"x_values"`
+	YValues []string     `json:"y_values"`
+	Score   [][]*float64 `json:"score"`
+}
+
+// Heatmaps returns a heatmap for each pair of parameters with more than one value in the trials.
+// Score is indexed by [y][x] and is nil for combinations without trials
+func Heatmaps(trials []OptimizationTrial) []Heatmap {
+	values := make(map[string][]interface{})
+	for _, trial := range trials {
+		for name, value := range trial.Params {
+			if !containsValue(values[name], value) {
+				values[name] = append(values[name], value)
+			}
+		}
+	}
+
+	var names []string
+	for name, options := range values {
+		if len(options) > 1 {
+			sortValues(options)
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	if len(names) == 1 {
+		return []Heatmap{newHeatmap(trials, names[0], "", values[names[0]], []interface{}{nil})}
+	}
+
+	var heatmaps []Heatmap
+	for i := range names {
+		for j := i + 1; j < len(names); j++ {
+			heatmaps = append(heatmaps, newHeatmap(trials, names[i], names[j], values[names[i]], values[names[j]]))
+		}
+	}
+	return heatmaps
+}
+
+func newHeatmap(trials []OptimizationTrial, x, y string, xValues, yValues []interface{}) Heatmap {
+	heatmap := Heatmap{
+		X:       x,
+		Y:       y,
+		XValues: labels(xValues),
+		YValues: labels(yValues),
+		Score:   make([][]*float64, len(yValues)),
+	}
+	for i := range heatmap.Score {
+		heatmap.Score[i] = make([]*float64, len(xValues))
+	}
+
+	for _, trial := range trials {
+		if math.IsNaN(trial.Score) || math.IsInf(trial.Score, 0) {
+			continue
+		}
+
+		column := indexOfValue(xValues, trial.Params[x])
+		row := 0
+		if y != "" {
+			row = indexOfValue(yValues, trial.Params[y])
+		}
+		if column < 0 || row < 0 {
+			continue
+		}
+
+		if cell := heatmap.Score[row][column]; cell == nil || trial.Score > *cell {
+			score := trial.Score
+			heatmap.Score[row][column] = &score
+		}
+	}
+	return heatmap
+}
+
+// WriteOptimizationReport writes a standalone HTML page with the parameter heatmaps and the equity of
+// the best and median trials
+func WriteOptimizationReport(w io.Writer, report OptimizationReport) error {
+	page, err := template.ParseFS(staticFiles, "assets/optimization.html")
+	if err != nil {
+		return err
+	}
+
+	bestScore := math.Inf(-1)
+	for _, trial := range report.Trials {
+		if !math.IsNaN(trial.Score) && trial.Score > bestScore {
+			bestScore = trial.Score
+		}
+	}
+	if math.IsInf(bestScore, 0) {
+		bestScore = 0
+	}
+
+	return page.Execute(w, struct {
+		Strategy  string
+		Objective string
+		Trials    int
+		BestScore float64
+		Data      interface{}
+	}{
+		Strategy:  report.Strategy,
+		Objective: report.Objective,
+		Trials:    len(report.Trials),
+		BestScore: bestScore,
+		Data: struct {
+			Objective string       `json:"objective"`
+			Heatmaps  []Heatmap    `json:"heatmaps"`
+			Best      []assetValue `json:"best"`
+			Median    []assetValue `json:"median"`
+		}{
+			Objective: report.Objective,
+			Heatmaps:  Heatmaps(report.Trials),
+			Best:      equityValues(report.Best),
+			Median:    equityValues(report.Median),
+		},
+	})
+}
+
+func equityValues(equity []exchange.AssetValue) []assetValue {
+	values := make([]assetValue, 0, len(equity))
+	for _, value := range equity {
+		values = append(values, assetValue{Time: value.Time, Value: value.Value})
+	}
+	return values
+}
+
+func labels(values []interface{}) []string {
+	result := make([]string, len(values))
+	for i, value := range values {
+		if value != nil {
+			result[i] = fmt.Sprint(value)
+		}
+	}
+	return result
+}
+
+// sortValues sorts numbers in ascending order, other values by their text
+func sortValues(values []interface{}) {
+	sort.SliceStable(values, func(i, j int) bool {
+		a, aErr := strconv.ParseFloat(fmt.Sprint(values[i]), 64)
+		b, bErr := strconv.ParseFloat(fmt.Sprint(values[j]), 64)
+		if aErr == nil && bErr == nil {
+			return a < b
+		}
+		return fmt.Sprint(values[i]) < fmt.Sprint(values[j])
+	})
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	return indexOfValue(values, value) >= 0
+}
+
+func indexOfValue(values []interface{}, value interface{}) int {
+	for i, option := range values {
+		if fmt.Sprint(option) == fmt.Sprint(value) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,73 @@
+package plot
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ezquant/azbot/azbot/exchange"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeatmaps(t *testing.T) {
+	trials := []OptimizationTrial{
+		{Params: map[string]interface{}{"fast": 8, "slow": 21, "mode": "long"}, Score: 1},
+		{Params: map[string]interface{}{"fast": 8, "slow": 21, "mode": "short"}, Score: 2},
+		{Params: map[string]interface{}{"fast": 10, "slow": 21, "mode": "long"}, Score: 0.5},
+		{Params: map[string]interface{}{"fast": 2, "slow": 34, "mode": "long"}, Score: math.NaN()},
+	}
+
+	heatmaps := Heatmaps(trials)
+	require.Len(t, heatmaps, 3)
+
+	heatmap := heatmaps[0]
+	require.Equal(t, "fast", heatmap.X)
+	require.Equal(t, "mode", heatmap.Y)
+	require.Equal(t, []string{"2", "8", "10"}, heatmap.XValues)
+	require.Equal(t, []string{"long", "short"}, heatmap.YValues)
+	require.Nil(t, heatmap.Score[0][0])
+	require.Equal(t, 1.0, *heatmap.Score[0][1])
+	require.Equal(t, 0.5, *heatmap.Score[0][2])
+	require.Equal(t, 2.0, *heatmap.Score[1][1])
+
+	require.Equal(t, "fast", heatmaps[1].X)
+	require.Equal(t, "slow", heatmaps[1].Y)
+	require.Equal(t, 2.0, *heatmaps[1].Score[0][1], "best score of the cell")
+
+	t.Run("single parameter", func(t *testing.T) {
+		heatmaps := Heatmaps([]OptimizationTrial{trials[0], trials[2]})
+		require.Len(t, heatmaps, 1)
+		require.Equal(t, "fast", heatmaps[0].X)
+		require.Empty(t, heatmaps[0].Y)
+		require.Len(t, heatmaps[0].Score, 1)
+		require.Equal(t, 1.0, *heatmaps[0].Score[0][0])
+		require.Equal(t, 0.5, *heatmaps[0].Score[0][1])
+	})
+}
+
+func TestWriteOptimizationReport(t *testing.T) {
+	start := time.Date(2021, 9, 26, 0, 0, 0, 0, time.UTC)
+	equity := []exchange.AssetValue{{Time: start, Value: 1000}, {Time: start.Add(time.Hour), Value: 1100}}
+
+	var buffer bytes.Buffer
+	err := WriteOptimizationReport(&buffer, OptimizationReport{
+		Strategy:  "CrossEMA",
+		Objective: "sharpe",
+		Trials: []OptimizationTrial{
+			{Params: map[string]interface{}{"fast": 8}, Score: 1.5},
+			{Params: map[string]interface{}{"fast": 10}, Score: math.Inf(-1)},
+		},
+		Best:   equity,
+		Median: equity[:1],
+	})
+	require.NoError(t, err)
+
+	html := buffer.String()
+	require.Contains(t, html, "<h1>CrossEMA</h1>")
+	require.Contains(t, html, "2 trials, objective sharpe, best score 1.5000")
+	require.Contains(t, html, `"x_values":["8","10"]`)
+	require.Contains(t, html, `"score":[[1.5,null]]`)
+	require.Contains(t, html, `"value":1100`)
+}
@@ -1,15 +1,36 @@
 package models
 
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigVersion 为当前配置格式的版本，没有 version 的配置视为当前版本
+const ConfigVersion = 1
+
 type Parameter struct {
 	Name    string      `yaml:"name"`
 	Type    string      `yaml:"type"`
 	Default interface{} `yaml:"default"`
-	Min     interface{} `yaml:"min"`
-	Max     interface{} `yaml:"max"`
-	Step    interface{} `yaml:"step"`
+	Min     interface{} `yaml:"min,omitempty"`
+	Max     interface{} `yaml:"max,omitempty"`
+	Step    interface{} `yaml:"step,omitempty"`
+	// Values 为 enum 参数的可选值
+	Values []interface{} `yaml:"values,omitempty"`
 }
 
+// Config 为回测、模拟交易 (paper) 和实盘 (live) 共用的配置。
+// 字符串中的 ${ENV} 或 ${ENV:-default} 在读取时替换为环境变量，见 LoadConfig
 type Config struct {
+	Version        int         `yaml:"version"`
 	Strategy       string      `yaml:"strategy"`
 	Parameters     []Parameter `yaml:"parameters"`
 	BacktestConfig struct {
@@ -19,11 +40,256 @@ type Config struct {
 		Slippage       float64 `yaml:"slippage"`
 	} `yaml:"backtest"`
 	AssetWeights map[string]float64 `yaml:"asset_weights,flow"`
+	WalkForward  WalkForward        `yaml:"walk_forward,omitempty"`
+	Optimizer    Optimizer          `yaml:"optimizer,omitempty"`
+	MonteCarlo   MonteCarlo         `yaml:"monte_carlo,omitempty"`
+
+	Paper    Paper    `yaml:"paper,omitempty"`
+	Exchange Exchange `yaml:"exchange,omitempty"`
+	Telegram Telegram `yaml:"telegram,omitempty"`
+	Storage  Storage  `yaml:"storage,omitempty"`
+	Log      Log      `yaml:"log,omitempty"`
+
+	// templates 按字段路径保存替换前的字符串，Save 时写回 ${ENV}，避免保存密钥
+	templates map[string]envTemplate
+}
+
+// envTemplate 为包含环境变量的字符串及替换后的值
+type envTemplate struct {
+	value    string
+	template string
+}
+
+// MonteCarlo 回测交易的蒙特卡洛模拟配置，Simulations 为 0 时不启用
+type MonteCarlo struct {
+	Simulations int `yaml:"simulations"`
+	// Method 为 reshuffle（默认，打乱交易顺序）或 bootstrap（有放回抽样）
+	Method string `yaml:"method"`
+	// Skip 为每笔交易被跳过的概率
+	Skip float64 `yaml:"skip"`
+	// FillNoise 为成交价格随机误差的标准差，eg: 0.001 为 10 bps
+	FillNoise float64 `yaml:"fill_noise"`
+	// Ruin 为视为破产的最大回撤，默认 0.5
+	Ruin float64 `yaml:"ruin"`
+	Seed int64   `yaml:"seed"`
+}
+
+// Optimizer 参数搜索配置，Method 为 grid（默认）、random、tpe 或 genetic
+type Optimizer struct {
+	Method string `yaml:"method"`
+	// Objective 为最大化的回测指标，默认 sharpe，见 Objectives
+	Objective string `yaml:"objective,omitempty"`
+	// Trials 为试验次数上限，网格搜索为 0 时遍历所有组合
+	Trials int `yaml:"trials"`
+	// Seed 为随机种子，相同的种子得到相同的搜索结果
+	Seed int64 `yaml:"seed"`
+}
+
+// WalkForward 滚动优化配置，Train 为空时不启用，eg: train: 90d, test: 30d
+type WalkForward struct {
+	Train string `yaml:"train"`
+	Test  string `yaml:"test"`
+	// Anchored 为 true 时训练窗口起点固定，否则随测试窗口滚动
+	Anchored bool `yaml:"anchored"`
+}
+
+// Paper 模拟交易配置，使用交易所的实时数据和模拟钱包
+type Paper struct {
+	// Quote 为计价资产，默认 USDT
+	Quote          string  `yaml:"quote"`
+	InitialBalance float64 `yaml:"initial_balance"`
+	Fee            float64 `yaml:"fee"`
+	Slippage       float64 `yaml:"slippage"`
+}
+
+// Exchange 交易所配置，Name 为 binance（默认）或 binance_futures
+type Exchange struct {
+	Name      string `yaml:"name"`
+	APIKey    string `yaml:"api_key"`
+	APISecret string `yaml:"api_secret"`
+	Testnet   bool   `yaml:"testnet"`
+	// Leverage 和 MarginType (isolated 或 crossed) 仅用于合约，应用于所有交易对
+	Leverage   int    `yaml:"leverage"`
+	MarginType string `yaml:"margin_type"`
+}
+
+// Telegram 通知和命令配置
+type Telegram struct {
+	Enabled bool   `yaml:"enabled"`
+	Token   string `yaml:"token"`
+	Users   []int  `yaml:"users"`
+}
+
+// Storage 订单存储配置，Path 为空时使用内存
+type Storage struct {
+	Path string `yaml:"path"`
+}
+
+// Log 日志配置，Level 为 trace、debug、info（默认）、warn 或 error
+type Log struct {
+	Level string `yaml:"level"`
+}
+
+var (
+	ErrInvalidConfig = errors.New("invalid config")
+	envPattern       = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+)
+
+// DefaultConfig 返回策略的配置模板，parameters 为策略的参数定义，eg: ParameterSchema 的结果。
+// 密钥使用环境变量，未设置时为空
+func DefaultConfig(strategy string, parameters []Parameter) *Config {
+	config := &Config{
+		Version:      ConfigVersion,
+		Strategy:     strategy,
+		Parameters:   parameters,
+		AssetWeights: map[string]float64{"BTCUSDT": 1},
+		Paper: Paper{
+			Quote:          "USDT",
+			InitialBalance: 10000,
+			Fee:            0.001,
+		},
+		Exchange: Exchange{
+			Name:      ExchangeBinance,
+			APIKey:    "${API_KEY:-}",
+			APISecret: "${API_SECRET:-}",
+		},
+		Telegram: Telegram{
+			Token: "${TELEGRAM_TOKEN:-}",
+		},
+		Storage: Storage{Path: "user_data/db/azbot.db"},
+		Log:     Log{Level: "info"},
+	}
+	config.BacktestConfig.Timeframe = "1h"
+	config.BacktestConfig.InitialBalance = 10000
+	config.BacktestConfig.Fee = 0.001
+	config.BacktestConfig.Slippage = 0.0005
+	return config
+}
+
+// LoadConfig 读取 YAML 配置。字符串值中的 ${ENV} 替换为环境变量，${ENV:-default} 在变量未设置时使用默认值，
+// 未设置且没有默认值的变量和未知字段返回 ErrInvalidConfig。读取后不会自动校验，见 Validate
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(data)
+}
+
+// ParseConfig 解析 YAML 配置，见 LoadConfig
+func ParseConfig(data []byte) (*Config, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+
+	config := &Config{templates: make(map[string]envTemplate)}
+	var missing []string
+	interpolate(&document, "", config.templates, &missing)
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: 环境变量未设置: %s", ErrInvalidConfig, strings.Join(missing, ", "))
+	}
+
+	// 替换后重新编码，使数值和布尔值按 YAML 规则解析，eg: leverage: ${LEVERAGE:-1}
+	expanded, err := yaml.Marshal(&document)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(expanded))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	return config, nil
 }
 
-// Save 保存配置到指定路径
+// interpolate 替换标量节点中的环境变量，templates 按字段路径记录原始字符串
+func interpolate(node *yaml.Node, path string, templates map[string]envTemplate, missing *[]string) {
+	if node.Kind == yaml.ScalarNode && strings.Contains(node.Value, "${") {
+		template := node.Value
+		node.Value = envPattern.ReplaceAllStringFunc(template, func(match string) string {
+			groups := envPattern.FindStringSubmatch(match)
+			if value, ok := os.LookupEnv(groups[1]); ok {
+				return value
+			}
+			if groups[2] != "" {
+				return groups[3]
+			}
+			*missing = append(*missing, groups[1])
+			return ""
+		})
+		if node.Value != template {
+			templates[path] = envTemplate{value: node.Value, template: template}
+			// 引号中的变量同样按替换后的值解析类型，eg: users: ["${TELEGRAM_USER}"]
+			node.Tag = ""
+			node.Style = 0
+		}
+	}
+
+	walk(node, path, func(child *yaml.Node, childPath string) {
+		interpolate(child, childPath, templates, missing)
+	})
+}
+
+// walk 以字段路径遍历子节点，eg: exchange.api_key 或 telegram.users[0]
+func walk(node *yaml.Node, path string, fn func(child *yaml.Node, childPath string)) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			fn(child, path)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			fn(node.Content[i+1], path+"."+node.Content[i].Value)
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			fn(child, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+// Save 保存配置到指定路径，从环境变量读取的值保存为原来的 ${ENV}
 func (c *Config) Save(path string) error {
-	// 实现配置保存逻辑
-	// 例如使用 yaml.Marshal 和 ioutil.WriteFile
-	return nil
+	if c.Version == 0 {
+		c.Version = ConfigVersion
+	}
+
+	var document yaml.Node
+	if err := document.Encode(c); err != nil {
+		return err
+	}
+	restore(&document, "", c.templates)
+
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&document); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	// 配置可能包含密钥，仅当前用户可读写
+	return os.WriteFile(path, buffer.Bytes(), 0600)
+}
+
+// restore 将未修改的环境变量的值替换为原始字符串
+func restore(node *yaml.Node, path string, templates map[string]envTemplate) {
+	if template, ok := templates[path]; ok && node.Kind == yaml.ScalarNode && node.Value == template.value {
+		node.Value = template.template
+		node.Tag = "!!str"
+		node.Style = 0
+	}
+
+	walk(node, path, func(child *yaml.Node, childPath string) {
+		restore(child, childPath, templates)
+	})
 }
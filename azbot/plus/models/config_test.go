@@ -0,0 +1,156 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testConfig = `
+version: 1
+strategy: CrossEMA
+backtest:
+  timeframe: 5m
+  initial_balance: 10000
+  fee: 0.001
+asset_weights:
+  BTCUSDT: 0.6
+  ETHUSDT: 0.4
+parameters:
+  - name: ema8_period
+    type: int
+    default: 8
+    min: 3
+    max: 11
+    step: 4
+exchange:
+  name: binance_futures
+  api_key: ${TEST_CONFIG_KEY}
+  api_secret: "${TEST_CONFIG_SECRET:-secret}"
+  leverage: ${TEST_CONFIG_LEVERAGE:-1}
+telegram:
+  enabled: true
+  token: ${TEST_CONFIG_TOKEN}
+  users: ["${TEST_CONFIG_USER}"]
+log:
+  level: debug
+# ${NOT_INTERPOLATED_IN_COMMENTS}
+`
+
+func TestParseConfig(t *testing.T) {
+	t.Setenv("TEST_CONFIG_KEY", "key")
+	t.Setenv("TEST_CONFIG_TOKEN", "bot:token")
+	t.Setenv("TEST_CONFIG_USER", "42")
+
+	config, err := ParseConfig([]byte(testConfig))
+	require.NoError(t, err)
+	require.Equal(t, 1, config.Version)
+	require.Equal(t, "5m", config.BacktestConfig.Timeframe)
+	require.Equal(t, Exchange{
+		Name:      ExchangeBinanceFutures,
+		APIKey:    "key",
+		APISecret: "secret",
+		Leverage:  1,
+	}, config.Exchange)
+	require.Equal(t, Telegram{Enabled: true, Token: "bot:token", Users: []int{42}}, config.Telegram)
+	require.NoError(t, config.ValidateMode(ModeLive))
+
+	t.Run("missing variables", func(t *testing.T) {
+		os.Unsetenv("TEST_CONFIG_KEY")
+		_, err := ParseConfig([]byte(testConfig))
+		require.ErrorIs(t, err, ErrInvalidConfig)
+		require.ErrorContains(t, err, "TEST_CONFIG_KEY")
+	})
+
+	t.Run("unknown fields", func(t *testing.T) {
+		_, err := ParseConfig([]byte("strategy: CrossEMA\nbacktest:\n  timefrme: 1h\n"))
+		require.ErrorIs(t, err, ErrInvalidConfig)
+		require.ErrorContains(t, err, "timefrme")
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		config, err := ParseConfig(nil)
+		require.NoError(t, err)
+		require.Error(t, config.Validate())
+	})
+}
+
+func TestConfig_Save(t *testing.T) {
+	t.Setenv("TEST_CONFIG_KEY", "key")
+	t.Setenv("TEST_CONFIG_TOKEN", "bot:token")
+	t.Setenv("TEST_CONFIG_USER", "42")
+
+	config, err := ParseConfig([]byte(testConfig))
+	require.NoError(t, err)
+	config.Parameters[0].Default = 11
+	config.Telegram.Token = "changed"
+
+	path := filepath.Join(t.TempDir(), "configs", "config.yml")
+	require.NoError(t, config.Save(path))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "${TEST_CONFIG_KEY}")
+	require.Contains(t, string(data), "${TEST_CONFIG_USER}")
+	require.NotContains(t, string(data), "bot:token")
+
+	loaded, err := LoadConfig(path)
+	require.NoError(t, err)
+	require.Equal(t, 11, loaded.Parameters[0].Default)
+	require.Equal(t, "key", loaded.Exchange.APIKey)
+	require.Equal(t, "changed", loaded.Telegram.Token)
+	require.Equal(t, []int{42}, loaded.Telegram.Users)
+	require.Equal(t, config.AssetWeights, loaded.AssetWeights)
+
+	t.Run("default config", func(t *testing.T) {
+		schema, err := ParameterSchema(testParameters{})
+		require.NoError(t, err)
+
+		path := filepath.Join(t.TempDir(), "config.yml")
+		require.NoError(t, DefaultConfig("Test", schema).Save(path))
+
+		loaded, err := LoadConfig(path)
+		require.NoError(t, err)
+		require.NoError(t, loaded.ValidateMode(ModeBacktest))
+		require.NoError(t, loaded.ValidateMode(ModePaper))
+		require.Error(t, loaded.ValidateMode(ModeLive))
+		require.Len(t, loaded.Parameters, len(schema))
+
+		var params testParameters
+		require.NoError(t, loaded.Bind(&params))
+		require.Equal(t, 8, params.Period)
+	})
+}
+
+func TestConfig_Validate(t *testing.T) {
+	config := DefaultConfig("Test", nil)
+	require.NoError(t, config.Validate())
+
+	config.Version = 2
+	config.AssetWeights["ETHUSDT"] = 0.5
+	config.BacktestConfig.Timeframe = "soon"
+	config.BacktestConfig.Fee = 2
+	config.Optimizer.Method = "annealing"
+	config.Optimizer.Objective = "alpha"
+	config.Exchange.Name = "ftx"
+	config.Telegram.Enabled = true
+	config.Telegram.Users = nil
+	config.Log.Level = "loud"
+	config.Parameters = []Parameter{
+		{Name: "period", Type: "int", Min: 10, Max: 5},
+		{Name: "period", Type: "integer"},
+	}
+
+	err := config.Validate()
+	require.ErrorIs(t, err, ErrInvalidConfig)
+	for _, field := range []string{
+		"version", "asset_weights", "backtest.timeframe", "backtest.fee", "optimizer.method", "optimizer.objective",
+		"exchange.name", "telegram.users", "log.level", "parameters.period", "parameters.period.type",
+	} {
+		require.ErrorContains(t, err, field+":")
+	}
+
+	require.ErrorContains(t, DefaultConfig("Test", nil).ValidateMode("replay"), "mode:")
+}
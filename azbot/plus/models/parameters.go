@@ -0,0 +1,363 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xhit/go-str2duration/v2"
+)
+
+// 参数类型，与 Parameter.Type 对应
+const (
+	ParameterInt      = "int"
+	ParameterFloat    = "float"
+	ParameterBool     = "bool"
+	ParameterEnum     = "enum"
+	ParameterDuration = "duration"
+)
+
+var ErrInvalidParameter = errors.New("invalid parameter")
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// parameterField 为带有 param 标签的结构体字段
+type parameterField struct {
+	index []int
+	name  string
+	kind  string
+	tag   reflect.StructTag
+}
+
+// Bind 将配置中的参数赋值到策略的参数结构体，见 BindParameters
+func (c *Config) Bind(target interface{}) error {
+	return BindParameters(c.Parameters, target)
+}
+
+// BindParameters 根据 param 标签将参数的默认值赋值到 target 指向的结构体，eg:
+//
+//	type Parameters struct {
+//		Period   int           `param:"period" default:"8" min:"2" max:"50" step:"1"`
+//		Risk     float64       `param:"risk" default:"0.02" min:"0" max:"1"`
+//		Trailing bool          `param:"trailing"`
+//		Mode     string        `param:"mode" default:"long" enum:"long,short,both"`
+//		Cooldown time.Duration `param:"cooldown" default:"4h" min:"0" max:"1d"`
+//	}
+//
+// YAML 中的数值会转换为字段的类型，eg: 2 可用于 float，2.0 可用于 int；duration 可为 "4h" 或秒数。
+// 参数超出 min/max 范围、不在 enum 中或结构体中不存在时返回 ErrInvalidParameter。
+// 配置中没有的参数使用 default 标签，没有 default 标签时保留字段原值。
+func BindParameters(parameters []Parameter, target interface{}) error {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: 参数目标必须为结构体指针, 实际为 %T", ErrInvalidParameter, target)
+	}
+
+	fields, err := parameterFields(value.Elem().Type())
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		known[field.name] = true
+	}
+
+	values := make(map[string]interface{}, len(parameters))
+	for _, param := range parameters {
+		if !known[param.Name] {
+			return fmt.Errorf("%w: 未知参数 %s", ErrInvalidParameter, param.Name)
+		}
+		values[param.Name] = param.Default
+	}
+
+	for _, field := range fields {
+		raw, ok := values[field.name]
+		if !ok || raw == nil {
+			defaultValue, ok := field.tag.Lookup("default")
+			if !ok {
+				continue
+			}
+			raw = defaultValue
+		}
+
+		converted, err := field.convert(raw)
+		if err != nil {
+			return err
+		}
+		if err := field.validate(converted); err != nil {
+			return err
+		}
+
+		target := value.Elem().FieldByIndex(field.index)
+		if overflows(target, converted) {
+			return fmt.Errorf("%w: %s 的值 %v 超出 %s 的范围", ErrInvalidParameter, field.name, converted, target.Type())
+		}
+		target.Set(reflect.ValueOf(converted).Convert(target.Type()))
+	}
+	return nil
+}
+
+// ParameterSchema 返回 target 结构体的参数定义，Min、Max、Step 和 Default 为标签中的值，
+// 可用于生成优化器的搜索空间。duration 参数的值为时长字符串，eg: "4h"
+func ParameterSchema(target interface{}) ([]Parameter, error) {
+	structType := reflect.TypeOf(target)
+	if structType != nil && structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType == nil || structType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: 参数目标必须为结构体, 实际为 %T", ErrInvalidParameter, target)
+	}
+
+	fields, err := parameterFields(structType)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := make([]Parameter, 0, len(fields))
+	for _, field := range fields {
+		param := Parameter{Name: field.name, Type: field.kind}
+		for _, item := range []struct {
+			tag   string
+			value *interface{}
+		}{
+			{"default", &param.Default},
+			{"min", &param.Min},
+			{"max", &param.Max},
+			{"step", &param.Step},
+		} {
+			text, ok := field.tag.Lookup(item.tag)
+			if !ok {
+				continue
+			}
+			converted, err := field.convert(text)
+			if err != nil {
+				return nil, err
+			}
+			// duration 使用原始字符串，便于阅读和写入配置文件
+			if field.kind == ParameterDuration {
+				converted = text
+			}
+			*item.value = converted
+		}
+
+		for _, value := range field.enum() {
+			param.Values = append(param.Values, value)
+		}
+		schema = append(schema, param)
+	}
+	return schema, nil
+}
+
+// parameterFields 返回带有 param 标签的字段，包括嵌入结构体中的字段
+func parameterFields(structType reflect.Type) ([]parameterField, error) {
+	var fields []parameterField
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		name, ok := field.Tag.Lookup("param")
+		if !ok {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				embedded, err := parameterFields(field.Type)
+				if err != nil {
+					return nil, err
+				}
+				for _, item := range embedded {
+					item.index = append([]int{i}, item.index...)
+					fields = append(fields, item)
+				}
+			}
+			continue
+		}
+
+		if !field.IsExported() {
+			return nil, fmt.Errorf("%w: 字段 %s 未导出", ErrInvalidParameter, field.Name)
+		}
+
+		result := parameterField{index: []int{i}, name: name, tag: field.Tag}
+		switch {
+		case field.Type == durationType:
+			result.kind = ParameterDuration
+		case field.Type.Kind() == reflect.Bool:
+			result.kind = ParameterBool
+		case field.Type.Kind() == reflect.String:
+			result.kind = ParameterEnum
+		case field.Type.Kind() >= reflect.Int && field.Type.Kind() <= reflect.Uint64:
+			result.kind = ParameterInt
+		case field.Type.Kind() == reflect.Float32 || field.Type.Kind() == reflect.Float64:
+			result.kind = ParameterFloat
+		default:
+			return nil, fmt.Errorf("%w: 参数 %s 的类型 %s 不支持", ErrInvalidParameter, name, field.Type)
+		}
+		fields = append(fields, result)
+	}
+	return fields, nil
+}
+
+// enum 返回字符串参数的可选值
+func (f parameterField) enum() []string {
+	text, ok := f.tag.Lookup("enum")
+	if !ok || f.kind != ParameterEnum {
+		return nil
+	}
+
+	values := strings.Split(text, ",")
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+	return values
+}
+
+// convert 将 YAML 中的值转换为参数的类型: int、float64、bool、string 或 time.Duration
+func (f parameterField) convert(raw interface{}) (interface{}, error) {
+	invalid := func(err error) error {
+		if err != nil {
+			return fmt.Errorf("%w: %s 的值 %v 不是有效的 %s: %v", ErrInvalidParameter, f.name, raw, f.kind, err)
+		}
+		return fmt.Errorf("%w: %s 的值 %v (%T) 不是有效的 %s", ErrInvalidParameter, f.name, raw, raw, f.kind)
+	}
+
+	switch f.kind {
+	case ParameterInt:
+		if text, ok := raw.(string); ok {
+			number, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+			if err != nil {
+				return nil, invalid(err)
+			}
+			raw = number
+		}
+		number, ok := toNumber(raw)
+		if !ok || number != math.Trunc(number) {
+			return nil, invalid(nil)
+		}
+		return int(number), nil
+	case ParameterFloat:
+		if text, ok := raw.(string); ok {
+			number, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+			if err != nil {
+				return nil, invalid(err)
+			}
+			return number, nil
+		}
+		number, ok := toNumber(raw)
+		if !ok {
+			return nil, invalid(nil)
+		}
+		return number, nil
+	case ParameterBool:
+		switch value := raw.(type) {
+		case bool:
+			return value, nil
+		case string:
+			result, err := strconv.ParseBool(strings.TrimSpace(value))
+			if err != nil {
+				return nil, invalid(err)
+			}
+			return result, nil
+		}
+		return nil, invalid(nil)
+	case ParameterEnum:
+		switch raw.(type) {
+		case string, int, int64, float64, bool:
+			return fmt.Sprint(raw), nil
+		}
+		return nil, invalid(nil)
+	case ParameterDuration:
+		switch value := raw.(type) {
+		case time.Duration:
+			return value, nil
+		case string:
+			duration, err := str2duration.ParseDuration(strings.TrimSpace(value))
+			if err != nil {
+				// 字符串形式的秒数，eg: "3600"
+				seconds, numberErr := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if numberErr != nil {
+					return nil, invalid(err)
+				}
+				return time.Duration(seconds * float64(time.Second)), nil
+			}
+			return duration, nil
+		}
+		seconds, ok := toNumber(raw)
+		if !ok {
+			return nil, invalid(nil)
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	return nil, invalid(nil)
+}
+
+// validate 检查参数是否在 min/max 范围内或为 enum 的可选值
+func (f parameterField) validate(value interface{}) error {
+	if values := f.enum(); len(values) > 0 {
+		for _, option := range values {
+			if value == option {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s 的值 %v 不在可选值 %v 中", ErrInvalidParameter, f.name, value, values)
+	}
+
+	if f.kind == ParameterBool || f.kind == ParameterEnum {
+		return nil
+	}
+
+	for _, bound := range []string{"min", "max"} {
+		text, ok := f.tag.Lookup(bound)
+		if !ok {
+			continue
+		}
+		limit, err := f.convert(text)
+		if err != nil {
+			return fmt.Errorf("%s 标签: %w", bound, err)
+		}
+
+		current, _ := toNumber(value)
+		threshold, _ := toNumber(limit)
+		if bound == "min" && current < threshold {
+			return fmt.Errorf("%w: %s 的值 %v 小于最小值 %s", ErrInvalidParameter, f.name, value, text)
+		}
+		if bound == "max" && current > threshold {
+			return fmt.Errorf("%w: %s 的值 %v 大于最大值 %s", ErrInvalidParameter, f.name, value, text)
+		}
+	}
+	return nil
+}
+
+// overflows 检查数值是否超出字段类型的范围，eg: uint 字段的 -1 或 int8 字段的 300
+func overflows(field reflect.Value, value interface{}) bool {
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		number, ok := value.(int)
+		return ok && field.OverflowInt(int64(number))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		number, ok := value.(int)
+		return ok && (number < 0 || field.OverflowUint(uint64(number)))
+	case reflect.Float32:
+		number, ok := value.(float64)
+		return ok && field.OverflowFloat(number)
+	}
+	return false
+}
+
+// toNumber 将数值转换为 float64，duration 为纳秒数
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case time.Duration:
+		return float64(v), true
+	}
+	return 0, false
+}
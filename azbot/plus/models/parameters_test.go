@@ -0,0 +1,130 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+type testParameters struct {
+	Period   int           `param:"period" default:"8" min:"2" max:"50" step:"2"`
+	Risk     float64       `param:"risk" default:"0.02" min:"0" max:"1"`
+	Trailing bool          `param:"trailing"`
+	Mode     string        `param:"mode" default:"long" enum:"long, short"`
+	Cooldown time.Duration `param:"cooldown" default:"4h" min:"0" max:"1d"`
+	ignored  int
+}
+
+type embeddedParameters struct {
+	testParameters
+	Size float32 `param:"size"`
+}
+
+func TestBindParameters(t *testing.T) {
+	t.Run("yaml coercion", func(t *testing.T) {
+		var config Config
+		err := yaml.Unmarshal([]byte(`
+parameters:
+  - name: period
+    default: 12.0
+  - name: risk
+    default: 1
+  - name: trailing
+    default: "true"
+  - name: mode
+    default: short
+  - name: cooldown
+    default: 1d
+`), &config)
+		require.NoError(t, err)
+
+		params := testParameters{ignored: 3}
+		require.NoError(t, config.Bind(&params))
+		require.Equal(t, testParameters{
+			Period:   12,
+			Risk:     1,
+			Trailing: true,
+			Mode:     "short",
+			Cooldown: 24 * time.Hour,
+			ignored:  3,
+		}, params)
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		params := testParameters{Trailing: true}
+		require.NoError(t, BindParameters(nil, &params))
+		require.Equal(t, 8, params.Period)
+		require.Equal(t, 0.02, params.Risk)
+		require.True(t, params.Trailing)
+		require.Equal(t, "long", params.Mode)
+		require.Equal(t, 4*time.Hour, params.Cooldown)
+	})
+
+	t.Run("durations in seconds and embedded structs", func(t *testing.T) {
+		var params embeddedParameters
+		require.NoError(t, BindParameters([]Parameter{
+			{Name: "cooldown", Default: 3600},
+			{Name: "size", Default: 0.5},
+		}, &params))
+		require.Equal(t, time.Hour, params.Cooldown)
+		require.Equal(t, float32(0.5), params.Size)
+		require.Equal(t, 8, params.Period)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, parameters := range [][]Parameter{
+			{{Name: "period", Default: 2.5}},
+			{{Name: "period", Default: 1}},
+			{{Name: "period", Default: 100}},
+			{{Name: "risk", Default: "high"}},
+			{{Name: "trailing", Default: 1}},
+			{{Name: "mode", Default: "both"}},
+			{{Name: "cooldown", Default: "2d"}},
+			{{Name: "cooldown", Default: "soon"}},
+			{{Name: "unknown", Default: 1}},
+		} {
+			var params testParameters
+			err := BindParameters(parameters, &params)
+			require.ErrorIs(t, err, ErrInvalidParameter, "%v", parameters)
+		}
+
+		require.ErrorIs(t, BindParameters(nil, testParameters{}), ErrInvalidParameter)
+		require.ErrorIs(t, BindParameters(nil, &struct {
+			Values []int `param:"values"`
+		}{}), ErrInvalidParameter)
+
+		// 超出字段类型范围的整数返回错误，不会溢出
+		var sizes struct {
+			Count uint `param:"count"`
+			Level int8 `param:"level"`
+		}
+		require.ErrorIs(t, BindParameters([]Parameter{{Name: "count", Default: -1}}, &sizes), ErrInvalidParameter)
+		require.ErrorIs(t, BindParameters([]Parameter{{Name: "level", Default: 300}}, &sizes), ErrInvalidParameter)
+		require.NoError(t, BindParameters([]Parameter{{Name: "count", Default: 3}, {Name: "level", Default: -100}}, &sizes))
+		require.Equal(t, uint(3), sizes.Count)
+		require.Equal(t, int8(-100), sizes.Level)
+	})
+}
+
+func TestParameterSchema(t *testing.T) {
+	schema, err := ParameterSchema(embeddedParameters{})
+	require.NoError(t, err)
+	require.Equal(t, []Parameter{
+		{Name: "period", Type: ParameterInt, Default: 8, Min: 2, Max: 50, Step: 2},
+		{Name: "risk", Type: ParameterFloat, Default: 0.02, Min: 0.0, Max: 1.0},
+		{Name: "trailing", Type: ParameterBool},
+		{Name: "mode", Type: ParameterEnum, Default: "long", Values: []interface{}{"long", "short"}},
+		{Name: "cooldown", Type: ParameterDuration, Default: "4h", Min: "0", Max: "1d"},
+		{Name: "size", Type: ParameterFloat},
+	}, schema)
+
+	_, err = ParameterSchema(3)
+	require.ErrorIs(t, err, ErrInvalidParameter)
+
+	_, err = ParameterSchema(struct {
+		Period int `param:"period" min:"low"`
+	}{})
+	require.ErrorIs(t, err, ErrInvalidParameter)
+}
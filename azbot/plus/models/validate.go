@@ -0,0 +1,231 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/xhit/go-str2duration/v2"
+)
+
+// Mode 为配置的运行模式，不同模式需要的配置不同
+type Mode string
+
+const (
+	ModeBacktest Mode = "backtest"
+	ModePaper    Mode = "paper"
+	ModeLive     Mode = "live"
+)
+
+// Exchange.Name 的可选值
+const (
+	ExchangeBinance        = "binance"
+	ExchangeBinanceFutures = "binance_futures"
+)
+
+// Optimizer.Objective 的可选值，drawdown 为负数，最大化即回撤最小
+const (
+	ObjectiveSharpe   = "sharpe"
+	ObjectiveSortino  = "sortino"
+	ObjectiveCalmar   = "calmar"
+	ObjectiveReturn   = "return"
+	ObjectiveProfit   = "profit"
+	ObjectiveDrawdown = "drawdown"
+)
+
+// Objectives 为参数优化支持的目标
+var Objectives = []string{ObjectiveSharpe, ObjectiveSortino, ObjectiveCalmar, ObjectiveReturn, ObjectiveProfit,
+	ObjectiveDrawdown}
+
+// Validate 校验所有模式共用的配置，所有错误合并返回，每个错误为 ErrInvalidConfig
+func (c *Config) Validate() error {
+	var v validator
+
+	if c.Version > ConfigVersion || c.Version < 0 {
+		v.add("version", "不支持的版本 %d，当前版本为 %d", c.Version, ConfigVersion)
+	}
+	if c.Strategy == "" {
+		v.add("strategy", "不能为空")
+	}
+
+	if len(c.AssetWeights) == 0 {
+		v.add("asset_weights", "至少需要一个交易对")
+	}
+	var total float64
+	for pair, weight := range c.AssetWeights {
+		if weight < 0 {
+			v.add("asset_weights."+pair, "权重不能为负数: %v", weight)
+		}
+		total += weight
+	}
+	if total > 1+1e-9 {
+		v.add("asset_weights", "权重之和 %.4f 大于 1", total)
+	}
+
+	v.duration("backtest.timeframe", c.BacktestConfig.Timeframe)
+	v.nonNegative("backtest.initial_balance", c.BacktestConfig.InitialBalance)
+	v.fraction("backtest.fee", c.BacktestConfig.Fee)
+	v.fraction("backtest.slippage", c.BacktestConfig.Slippage)
+
+	c.validateParameters(&v)
+
+	v.oneOf("optimizer.method", c.Optimizer.Method, "", "grid", "random", "tpe", "bayesian", "genetic", "ga")
+	v.oneOf("optimizer.objective", c.Optimizer.Objective, append([]string{""}, Objectives...)...)
+	if c.Optimizer.Trials < 0 {
+		v.add("optimizer.trials", "不能为负数: %d", c.Optimizer.Trials)
+	}
+
+	if c.WalkForward.Train != "" || c.WalkForward.Test != "" {
+		v.required("walk_forward.train", c.WalkForward.Train)
+		v.required("walk_forward.test", c.WalkForward.Test)
+		v.duration("walk_forward.train", c.WalkForward.Train)
+		v.duration("walk_forward.test", c.WalkForward.Test)
+	}
+
+	if c.MonteCarlo.Simulations < 0 {
+		v.add("monte_carlo.simulations", "不能为负数: %d", c.MonteCarlo.Simulations)
+	}
+	v.oneOf("monte_carlo.method", c.MonteCarlo.Method, "", "reshuffle", "bootstrap")
+	v.fraction("monte_carlo.skip", c.MonteCarlo.Skip)
+	v.fraction("monte_carlo.ruin", c.MonteCarlo.Ruin)
+	v.nonNegative("monte_carlo.fill_noise", c.MonteCarlo.FillNoise)
+
+	v.nonNegative("paper.initial_balance", c.Paper.InitialBalance)
+	v.fraction("paper.fee", c.Paper.Fee)
+	v.fraction("paper.slippage", c.Paper.Slippage)
+
+	v.oneOf("exchange.name", c.Exchange.Name, "", ExchangeBinance, ExchangeBinanceFutures)
+	v.oneOf("exchange.margin_type", c.Exchange.MarginType, "", "isolated", "crossed")
+	if c.Exchange.Leverage < 0 {
+		v.add("exchange.leverage", "不能为负数: %d", c.Exchange.Leverage)
+	}
+
+	if c.Telegram.Enabled {
+		v.required("telegram.token", c.Telegram.Token)
+		if len(c.Telegram.Users) == 0 {
+			v.add("telegram.users", "启用 telegram 时至少需要一个用户")
+		}
+	}
+
+	if c.Log.Level != "" {
+		if _, err := log.ParseLevel(c.Log.Level); err != nil {
+			v.add("log.level", "无效的日志级别 %s", c.Log.Level)
+		}
+	}
+
+	return v.err()
+}
+
+// ValidateMode 校验配置并检查运行模式需要的配置，eg: 实盘需要交易所密钥
+func (c *Config) ValidateMode(mode Mode) error {
+	var v validator
+	if err := c.Validate(); err != nil {
+		v.errs = append(v.errs, err)
+	}
+
+	switch mode {
+	case ModeBacktest:
+		v.required("backtest.timeframe", c.BacktestConfig.Timeframe)
+		if c.BacktestConfig.InitialBalance <= 0 {
+			v.add("backtest.initial_balance", "必须大于 0")
+		}
+	case ModePaper:
+		if c.Paper.InitialBalance <= 0 {
+			v.add("paper.initial_balance", "必须大于 0")
+		}
+	case ModeLive:
+		v.required("exchange.api_key", c.Exchange.APIKey)
+		v.required("exchange.api_secret", c.Exchange.APISecret)
+	default:
+		v.add("mode", "未知的运行模式 %s", mode)
+	}
+
+	return v.err()
+}
+
+// validateParameters 检查参数名称唯一，数值参数的 min 不大于 max
+func (c *Config) validateParameters(v *validator) {
+	seen := make(map[string]bool, len(c.Parameters))
+	for i, param := range c.Parameters {
+		field := fmt.Sprintf("parameters[%d]", i)
+		if param.Name == "" {
+			v.add(field+".name", "不能为空")
+			continue
+		}
+		field = fmt.Sprintf("parameters.%s", param.Name)
+		if seen[param.Name] {
+			v.add(field, "重复的参数")
+		}
+		seen[param.Name] = true
+
+		v.oneOf(field+".type", param.Type, "", ParameterInt, ParameterFloat, ParameterBool, ParameterEnum,
+			ParameterDuration)
+
+		minValue, minOk := toNumber(param.Min)
+		maxValue, maxOk := toNumber(param.Max)
+		if minOk && maxOk && minValue > maxValue {
+			v.add(field, "min %v 大于 max %v", param.Min, param.Max)
+		}
+		if step, ok := toNumber(param.Step); ok && step < 0 {
+			v.add(field+".step", "不能为负数: %v", param.Step)
+		}
+	}
+}
+
+// validator 收集校验错误
+type validator struct {
+	errs []error
+}
+
+func (v *validator) add(field, format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Errorf("%w: %s: %s", ErrInvalidConfig, field, fmt.Sprintf(format, args...)))
+}
+
+func (v *validator) err() error {
+	return errors.Join(v.errs...)
+}
+
+func (v *validator) required(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.add(field, "不能为空")
+	}
+}
+
+func (v *validator) oneOf(field, value string, options ...string) {
+	for _, option := range options {
+		if value == option {
+			return
+		}
+	}
+	var names []string
+	for _, option := range options {
+		if option != "" {
+			names = append(names, option)
+		}
+	}
+	v.add(field, "无效的值 %q，可选值为 %s", value, strings.Join(names, ", "))
+}
+
+func (v *validator) duration(field, value string) {
+	if value == "" {
+		return
+	}
+	if duration, err := str2duration.ParseDuration(value); err != nil || duration <= 0 {
+		v.add(field, "无效的时长 %q", value)
+	}
+}
+
+func (v *validator) nonNegative(field string, value float64) {
+	if value < 0 || math.IsNaN(value) {
+		v.add(field, "不能为负数: %v", value)
+	}
+}
+
+// fraction 检查比例在 [0, 1] 之间
+func (v *validator) fraction(field string, value float64) {
+	if value < 0 || value > 1 || math.IsNaN(value) {
+		v.add(field, "必须在 0 和 1 之间: %v", value)
+	}
+}
@@ -0,0 +1,311 @@
+// Package rebalance keeps a portfolio allocated to target weights with market orders.
+package rebalance
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/ezquant/azbot/azbot/exchange"
+	"github.com/ezquant/azbot/azbot/model"
+	"github.com/ezquant/azbot/azbot/service"
+	"github.com/ezquant/azbot/azbot/sizing"
+)
+
+var ErrMissingPrice = errors.New("missing price")
+
+// Broker is a broker that provides the trading limits of pairs, eg: order.Controller or exchange.PaperWallet
+type Broker interface {
+	service.Broker
+	AssetsInfo(pair string) model.AssetInfo
+}
+
+// Trigger decides if the portfolio is rebalanced given the time of the last rebalance (zero in the first call)
+// and the drift, the largest difference between the current and the target weight of a pair
+type Trigger func(last, now time.Time, drift float64) bool
+
+// Every rebalances after a period since the last rebalance, eg: 7 * 24 * time.Hour
+func Every(period time.Duration) Trigger {
+	return func(last, now time.Time, _ float64) bool {
+		return last.IsZero() || now.Sub(last) >= period
+	}
+}
+
+// Monthly rebalances in the first call of each calendar month (UTC)
+func Monthly() Trigger {
+	return func(last, now time.Time, _ float64) bool {
+		last, now = last.UTC(), now.UTC()
+		return last.IsZero() || last.Year() != now.Year() || last.Month() != now.Month()
+	}
+}
+
+// Drift rebalances when the weight of a pair drifts from the target, eg: 0.05 for 5 percentage points
+func Drift(threshold float64) Trigger {
+	return func(_, _ time.Time, drift float64) bool {
+		return drift >= threshold
+	}
+}
+
+// Any rebalances when any of the triggers fires
+func Any(triggers ...Trigger) Trigger {
+	return func(last, now time.Time, drift float64) bool {
+		for _, trigger := range triggers {
+			if trigger(last, now, drift) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Allocation is the position of a pair in the portfolio
+type Allocation struct {
+	Pair     string
+	Price    float64
+	Quantity float64
+	Value    float64
+	Weight   float64
+	Target   float64
+}
+
+// Trade is an order needed to reach the target weight of a pair
+type Trade struct {
+	Pair     string
+	Side     model.SideType
+	Quantity float64
+	Value    float64
+}
+
+type Rebalancer struct {
+	broker     Broker
+	quote      string
+	weights    map[string]float64
+	trigger    Trigger
+	cashBuffer float64
+	fee        float64
+	last       time.Time
+}
+
+type Option func(*Rebalancer)
+
+// WithTrigger sets when the portfolio is rebalanced, by default it is rebalanced in every call
+func WithTrigger(trigger Trigger) Option {
+	return func(r *Rebalancer) {
+		r.trigger = trigger
+	}
+}
+
+// WithCashBuffer keeps a ratio of the portfolio value in the quote asset, eg: 0.02 for 2%
+func WithCashBuffer(ratio float64) Option {
+	return func(r *Rebalancer) {
+		r.cashBuffer = ratio
+	}
+}
+
+// WithFee reserves the fee of market orders from the cash of buys, eg: 0.001 for 0.1%
+func WithFee(rate float64) Option {
+	return func(r *Rebalancer) {
+		r.fee = rate
+	}
+}
+
+// New creates a rebalancer of pairs quoted in the same asset, eg: USDT. Weights are normalized by their sum,
+// a pair with zero weight is sold and assets of pairs not present in weights are not traded.
+func New(broker Broker, quote string, weights map[string]float64, options ...Option) *Rebalancer {
+	rebalancer := &Rebalancer{
+		broker:  broker,
+		quote:   quote,
+		weights: weights,
+	}
+
+	for _, option := range options {
+		option(rebalancer)
+	}
+
+	return rebalancer
+}
+
+// Prices returns the last close of each dataframe, eg: dataframes of a PortfolioStrategy
+func Prices(dataframes map[string]*model.Dataframe) map[string]float64 {
+	prices := make(map[string]float64, len(dataframes))
+	for pair, df := range dataframes {
+		if len(df.Close) > 0 {
+			prices[pair] = df.Close.Last(0)
+		}
+	}
+	return prices
+}
+
+// Allocations returns the current and target allocations of the portfolio and its total value in quote
+func (r *Rebalancer) Allocations(prices map[string]float64) ([]Allocation, float64, error) {
+	allocations, total, _, err := r.allocations(prices)
+	return allocations, total, err
+}
+
+// allocations returns the allocations, the total value and the free quote that can be spent in buys
+func (r *Rebalancer) allocations(prices map[string]float64) ([]Allocation, float64, float64, error) {
+	account, err := r.broker.Account()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	var totalWeight float64
+	for _, weight := range r.weights {
+		totalWeight += weight
+	}
+
+	var quote, cash float64
+	for _, balance := range account.Balances {
+		if balance.Asset == r.quote {
+			quote = balance.Free + balance.Lock
+			cash = balance.Free
+		}
+	}
+
+	pairs := make([]string, 0, len(r.weights))
+	for pair := range r.weights {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+
+	total := quote
+	allocations := make([]Allocation, 0, len(pairs))
+	for _, pair := range pairs {
+		price, ok := prices[pair]
+		if !ok || price <= 0 {
+			return nil, 0, 0, fmt.Errorf("%w: %s", ErrMissingPrice, pair)
+		}
+
+		asset, _ := exchange.SplitAssetQuote(pair)
+		balance, _ := account.Balance(asset, r.quote)
+		quantity := balance.Free + balance.Lock
+
+		target := 0.0
+		if totalWeight > 0 {
+			target = r.weights[pair] / totalWeight * (1 - r.cashBuffer)
+		}
+
+		allocations = append(allocations, Allocation{
+			Pair:     pair,
+			Price:    price,
+			Quantity: quantity,
+			Value:    quantity * price,
+			Target:   target,
+		})
+		total += quantity * price
+	}
+
+	for i := range allocations {
+		if total > 0 {
+			allocations[i].Weight = allocations[i].Value / total
+		}
+	}
+
+	return allocations, total, cash, nil
+}
+
+// MaxDrift returns the largest difference between the current and the target weight of a pair
+func MaxDrift(allocations []Allocation) float64 {
+	var drift float64
+	for _, allocation := range allocations {
+		drift = math.Max(drift, math.Abs(allocation.Weight-allocation.Target))
+	}
+	return drift
+}
+
+// Plan returns the orders needed to reach the target weights, sells first. Quantities are rounded down to
+// the step size of the pair, orders under the minimum quantity are skipped and buys are limited by the cash,
+// the free quote and the value of sells without the fee. Quote locked by pending orders is not spent.
+func (r *Rebalancer) Plan(prices map[string]float64) ([]Trade, error) {
+	allocations, total, cash, err := r.allocations(prices)
+	if err != nil {
+		return nil, err
+	}
+
+	var sells, buys []Trade
+	for _, allocation := range allocations {
+		info := r.broker.AssetsInfo(allocation.Pair)
+		diff := allocation.Target*total - allocation.Value
+		quantity := sizing.RoundStep(math.Abs(diff)/allocation.Price, info.StepSize)
+		if diff < 0 {
+			// sell the whole position when the target is zero, even odd lots
+			if allocation.Target == 0 {
+				quantity = allocation.Quantity
+			}
+			quantity = math.Min(quantity, allocation.Quantity)
+		}
+
+		if quantity <= 0 || quantity < info.MinQuantity {
+			continue
+		}
+
+		trade := Trade{
+			Pair:     allocation.Pair,
+			Quantity: quantity,
+			Value:    quantity * allocation.Price,
+		}
+
+		if diff < 0 {
+			trade.Side = model.SideTypeSell
+			sells = append(sells, trade)
+			cash += trade.Value * (1 - r.fee)
+		} else {
+			trade.Side = model.SideTypeBuy
+			buys = append(buys, trade)
+		}
+	}
+
+	for i := range buys {
+		if buys[i].Value*(1+r.fee) > cash {
+			info := r.broker.AssetsInfo(buys[i].Pair)
+			price := buys[i].Value / buys[i].Quantity
+			buys[i].Quantity = sizing.RoundStep(math.Max(cash, 0)/(1+r.fee)/price, info.StepSize)
+			buys[i].Value = buys[i].Quantity * price
+		}
+		cash -= buys[i].Value * (1 + r.fee)
+	}
+
+	trades := sells
+	for _, buy := range buys {
+		if buy.Quantity > 0 && buy.Quantity >= r.broker.AssetsInfo(buy.Pair).MinQuantity {
+			trades = append(trades, buy)
+		}
+	}
+
+	return trades, nil
+}
+
+// Rebalance creates market orders to reach the target weights when the trigger fires
+func (r *Rebalancer) Rebalance(now time.Time, prices map[string]float64) ([]model.Order, error) {
+	if r.trigger != nil {
+		allocations, _, err := r.Allocations(prices)
+		if err != nil {
+			return nil, err
+		}
+
+		if !r.trigger(r.last, now, MaxDrift(allocations)) {
+			return nil, nil
+		}
+	}
+
+	trades, err := r.Plan(prices)
+	if err != nil {
+		return nil, err
+	}
+	r.last = now
+
+	var orders []model.Order
+	var errs []error
+	for _, trade := range trades {
+		order, err := r.broker.CreateOrderMarket(trade.Side, trade.Pair, trade.Quantity)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rebalance %s: %w", trade.Pair, err))
+			continue
+		}
+		orders = append(orders, order)
+	}
+
+	return orders, errors.Join(errs...)
+}
@@ -0,0 +1,167 @@
+package rebalance
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ezquant/azbot/azbot/exchange"
+	"github.com/ezquant/azbot/azbot/model"
+)
+
+func paperWallet(t *testing.T, prices map[string]float64) *exchange.PaperWallet {
+	t.Helper()
+
+	wallet := exchange.NewPaperWallet(context.Background(), "USDT", exchange.WithPaperAsset("USDT", 10000))
+	updatePrices(wallet, prices)
+	return wallet
+}
+
+func updatePrices(wallet *exchange.PaperWallet, prices map[string]float64) {
+	for pair, price := range prices {
+		wallet.OnCandle(model.Candle{Pair: pair, Open: price, High: price, Low: price, Close: price})
+	}
+}
+
+func position(t *testing.T, wallet *exchange.PaperWallet, pair string) float64 {
+	t.Helper()
+
+	asset, _, err := wallet.Position(pair)
+	require.NoError(t, err)
+	return asset
+}
+
+func TestRebalancer_Rebalance(t *testing.T) {
+	prices := map[string]float64{"BTCUSDT": 100, "ETHUSDT": 10}
+	wallet := paperWallet(t, prices)
+	rebalancer := New(wallet, "USDT", map[string]float64{"BTCUSDT": 1, "ETHUSDT": 1},
+		WithTrigger(Drift(0.1)))
+
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	orders, err := rebalancer.Rebalance(now, prices)
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	require.InDelta(t, 50, position(t, wallet, "BTCUSDT"), 1e-6)
+	require.InDelta(t, 500, position(t, wallet, "ETHUSDT"), 1e-6)
+
+	// drift of 1/6 triggers the rebalance, BTC is sold before ETH is bought
+	prices["BTCUSDT"] = 200
+	updatePrices(wallet, prices)
+	allocations, total, err := rebalancer.Allocations(prices)
+	require.NoError(t, err)
+	require.InDelta(t, 15000, total, 1e-6)
+	require.InDelta(t, 1.0/6, MaxDrift(allocations), 1e-9)
+
+	orders, err = rebalancer.Rebalance(now.Add(time.Hour), prices)
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	require.Equal(t, model.SideTypeSell, orders[0].Side)
+	require.Equal(t, "BTCUSDT", orders[0].Pair)
+	require.InDelta(t, 37.5, position(t, wallet, "BTCUSDT"), 1e-6)
+	require.InDelta(t, 750, position(t, wallet, "ETHUSDT"), 1e-6)
+
+	// small drift is ignored
+	prices["ETHUSDT"] = 10.5
+	updatePrices(wallet, prices)
+	orders, err = rebalancer.Rebalance(now.Add(2*time.Hour), prices)
+	require.NoError(t, err)
+	require.Empty(t, orders)
+
+	t.Run("missing price", func(t *testing.T) {
+		_, err := rebalancer.Rebalance(now, map[string]float64{"BTCUSDT": 100})
+		require.ErrorIs(t, err, ErrMissingPrice)
+	})
+}
+
+func TestRebalancer_CashBuffer(t *testing.T) {
+	prices := map[string]float64{"BTCUSDT": 100, "ETHUSDT": 10}
+	wallet := paperWallet(t, prices)
+	rebalancer := New(wallet, "USDT", map[string]float64{"BTCUSDT": 3, "ETHUSDT": 1}, WithCashBuffer(0.2))
+
+	_, err := rebalancer.Rebalance(time.Now(), prices)
+	require.NoError(t, err)
+	require.InDelta(t, 60, position(t, wallet, "BTCUSDT"), 1e-6)
+	require.InDelta(t, 200, position(t, wallet, "ETHUSDT"), 1e-6)
+
+	_, quote, err := wallet.Position("BTCUSDT")
+	require.NoError(t, err)
+	require.InDelta(t, 2000, quote, 1e-6)
+
+	// zero weight sells the whole position
+	rebalancer = New(wallet, "USDT", map[string]float64{"BTCUSDT": 1, "ETHUSDT": 0})
+	trades, err := rebalancer.Plan(prices)
+	require.NoError(t, err)
+	require.Equal(t, []Trade{
+		{Pair: "ETHUSDT", Side: model.SideTypeSell, Quantity: 200, Value: 2000},
+		{Pair: "BTCUSDT", Side: model.SideTypeBuy, Quantity: 40, Value: 4000},
+	}, trades)
+}
+
+func TestRebalancer_Fee(t *testing.T) {
+	prices := map[string]float64{"BTCUSDT": 100, "ETHUSDT": 10}
+	wallet := exchange.NewPaperWallet(context.Background(), "USDT",
+		exchange.WithPaperAsset("USDT", 10000),
+		exchange.WithPaperFee(0.001, 0.001))
+	updatePrices(wallet, prices)
+
+	// quote locked by a pending order is not spent
+	_, err := wallet.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 50)
+	require.NoError(t, err)
+
+	rebalancer := New(wallet, "USDT", map[string]float64{"BTCUSDT": 1, "ETHUSDT": 1}, WithFee(0.001))
+	orders, err := rebalancer.Rebalance(time.Now(), prices)
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	require.InDelta(t, 50, position(t, wallet, "BTCUSDT"), 1e-6)
+	require.InDelta(t, 4945/10.01, position(t, wallet, "ETHUSDT"), 1e-6)
+
+	account, err := wallet.Account()
+	require.NoError(t, err)
+	quote, _ := account.Balance("USDT", "USDT")
+	require.InDelta(t, 0, quote.Free, 1e-6)
+	require.InDelta(t, 50, quote.Lock, 1e-6)
+}
+
+// lotWallet trades in lots of 100 units
+type lotWallet struct {
+	*exchange.PaperWallet
+}
+
+func (w lotWallet) AssetsInfo(pair string) model.AssetInfo {
+	info := w.PaperWallet.AssetsInfo(pair)
+	info.StepSize = 100
+	info.MinQuantity = 100
+	return info
+}
+
+func TestRebalancer_LotSize(t *testing.T) {
+	prices := map[string]float64{"BTCUSDT": 3, "ETHUSDT": 60}
+	wallet := lotWallet{paperWallet(t, prices)}
+	rebalancer := New(wallet, "USDT", map[string]float64{"BTCUSDT": 1, "ETHUSDT": 1})
+
+	trades, err := rebalancer.Plan(prices)
+	require.NoError(t, err)
+	require.Equal(t, []Trade{
+		{Pair: "BTCUSDT", Side: model.SideTypeBuy, Quantity: 1600, Value: 4800},
+	}, trades, "ETH quantity is under the lot size")
+}
+
+func TestTriggers(t *testing.T) {
+	start := time.Date(2021, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	every := Every(24 * time.Hour)
+	require.True(t, every(time.Time{}, start, 0))
+	require.False(t, every(start, start.Add(time.Hour), 0))
+	require.True(t, every(start, start.Add(24*time.Hour), 0))
+
+	monthly := Monthly()
+	require.True(t, monthly(time.Time{}, start, 0))
+	require.False(t, monthly(start.AddDate(0, 0, -10), start, 0))
+	require.True(t, monthly(start, start.AddDate(0, 0, 1), 0))
+
+	trigger := Any(Monthly(), Drift(0.05))
+	require.True(t, trigger(start.AddDate(0, 0, -10), start, 0.05))
+	require.False(t, trigger(start.AddDate(0, 0, -10), start, 0.01))
+}
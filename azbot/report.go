@@ -0,0 +1,141 @@
+package azbot
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ezquant/azbot/azbot/exchange"
+	"github.com/ezquant/azbot/azbot/metrics"
+	"github.com/ezquant/azbot/azbot/order"
+	"github.com/ezquant/azbot/azbot/tools/log"
+)
+
+// PairReport contains the trade statistics of a single pair
+type PairReport struct {
+	Pair          string
+	Trades        int
+	Win           int
+	Loss          int
+	WinPercentage float64
+	Payoff        float64
+	SQN           float64
+	Profit        float64
+	Volume        float64
+	Fees          float64
+	// Profits contains the result of each closed trade, in the order they were closed
+	Profits []float64
+}
+
+// BacktestReport is a structured version of the bot summary. Portfolio values are filled
+// only when the bot runs with a paper wallet.
+type BacktestReport struct {
+	Start time.Time
+	End   time.Time
+
+	InitialValue float64
+	FinalValue   float64
+	Profit       float64
+	Return       float64
+	MarketChange float64
+
+	MaxDrawdown      float64
+	MaxDrawdownStart time.Time
+	MaxDrawdownEnd   time.Time
+	// Exposure is the fraction of candles with an open position, the time in market
+	Exposure float64
+
+	Trades int
+	Volume float64
+	Fees   float64
+	Pairs  []PairReport
+	Equity []exchange.AssetValue
+	// ClosedTrades contains the closed trades of all pairs in the order they were closed
+	ClosedTrades []order.Trade
+
+	// Metrics contains risk and return statistics of the equity curve, annualized by the strategy timeframe
+	Metrics metrics.Metrics
+}
+
+// Report returns the bot results as a BacktestReport, it is safe to call after `Run` returns
+func (n *AzBot) Report() BacktestReport {
+	report := BacktestReport{
+		Pairs: make([]PairReport, 0, len(n.orderController.Results)),
+	}
+
+	for _, summary := range n.orderController.Results {
+		trades := len(summary.Win()) + len(summary.Lose())
+		pair := PairReport{
+			Pair:          summary.Pair,
+			Trades:        trades,
+			Win:           len(summary.Win()),
+			Loss:          len(summary.Lose()),
+			WinPercentage: summary.WinPercentage(),
+			Payoff:        summary.Payoff(),
+			Profit:        summary.Profit(),
+			Volume:        summary.Volume,
+		}
+		for _, trade := range summary.Trades {
+			pair.Profits = append(pair.Profits, trade.Profit)
+		}
+		report.ClosedTrades = append(report.ClosedTrades, summary.Trades...)
+		if trades > 0 {
+			pair.SQN = summary.SQN()
+		}
+
+		report.Trades += pair.Trades
+		report.Volume += pair.Volume
+		report.Pairs = append(report.Pairs, pair)
+	}
+
+	sort.Slice(report.Pairs, func(i, j int) bool {
+		return report.Pairs[i].Pair < report.Pairs[j].Pair
+	})
+
+	// trades of a pair are already in order, pairs closed at the same time are sorted by name
+	sort.SliceStable(report.ClosedTrades, func(i, j int) bool {
+		a, b := report.ClosedTrades[i], report.ClosedTrades[j]
+		if a.Time.Equal(b.Time) {
+			return a.Pair < b.Pair
+		}
+		return a.Time.Before(b.Time)
+	})
+
+	if n.paperWallet == nil {
+		return report
+	}
+
+	report.Equity = n.paperWallet.EquityValues()
+	if len(report.Equity) > 0 {
+		report.Start = report.Equity[0].Time
+		report.End = report.Equity[len(report.Equity)-1].Time
+	}
+
+	report.InitialValue = n.paperWallet.InitialValue()
+	report.FinalValue = n.paperWallet.Value()
+	report.Profit = report.FinalValue - report.InitialValue
+	if report.InitialValue > 0 {
+		report.Return = report.Profit / report.InitialValue
+	}
+	report.MarketChange = n.paperWallet.MarketChange()
+	report.MaxDrawdown, report.MaxDrawdownStart, report.MaxDrawdownEnd = n.paperWallet.MaxDrawdown()
+	report.Exposure = n.paperWallet.Exposure()
+
+	fees := n.paperWallet.Fees()
+	for i := range report.Pairs {
+		report.Pairs[i].Fees = fees[report.Pairs[i].Pair]
+		report.Fees += report.Pairs[i].Fees
+	}
+
+	trades := make([]float64, 0, report.Trades)
+	for _, pair := range report.Pairs {
+		trades = append(trades, pair.Profits...)
+	}
+
+	var err error
+	report.Metrics, err = metrics.Compute(report.Equity, trades, n.strategy.Timeframe())
+	if err != nil {
+		log.Warnf("report: unable to compute metrics: %v", err)
+	}
+
+	return report
+}
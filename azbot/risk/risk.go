@@ -0,0 +1,366 @@
+// Package risk enforces pre-trade limits on orders created by strategies.
+package risk
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ezquant/azbot/azbot/exchange"
+	"github.com/ezquant/azbot/azbot/model"
+	"github.com/ezquant/azbot/azbot/service"
+	"github.com/ezquant/azbot/azbot/tools/log"
+)
+
+var (
+	ErrPositionLimit = errors.New("max position value exceeded")
+	ErrExposureLimit = errors.New("max exposure exceeded")
+	ErrOpenOrders    = errors.New("max open orders exceeded")
+	ErrDailyLoss     = errors.New("max daily loss reached")
+	ErrKillSwitch    = errors.New("trading halted by kill switch")
+	ErrUnknownPrice  = errors.New("unknown price of the pair")
+)
+
+// Error is an order rejected by the risk manager
+type Error struct {
+	Err      error
+	Pair     string
+	Side     model.SideType
+	Quantity float64
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("risk: %s %s %f rejected: %v", e.Side, e.Pair, e.Quantity, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Limits of the risk manager, zero values are disabled. Values are in the quote asset and losses
+// are ratios of the equity, eg: 0.05 for 5%.
+type Limits struct {
+	// MaxPositionValue is the max value of the position of each pair
+	MaxPositionValue float64
+	// PositionValues overrides MaxPositionValue by pair
+	PositionValues map[string]float64
+	// MaxExposure is the max value of all positions
+	MaxExposure float64
+	// MaxOpenOrders is the max number of pending orders, exits are accepted above the limit
+	MaxOpenOrders int
+	// MaxDailyLoss blocks new positions until the next day (UTC) when the equity drops from the first candle of the day
+	MaxDailyLoss float64
+	// MaxDrawdown halts new positions when the equity drops from its peak, until Resume is called
+	MaxDrawdown float64
+}
+
+// Manager is a broker that validates orders against the limits before they reach the wrapped broker.
+// Orders that reduce a position are always accepted, so strategies can exit even after the kill switch.
+// Orders by quote value are rejected until the manager receives a price of the pair.
+type Manager struct {
+	service.Broker
+
+	mtx      sync.Mutex
+	quote    string
+	limits   Limits
+	notifier service.Notifier
+
+	prices   map[string]float64
+	open     map[int64]model.Order
+	closed   map[int64]bool
+	day      string
+	dayStart float64
+	peak     float64
+	halted   bool
+}
+
+type Option func(*Manager)
+
+// WithNotifier reports rejected orders and the kill switch to the notifier, eg: telegram
+func WithNotifier(notifier service.Notifier) Option {
+	return func(m *Manager) {
+		m.notifier = notifier
+	}
+}
+
+// NewManager creates a risk manager of pairs quoted in the given asset, eg: USDT
+func NewManager(broker service.Broker, quote string, limits Limits, options ...Option) *Manager {
+	manager := &Manager{
+		Broker: broker,
+		quote:  quote,
+		limits: limits,
+		prices: make(map[string]float64),
+		open:   make(map[int64]model.Order),
+		closed: make(map[int64]bool),
+	}
+
+	for _, option := range options {
+		option(manager)
+	}
+
+	return manager
+}
+
+// Halted returns true when the kill switch is active
+func (m *Manager) Halted() bool {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.halted
+}
+
+// Resume disables the kill switch and resets the equity peak
+func (m *Manager) Resume() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.halted = false
+	m.peak = 0
+}
+
+// OnCandle updates prices and checks losses of the account with closed candles
+func (m *Manager) OnCandle(candle model.Candle) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.prices[candle.Pair] = candle.Close
+	if !candle.Complete || (m.limits.MaxDailyLoss == 0 && m.limits.MaxDrawdown == 0) {
+		return
+	}
+
+	equity, err := m.equity()
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	if day := candle.Time.UTC().Format(time.DateOnly); day != m.day {
+		m.day = day
+		m.dayStart = equity
+	}
+
+	m.peak = math.Max(m.peak, equity)
+	if m.limits.MaxDrawdown > 0 && !m.halted && m.peak > 0 && 1-equity/m.peak >= m.limits.MaxDrawdown {
+		m.halted = true
+		m.notify(fmt.Sprintf("risk: kill switch activated, drawdown of %.2f%% from %.2f %s",
+			(1-equity/m.peak)*100, m.peak, m.quote))
+	}
+}
+
+// OnOrder tracks pending orders, it must be subscribed to the order feed. New orders are published
+// asynchronously and may arrive after they are filled or canceled, so closed orders are never reopened.
+func (m *Manager) OnOrder(order model.Order) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	switch order.Status {
+	case model.OrderStatusTypeNew, model.OrderStatusTypePartiallyFilled:
+		if !m.closed[order.ExchangeID] {
+			m.open[order.ExchangeID] = order
+		}
+	default:
+		delete(m.open, order.ExchangeID)
+		m.closed[order.ExchangeID] = true
+	}
+}
+
+func (m *Manager) notify(message string) {
+	log.Warn(message)
+	if m.notifier != nil {
+		m.notifier.Notify(message)
+	}
+}
+
+// equity returns the value of the quote asset and positions with known prices
+func (m *Manager) equity() (float64, error) {
+	account, err := m.Broker.Account()
+	if err != nil {
+		return 0, err
+	}
+
+	var equity float64
+	for _, balance := range account.Balances {
+		amount := balance.Free + balance.Lock
+		if balance.Asset == m.quote {
+			equity += amount
+			continue
+		}
+		equity += amount * m.prices[balance.Asset+m.quote]
+	}
+	return equity, nil
+}
+
+// exposure returns the value of all positions with known prices
+func (m *Manager) exposure(account model.Account) float64 {
+	var exposure float64
+	for _, balance := range account.Balances {
+		if balance.Asset != m.quote {
+			exposure += math.Abs(balance.Free+balance.Lock) * m.prices[balance.Asset+m.quote]
+		}
+	}
+	return exposure
+}
+
+// reject reports an order rejected by a limit
+func (m *Manager) reject(err error, side model.SideType, pair string, quantity float64) error {
+	riskErr := &Error{Err: err, Pair: pair, Side: side, Quantity: quantity}
+	log.Warn(riskErr)
+	if m.notifier != nil {
+		m.notifier.OnError(riskErr)
+	}
+	return riskErr
+}
+
+// check validates a new order, price is zero when unknown
+func (m *Manager) check(side model.SideType, pair string, quantity, price float64, orders int) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	reject := func(err error) error {
+		return m.reject(err, side, pair, quantity)
+	}
+
+	account, err := m.Broker.Account()
+	if err != nil {
+		return err
+	}
+
+	asset, _ := exchange.SplitAssetQuote(pair)
+	assetBalance, _ := account.Balance(asset, m.quote)
+	position := assetBalance.Free + assetBalance.Lock
+	target := position + quantity
+	if side == model.SideTypeSell {
+		target = position - quantity
+	}
+
+	// only orders with a known quantity that reduce the position are exits
+	reducing := quantity > 0 && math.Abs(target) <= math.Abs(position)
+
+	if m.limits.MaxOpenOrders > 0 && !reducing && len(m.open)+orders > m.limits.MaxOpenOrders {
+		return reject(ErrOpenOrders)
+	}
+
+	if m.halted && !reducing {
+		return reject(ErrKillSwitch)
+	}
+
+	if m.limits.MaxDailyLoss > 0 && m.dayStart > 0 && !reducing {
+		equity, err := m.equity()
+		if err != nil {
+			return err
+		}
+
+		if 1-equity/m.dayStart >= m.limits.MaxDailyLoss {
+			return reject(ErrDailyLoss)
+		}
+	}
+
+	// reducing a position is always allowed
+	if reducing {
+		return nil
+	}
+
+	if price == 0 {
+		price = m.prices[pair]
+	}
+
+	maxPosition := m.limits.MaxPositionValue
+	if value, ok := m.limits.PositionValues[pair]; ok {
+		maxPosition = value
+	}
+
+	if maxPosition > 0 && math.Abs(target)*price > maxPosition {
+		return reject(ErrPositionLimit)
+	}
+
+	increase := (math.Abs(target) - math.Abs(position)) * price
+	if m.limits.MaxExposure > 0 && m.exposure(account)+increase > m.limits.MaxExposure {
+		return reject(ErrExposureLimit)
+	}
+
+	return nil
+}
+
+// AssetsInfo returns the trading limits of the pair when the wrapped broker provides them
+func (m *Manager) AssetsInfo(pair string) model.AssetInfo {
+	if broker, ok := m.Broker.(interface {
+		AssetsInfo(pair string) model.AssetInfo
+	}); ok {
+		return broker.AssetsInfo(pair)
+	}
+
+	asset, quote := exchange.SplitAssetQuote(pair)
+	return model.AssetInfo{BaseAsset: asset, QuoteAsset: quote}
+}
+
+func (m *Manager) track(orders ...model.Order) {
+	for _, order := range orders {
+		m.OnOrder(order)
+	}
+}
+
+func (m *Manager) CreateOrderOCO(side model.SideType, pair string, size, price, stop,
+	stopLimit float64) ([]model.Order, error) {
+
+	if err := m.check(side, pair, size, price, 2); err != nil {
+		return nil, err
+	}
+
+	orders, err := m.Broker.CreateOrderOCO(side, pair, size, price, stop, stopLimit)
+	if err != nil {
+		return nil, err
+	}
+	m.track(orders...)
+	return orders, nil
+}
+
+func (m *Manager) CreateOrderLimit(side model.SideType, pair string, size float64,
+	limit float64) (model.Order, error) {
+
+	if err := m.check(side, pair, size, limit, 1); err != nil {
+		return model.Order{}, err
+	}
+
+	order, err := m.Broker.CreateOrderLimit(side, pair, size, limit)
+	if err != nil {
+		return model.Order{}, err
+	}
+	m.track(order)
+	return order, nil
+}
+
+func (m *Manager) CreateOrderMarket(side model.SideType, pair string, size float64) (model.Order, error) {
+	if err := m.check(side, pair, size, 0, 0); err != nil {
+		return model.Order{}, err
+	}
+	return m.Broker.CreateOrderMarket(side, pair, size)
+}
+
+func (m *Manager) CreateOrderMarketQuote(side model.SideType, pair string, quote float64) (model.Order, error) {
+	m.mtx.Lock()
+	price := m.prices[pair]
+	m.mtx.Unlock()
+
+	// without price the quantity is unknown, the order could open a position of any size
+	if price == 0 {
+		return model.Order{}, m.reject(ErrUnknownPrice, side, pair, 0)
+	}
+
+	if err := m.check(side, pair, quote/price, price, 0); err != nil {
+		return model.Order{}, err
+	}
+	return m.Broker.CreateOrderMarketQuote(side, pair, quote)
+}
+
+func (m *Manager) CreateOrderStop(pair string, quantity float64, limit float64) (model.Order, error) {
+	if err := m.check(model.SideTypeSell, pair, quantity, limit, 1); err != nil {
+		return model.Order{}, err
+	}
+
+	order, err := m.Broker.CreateOrderStop(pair, quantity, limit)
+	if err != nil {
+		return model.Order{}, err
+	}
+	m.track(order)
+	return order, nil
+}
@@ -0,0 +1,198 @@
+package risk
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ezquant/azbot/azbot/exchange"
+	"github.com/ezquant/azbot/azbot/model"
+)
+
+type notifier struct {
+	messages []string
+	errors   []error
+}
+
+func (n *notifier) Notify(message string) {
+	n.messages = append(n.messages, message)
+}
+
+func (n *notifier) OnOrder(_ model.Order) {}
+
+func (n *notifier) OnError(err error) {
+	n.errors = append(n.errors, err)
+}
+
+func newManager(t *testing.T, limits Limits, options ...Option) (*Manager, *exchange.PaperWallet) {
+	t.Helper()
+
+	wallet := exchange.NewPaperWallet(context.Background(), "USDT", exchange.WithPaperAsset("USDT", 10000))
+	manager := NewManager(wallet, "USDT", limits, options...)
+	updatePrice(manager, wallet, time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), "BTCUSDT", 100)
+	updatePrice(manager, wallet, time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), "ETHUSDT", 10)
+	return manager, wallet
+}
+
+func updatePrice(manager *Manager, wallet *exchange.PaperWallet, now time.Time, pair string, price float64) {
+	candle := model.Candle{
+		Pair: pair, Time: now, Open: price, High: price, Low: price, Close: price, Complete: true,
+	}
+	wallet.OnCandle(candle)
+	manager.OnCandle(candle)
+}
+
+func TestManager_PositionLimit(t *testing.T) {
+	n := &notifier{}
+	manager, _ := newManager(t, Limits{
+		MaxPositionValue: 1000,
+		PositionValues:   map[string]float64{"ETHUSDT": 2000},
+	}, WithNotifier(n))
+
+	_, err := manager.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 10)
+	require.NoError(t, err)
+
+	_, err = manager.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.ErrorIs(t, err, ErrPositionLimit)
+
+	var riskErr *Error
+	require.True(t, errors.As(err, &riskErr))
+	require.Equal(t, "BTCUSDT", riskErr.Pair)
+	require.Len(t, n.errors, 1)
+
+	// limit price is used to value the order
+	_, err = manager.CreateOrderLimit(model.SideTypeBuy, "ETHUSDT", 150, 20)
+	require.ErrorIs(t, err, ErrPositionLimit)
+	_, err = manager.CreateOrderMarket(model.SideTypeBuy, "ETHUSDT", 150)
+	require.NoError(t, err)
+
+	// reducing the position is allowed
+	_, err = manager.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 5)
+	require.NoError(t, err)
+}
+
+func TestManager_Exposure(t *testing.T) {
+	manager, _ := newManager(t, Limits{MaxExposure: 3000})
+
+	_, err := manager.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 20)
+	require.NoError(t, err)
+
+	_, err = manager.CreateOrderMarketQuote(model.SideTypeBuy, "ETHUSDT", 1500)
+	require.ErrorIs(t, err, ErrExposureLimit)
+
+	_, err = manager.CreateOrderMarketQuote(model.SideTypeBuy, "ETHUSDT", 1000)
+	require.NoError(t, err)
+}
+
+func TestManager_OpenOrders(t *testing.T) {
+	manager, _ := newManager(t, Limits{MaxOpenOrders: 2})
+
+	order, err := manager.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 50)
+	require.NoError(t, err)
+
+	_, err = manager.CreateOrderOCO(model.SideTypeBuy, "ETHUSDT", 1, 5, 15, 16)
+	require.ErrorIs(t, err, ErrOpenOrders)
+
+	_, err = manager.CreateOrderLimit(model.SideTypeBuy, "ETHUSDT", 1, 5)
+	require.NoError(t, err)
+
+	// filled orders are released
+	order.Status = model.OrderStatusTypeFilled
+	manager.OnOrder(order)
+	_, err = manager.CreateOrderLimit(model.SideTypeBuy, "ETHUSDT", 1, 5)
+	require.NoError(t, err)
+}
+
+func TestManager_OpenOrdersLateEvent(t *testing.T) {
+	manager, _ := newManager(t, Limits{MaxOpenOrders: 1})
+
+	order, err := manager.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 50)
+	require.NoError(t, err)
+
+	// the new order event is published after the order is filled
+	filled := order
+	filled.Status = model.OrderStatusTypeFilled
+	manager.OnOrder(filled)
+	manager.OnOrder(order)
+
+	_, err = manager.CreateOrderLimit(model.SideTypeBuy, "ETHUSDT", 1, 5)
+	require.NoError(t, err)
+}
+
+func TestManager_OpenOrdersExit(t *testing.T) {
+	manager, _ := newManager(t, Limits{MaxOpenOrders: 1})
+
+	_, err := manager.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 2)
+	require.NoError(t, err)
+
+	_, err = manager.CreateOrderLimit(model.SideTypeBuy, "ETHUSDT", 1, 5)
+	require.NoError(t, err)
+
+	_, err = manager.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 50)
+	require.ErrorIs(t, err, ErrOpenOrders)
+
+	// exits are accepted with the limit of open orders reached
+	_, err = manager.CreateOrderStop("BTCUSDT", 1, 90)
+	require.NoError(t, err)
+
+	_, err = manager.CreateOrderOCO(model.SideTypeSell, "BTCUSDT", 1, 120, 90, 90)
+	require.NoError(t, err)
+}
+
+func TestManager_DailyLoss(t *testing.T) {
+	manager, wallet := newManager(t, Limits{MaxDailyLoss: 0.05})
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := manager.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 50)
+	require.NoError(t, err)
+
+	// equity drops from 10000 to 7500
+	updatePrice(manager, wallet, start.Add(time.Hour), "BTCUSDT", 50)
+	_, err = manager.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.ErrorIs(t, err, ErrDailyLoss)
+
+	_, err = manager.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+	require.NoError(t, err)
+
+	// new day resets the loss
+	updatePrice(manager, wallet, start.AddDate(0, 0, 1), "BTCUSDT", 50)
+	_, err = manager.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+}
+
+func TestManager_KillSwitch(t *testing.T) {
+	n := &notifier{}
+	manager, wallet := newManager(t, Limits{MaxDrawdown: 0.2}, WithNotifier(n))
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := manager.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 100)
+	require.NoError(t, err)
+
+	updatePrice(manager, wallet, start.AddDate(0, 0, 1), "BTCUSDT", 90)
+	require.False(t, manager.Halted())
+
+	updatePrice(manager, wallet, start.AddDate(0, 0, 2), "BTCUSDT", 75)
+	require.True(t, manager.Halted())
+	require.Len(t, n.messages, 1)
+
+	// kill switch is kept after recovery, exits are allowed
+	updatePrice(manager, wallet, start.AddDate(0, 0, 3), "BTCUSDT", 100)
+	_, err = manager.CreateOrderMarket(model.SideTypeBuy, "ETHUSDT", 1)
+	require.ErrorIs(t, err, ErrKillSwitch)
+	// the quantity of quote orders is unknown without price, they are not exits
+	_, err = manager.CreateOrderMarketQuote(model.SideTypeSell, "SOLUSDT", 100)
+	require.ErrorIs(t, err, ErrUnknownPrice)
+	_, err = manager.CreateOrderMarketQuote(model.SideTypeSell, "BTCUSDT", 100)
+	require.NoError(t, err)
+
+	_, err = manager.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 99)
+	require.NoError(t, err)
+
+	manager.Resume()
+	require.False(t, manager.Halted())
+	_, err = manager.CreateOrderMarket(model.SideTypeBuy, "ETHUSDT", 1)
+	require.NoError(t, err)
+}
@@ -0,0 +1,108 @@
+package sizing
+
+import (
+	"math"
+	"sort"
+
+	"github.com/ezquant/azbot/azbot/model"
+)
+
+// EqualRiskContribution returns the weights of the pairs that make each pair contribute the same risk to the
+// portfolio, from the covariance of the returns of the last period candles. Dataframes must be synchronized,
+// eg: dataframes of a PortfolioStrategy. Weights sum to one and can be used with rebalance.New.
+func EqualRiskContribution(dataframes map[string]*model.Dataframe, period int) (map[string]float64, error) {
+	pairs := make([]string, 0, len(dataframes))
+	for pair := range dataframes {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+
+	returns := make([][]float64, len(pairs))
+	for i, pair := range pairs {
+		var err error
+		returns[i], err = lastReturns(dataframes[pair].Close, period)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	weights := make(map[string]float64, len(pairs))
+	for i, weight := range riskParity(covariance(returns)) {
+		weights[pairs[i]] = weight
+	}
+	return weights, nil
+}
+
+// covariance returns the covariance matrix of the series of returns
+func covariance(returns [][]float64) [][]float64 {
+	means := make([]float64, len(returns))
+	for i, series := range returns {
+		for _, r := range series {
+			means[i] += r
+		}
+		means[i] /= float64(len(series))
+	}
+
+	cov := make([][]float64, len(returns))
+	for i := range returns {
+		cov[i] = make([]float64, len(returns))
+		for j := range returns {
+			for k := range returns[i] {
+				cov[i][j] += (returns[i][k] - means[i]) * (returns[j][k] - means[j])
+			}
+			cov[i][j] /= float64(len(returns[i]))
+		}
+	}
+	return cov
+}
+
+// riskParity solves the weights where w_i * (cov * w)_i is the same for all assets with a damped
+// fixed-point iteration, starting from the inverse volatility weights
+func riskParity(cov [][]float64) []float64 {
+	n := len(cov)
+	weights := make([]float64, n)
+	for i := range weights {
+		weights[i] = 1
+		if cov[i][i] > 0 {
+			weights[i] = 1 / math.Sqrt(cov[i][i])
+		}
+	}
+	normalize(weights)
+
+	for iteration := 0; iteration < 1000; iteration++ {
+		next := make([]float64, n)
+		for i := range cov {
+			var marginal float64
+			for j := range cov {
+				marginal += cov[i][j] * weights[j]
+			}
+			if marginal <= 0 {
+				return weights
+			}
+			next[i] = 1 / marginal
+		}
+		normalize(next)
+
+		var change float64
+		for i := range weights {
+			next[i] = (weights[i] + next[i]) / 2
+			change = math.Max(change, math.Abs(next[i]-weights[i]))
+		}
+		weights = next
+		if change < 1e-10 {
+			break
+		}
+	}
+
+	return weights
+}
+
+func normalize(weights []float64) {
+	var total float64
+	for _, weight := range weights {
+		total += weight
+	}
+	for i := range weights {
+		weights[i] /= total
+	}
+}
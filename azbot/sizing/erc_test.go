@@ -0,0 +1,46 @@
+package sizing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ezquant/azbot/azbot/model"
+)
+
+func TestEqualRiskContribution(t *testing.T) {
+	// uncorrelated returns, ETH is twice as volatile as BTC
+	dataframes := map[string]*model.Dataframe{
+		"BTCUSDT": {Close: []float64{100, 101, 99.99, 100.9899, 99.980001}},
+		"ETHUSDT": {Close: []float64{100, 98, 96.04, 97.9608, 99.920016}},
+	}
+
+	weights, err := EqualRiskContribution(dataframes, 4)
+	require.NoError(t, err)
+	require.InDelta(t, 2.0/3, weights["BTCUSDT"], 1e-6)
+	require.InDelta(t, 1.0/3, weights["ETHUSDT"], 1e-6)
+
+	t.Run("correlated", func(t *testing.T) {
+		cov := [][]float64{
+			{0.04, 0.006, 0},
+			{0.006, 0.01, 0.002},
+			{0, 0.002, 0.0225},
+		}
+		weights := riskParity(cov)
+
+		contributions := make([]float64, len(weights))
+		for i := range cov {
+			for j := range cov {
+				contributions[i] += weights[i] * cov[i][j] * weights[j]
+			}
+		}
+		require.InDelta(t, contributions[0], contributions[1], 1e-9)
+		require.InDelta(t, contributions[0], contributions[2], 1e-9)
+		require.InDelta(t, 1, weights[0]+weights[1]+weights[2], 1e-9)
+	})
+
+	t.Run("not enough data", func(t *testing.T) {
+		_, err := EqualRiskContribution(dataframes, 10)
+		require.ErrorIs(t, err, ErrNotEnoughData)
+	})
+}
@@ -0,0 +1,202 @@
+// Package sizing calculates the quantity of new positions from the account and the dataframe of a pair.
+package sizing
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/ezquant/azbot/azbot/indicator"
+	"github.com/ezquant/azbot/azbot/model"
+	"github.com/ezquant/azbot/azbot/service"
+)
+
+var (
+	ErrNotEnoughData = errors.New("not enough data")
+	ErrInvalidPrice  = errors.New("invalid price")
+)
+
+// Sizer returns the quantity to buy of the pair of the dataframe, rounded to the trading limits of the pair
+type Sizer interface {
+	Size(df *model.Dataframe, broker service.Broker) (float64, error)
+}
+
+// SizerFunc returns the value in quote of the position given the equity of the pair, the sum of the quote
+// and the asset position, and the last close price
+type SizerFunc func(df *model.Dataframe, equity, price float64) (float64, error)
+
+// Size converts the position value to a quantity limited by the free quote and rounded to the step size,
+// it returns zero when the quantity is under the minimum quantity of the pair
+func (f SizerFunc) Size(df *model.Dataframe, broker service.Broker) (float64, error) {
+	if len(df.Close) == 0 {
+		return 0, ErrNotEnoughData
+	}
+
+	price := df.Close.Last(0)
+	if price <= 0 {
+		return 0, fmt.Errorf("%w: %s %f", ErrInvalidPrice, df.Pair, price)
+	}
+
+	asset, quote, err := broker.Position(df.Pair)
+	if err != nil {
+		return 0, err
+	}
+
+	value, err := f(df, quote+asset*price, price)
+	if err != nil {
+		return 0, err
+	}
+
+	quantity := math.Min(math.Max(value, 0), quote) / price
+	return Round(quantity, assetsInfo(broker, df.Pair)), nil
+}
+
+// assetsInfo returns the trading limits of the pair when the broker provides them, eg: order.Controller
+func assetsInfo(broker service.Broker, pair string) model.AssetInfo {
+	if broker, ok := broker.(interface {
+		AssetsInfo(pair string) model.AssetInfo
+	}); ok {
+		return broker.AssetsInfo(pair)
+	}
+	return model.AssetInfo{}
+}
+
+// RoundStep rounds the quantity down to a multiple of step
+func RoundStep(quantity, step float64) float64 {
+	if step <= 0 {
+		return quantity
+	}
+	// tolerance for floating point errors, eg: 0.3 / 0.1 = 2.9999999999999996
+	return math.Floor(quantity/step+1e-9) * step
+}
+
+// Round rounds the quantity down to the step size and limits it to the max quantity,
+// quantities under the minimum quantity are zero
+func Round(quantity float64, info model.AssetInfo) float64 {
+	if info.MaxQuantity > 0 {
+		quantity = math.Min(quantity, info.MaxQuantity)
+	}
+
+	quantity = RoundStep(quantity, info.StepSize)
+	if quantity <= 0 || quantity < info.MinQuantity {
+		return 0
+	}
+	return quantity
+}
+
+// FixedFractional invests a fraction of the equity, eg: 0.1 for 10%
+func FixedFractional(fraction float64) Sizer {
+	return SizerFunc(func(_ *model.Dataframe, equity, _ float64) (float64, error) {
+		return equity * fraction, nil
+	})
+}
+
+// FixedNotional invests a fixed value in quote, eg: 1000 USDT
+func FixedNotional(value float64) Sizer {
+	return SizerFunc(func(_ *model.Dataframe, _, _ float64) (float64, error) {
+		return value, nil
+	})
+}
+
+// FreeQuote invests a fraction of the free quote, eg: 0.3 for 30%
+func FreeQuote(fraction float64) Sizer {
+	return freeQuote(fraction)
+}
+
+type freeQuote float64
+
+func (f freeQuote) Size(df *model.Dataframe, broker service.Broker) (float64, error) {
+	_, quote, err := broker.Position(df.Pair)
+	if err != nil {
+		return 0, err
+	}
+
+	return SizerFunc(func(_ *model.Dataframe, _, _ float64) (float64, error) {
+		return quote * float64(f), nil
+	}).Size(df, broker)
+}
+
+// ATR risks a fraction of the equity with a stop loss at a multiple of the average true range,
+// eg: ATR(0.01, 14, 2) loses 1% of the equity when the price drops 2 ATRs
+func ATR(risk float64, period int, multiplier float64) Sizer {
+	return SizerFunc(func(df *model.Dataframe, equity, price float64) (float64, error) {
+		if len(df.Close) <= period {
+			return 0, ErrNotEnoughData
+		}
+
+		atr := indicator.ATR(df.High, df.Low, df.Close, period)
+		stop := atr[len(atr)-1] * multiplier
+		if stop <= 0 {
+			return 0, nil
+		}
+		return equity * risk / stop * price, nil
+	})
+}
+
+// VolatilityTarget invests the fraction of the equity that makes the volatility of the position equal to the
+// target, where volatility is the standard deviation of the returns of the last period candles, eg: 0.02 for
+// 2% per candle. The position is limited to the equity.
+func VolatilityTarget(target float64, period int) Sizer {
+	return SizerFunc(func(df *model.Dataframe, equity, _ float64) (float64, error) {
+		volatility, err := Volatility(df.Close, period)
+		if err != nil {
+			return 0, err
+		}
+
+		if volatility == 0 {
+			return equity, nil
+		}
+		return equity * math.Min(target/volatility, 1), nil
+	})
+}
+
+// Kelly invests a fraction of the Kelly criterion given the rate of winning trades and the payoff,
+// the average win over the average loss, eg: Kelly(0.55, 1.5, 0.5) for half Kelly. A payoff of zero
+// or less has no edge and invests nothing.
+func Kelly(winRate, payoff, fraction float64) Sizer {
+	var kelly float64
+	if payoff > 0 {
+		kelly = winRate - (1-winRate)/payoff
+	}
+
+	return SizerFunc(func(_ *model.Dataframe, equity, _ float64) (float64, error) {
+		return equity * math.Max(kelly*fraction, 0), nil
+	})
+}
+
+// Volatility returns the standard deviation of the returns of the last period prices
+func Volatility(prices model.Series[float64], period int) (float64, error) {
+	returns, err := lastReturns(prices, period)
+	if err != nil {
+		return 0, err
+	}
+
+	var mean float64
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+
+	var variance float64
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	return math.Sqrt(variance / float64(len(returns))), nil
+}
+
+// lastReturns returns the simple returns of the last period prices
+func lastReturns(prices model.Series[float64], period int) ([]float64, error) {
+	if period < 1 || len(prices) <= period {
+		return nil, ErrNotEnoughData
+	}
+
+	prices = prices[len(prices)-period-1:]
+	returns := make([]float64, period)
+	for i := range returns {
+		if prices[i] <= 0 {
+			return nil, ErrInvalidPrice
+		}
+		returns[i] = prices[i+1]/prices[i] - 1
+	}
+	return returns, nil
+}
@@ -0,0 +1,140 @@
+package sizing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ezquant/azbot/azbot/exchange"
+	"github.com/ezquant/azbot/azbot/model"
+)
+
+func dataframe(prices ...float64) *model.Dataframe {
+	df := &model.Dataframe{Pair: "BTCUSDT"}
+	for _, price := range prices {
+		df.Close = append(df.Close, price)
+		df.High = append(df.High, price+1)
+		df.Low = append(df.Low, price-1)
+	}
+	return df
+}
+
+func paperWallet(t *testing.T, price float64, options ...exchange.PaperWalletOption) *exchange.PaperWallet {
+	t.Helper()
+
+	options = append([]exchange.PaperWalletOption{exchange.WithPaperAsset("USDT", 10000)}, options...)
+	wallet := exchange.NewPaperWallet(context.Background(), "USDT", options...)
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Open: price, High: price, Low: price, Close: price})
+	return wallet
+}
+
+// lotWallet trades in lots of 100 units
+type lotWallet struct {
+	*exchange.PaperWallet
+}
+
+func (w lotWallet) AssetsInfo(pair string) model.AssetInfo {
+	info := w.PaperWallet.AssetsInfo(pair)
+	info.StepSize = 100
+	info.MinQuantity = 100
+	return info
+}
+
+func TestRound(t *testing.T) {
+	info := model.AssetInfo{StepSize: 0.1, MinQuantity: 0.5, MaxQuantity: 10}
+	require.InDelta(t, 0.3, Round(0.3/0.1*0.1, model.AssetInfo{StepSize: 0.1}), 1e-9)
+	require.InDelta(t, 1.2, Round(1.25, info), 1e-9)
+	require.Zero(t, Round(0.45, info))
+	require.InDelta(t, 10, Round(12, info), 1e-9)
+	require.Equal(t, 1.234, Round(1.234, model.AssetInfo{}))
+}
+
+func TestFixedSizers(t *testing.T) {
+	wallet := paperWallet(t, 100, exchange.WithPaperAsset("BTC", 50))
+	df := dataframe(100)
+
+	// equity of 15000 USDT
+	quantity, err := FixedFractional(0.1).Size(df, wallet)
+	require.NoError(t, err)
+	require.InDelta(t, 15, quantity, 1e-6)
+
+	quantity, err = FixedNotional(1000).Size(df, wallet)
+	require.NoError(t, err)
+	require.InDelta(t, 10, quantity, 1e-6)
+
+	// limited by the free quote
+	quantity, err = FixedFractional(1).Size(df, wallet)
+	require.NoError(t, err)
+	require.InDelta(t, 100, quantity, 1e-6)
+
+	// 30% of 10000 USDT
+	quantity, err = FreeQuote(0.3).Size(df, wallet)
+	require.NoError(t, err)
+	require.InDelta(t, 30, quantity, 1e-6)
+
+	t.Run("lot size", func(t *testing.T) {
+		quantity, err := FixedNotional(25000).Size(dataframe(3), lotWallet{paperWallet(t, 3)})
+		require.NoError(t, err)
+		require.Equal(t, 3300.0, quantity)
+
+		quantity, err = FixedNotional(250).Size(dataframe(3), lotWallet{paperWallet(t, 3)})
+		require.NoError(t, err)
+		require.Zero(t, quantity)
+	})
+
+	t.Run("empty dataframe", func(t *testing.T) {
+		_, err := FixedNotional(1000).Size(dataframe(), wallet)
+		require.ErrorIs(t, err, ErrNotEnoughData)
+	})
+}
+
+func TestATR(t *testing.T) {
+	wallet := paperWallet(t, 100)
+
+	// true range of 2 in all candles
+	quantity, err := ATR(0.01, 3, 2).Size(dataframe(100, 100, 100, 100, 100), wallet)
+	require.NoError(t, err)
+	require.InDelta(t, 25, quantity, 1e-6)
+
+	_, err = ATR(0.01, 14, 2).Size(dataframe(100, 100), wallet)
+	require.ErrorIs(t, err, ErrNotEnoughData)
+}
+
+func TestVolatilityTarget(t *testing.T) {
+	wallet := paperWallet(t, 100)
+
+	// returns of +10% and -10%
+	df := dataframe(100, 110, 99, 108.9, 98.01)
+	volatility, err := Volatility(df.Close, 4)
+	require.NoError(t, err)
+	require.InDelta(t, 0.1, volatility, 1e-9)
+
+	quantity, err := VolatilityTarget(0.02, 4).Size(df, wallet)
+	require.NoError(t, err)
+	require.InDelta(t, 10000*0.2/98.01, quantity, 1e-6)
+
+	// positions are not leveraged
+	quantity, err = VolatilityTarget(0.5, 4).Size(df, wallet)
+	require.NoError(t, err)
+	require.InDelta(t, 10000/98.01, quantity, 1e-6)
+}
+
+func TestKelly(t *testing.T) {
+	wallet := paperWallet(t, 100)
+	df := dataframe(100)
+
+	// 0.6 - 0.4 / 2 = 0.4
+	quantity, err := Kelly(0.6, 2, 0.5).Size(df, wallet)
+	require.NoError(t, err)
+	require.InDelta(t, 20, quantity, 1e-6)
+
+	quantity, err = Kelly(0.3, 1, 1).Size(df, wallet)
+	require.NoError(t, err)
+	require.Zero(t, quantity)
+
+	// without payoff there is no edge
+	quantity, err = Kelly(0.55, 0, 1).Size(df, wallet)
+	require.NoError(t, err)
+	require.Zero(t, quantity)
+}
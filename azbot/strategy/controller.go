@@ -1,17 +1,32 @@
 package strategy
 
 import (
+	"sync"
+	"time"
+
 	log "github.com/sirupsen/logrus"
+	"github.com/xhit/go-str2duration/v2"
 
 	"github.com/ezquant/azbot/azbot/model"
 	"github.com/ezquant/azbot/azbot/service"
 )
 
+// timeframeFeed holds closed candles of an additional timeframe until they are visible to the strategy
+type timeframeFeed struct {
+	duration  time.Duration
+	dataframe *model.Dataframe
+	pending   []model.Candle
+}
+
 type Controller struct {
 	strategy  Strategy
 	dataframe *model.Dataframe
 	broker    service.Broker
 	started   bool
+	duration  time.Duration
+
+	mtx        sync.Mutex
+	timeframes map[string]*timeframeFeed
 }
 
 func NewStrategyController(pair string, strategy Strategy, broker service.Broker) *Controller {
@@ -20,20 +35,99 @@ func NewStrategyController(pair string, strategy Strategy, broker service.Broker
 		Metadata: make(map[string]model.Series[float64]),
 	}
 
-	return &Controller{
-		dataframe: dataframe,
-		strategy:  strategy,
-		broker:    broker,
+	controller := &Controller{
+		dataframe:  dataframe,
+		strategy:   strategy,
+		broker:     broker,
+		timeframes: make(map[string]*timeframeFeed),
+	}
+
+	if str, ok := strategy.(MultiTimeframeStrategy); ok {
+		var err error
+		controller.duration, err = str2duration.ParseDuration(strategy.Timeframe())
+		if err != nil {
+			log.Errorf("invalid timeframe %s: %v", strategy.Timeframe(), err)
+		}
+
+		dataframe.Timeframes = make(map[string]*model.Dataframe)
+		for _, timeframe := range str.Timeframes() {
+			duration, err := str2duration.ParseDuration(timeframe)
+			if err != nil {
+				log.Errorf("invalid timeframe %s: %v", timeframe, err)
+				continue
+			}
+
+			dataframe.Timeframes[timeframe] = &model.Dataframe{
+				Pair:     pair,
+				Metadata: make(map[string]model.Series[float64]),
+			}
+			controller.timeframes[timeframe] = &timeframeFeed{
+				duration:  duration,
+				dataframe: dataframe.Timeframes[timeframe],
+			}
+		}
 	}
+
+	return controller
+}
+
+// Dataframe returns the dataframe of the main timeframe of the strategy
+func (s *Controller) Dataframe() *model.Dataframe {
+	return s.dataframe
 }
 
 func (s *Controller) Start() {
 	s.started = true
 }
 
+// OnTimeframeCandle receives candles of additional timeframes of a MultiTimeframeStrategy, closed candles
+// are added to the dataframe of the timeframe when a candle of the main timeframe closes after them.
+func (s *Controller) OnTimeframeCandle(timeframe string, candle model.Candle) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	feed, ok := s.timeframes[timeframe]
+	if !ok || !candle.Complete {
+		return
+	}
+	feed.pending = append(feed.pending, candle)
+}
+
+// releaseTimeframes updates dataframes of additional timeframes with candles closed until the given time
+func (s *Controller) releaseTimeframes(until time.Time) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	str, ok := s.strategy.(MultiTimeframeStrategy)
+	if !ok {
+		return
+	}
+
+	for timeframe, feed := range s.timeframes {
+		var released int
+		for _, candle := range feed.pending {
+			if candle.Time.Add(feed.duration).After(until) {
+				break
+			}
+
+			df := feed.dataframe
+			if len(df.Time) > 0 && !candle.Time.After(df.Time[len(df.Time)-1]) {
+				released++
+				continue
+			}
+
+			updateDataframe(df, candle)
+			str.TimeframeIndicators(timeframe, df)
+			released++
+		}
+		feed.pending = feed.pending[released:]
+	}
+}
+
 func (s *Controller) OnPartialCandle(candle model.Candle) {
 	if !candle.Complete && len(s.dataframe.Close) >= s.strategy.WarmupPeriod() {
 		if str, ok := s.strategy.(HighFrequencyStrategy); ok {
+			s.releaseTimeframes(candle.UpdatedAt)
 			s.updateDataFrame(candle)
 			str.Indicators(s.dataframe)
 			str.OnPartialCandle(s.dataframe, s.broker)
@@ -42,27 +136,31 @@ func (s *Controller) OnPartialCandle(candle model.Candle) {
 }
 
 func (s *Controller) updateDataFrame(candle model.Candle) {
-	if len(s.dataframe.Time) > 0 && candle.Time.Equal(s.dataframe.Time[len(s.dataframe.Time)-1]) {
-		last := len(s.dataframe.Time) - 1
-		s.dataframe.Close[last] = candle.Close
-		s.dataframe.Open[last] = candle.Open
-		s.dataframe.High[last] = candle.High
-		s.dataframe.Low[last] = candle.Low
-		s.dataframe.Volume[last] = candle.Volume
-		s.dataframe.Time[last] = candle.Time
+	updateDataframe(s.dataframe, candle)
+}
+
+func updateDataframe(dataframe *model.Dataframe, candle model.Candle) {
+	if len(dataframe.Time) > 0 && candle.Time.Equal(dataframe.Time[len(dataframe.Time)-1]) {
+		last := len(dataframe.Time) - 1
+		dataframe.Close[last] = candle.Close
+		dataframe.Open[last] = candle.Open
+		dataframe.High[last] = candle.High
+		dataframe.Low[last] = candle.Low
+		dataframe.Volume[last] = candle.Volume
+		dataframe.Time[last] = candle.Time
 		for k, v := range candle.Metadata {
-			s.dataframe.Metadata[k][last] = v
+			dataframe.Metadata[k][last] = v
 		}
 	} else {
-		s.dataframe.Close = append(s.dataframe.Close, candle.Close)
-		s.dataframe.Open = append(s.dataframe.Open, candle.Open)
-		s.dataframe.High = append(s.dataframe.High, candle.High)
-		s.dataframe.Low = append(s.dataframe.Low, candle.Low)
-		s.dataframe.Volume = append(s.dataframe.Volume, candle.Volume)
-		s.dataframe.Time = append(s.dataframe.Time, candle.Time)
-		s.dataframe.LastUpdate = candle.Time
+		dataframe.Close = append(dataframe.Close, candle.Close)
+		dataframe.Open = append(dataframe.Open, candle.Open)
+		dataframe.High = append(dataframe.High, candle.High)
+		dataframe.Low = append(dataframe.Low, candle.Low)
+		dataframe.Volume = append(dataframe.Volume, candle.Volume)
+		dataframe.Time = append(dataframe.Time, candle.Time)
+		dataframe.LastUpdate = candle.Time
 		for k, v := range candle.Metadata {
-			s.dataframe.Metadata[k] = append(s.dataframe.Metadata[k], v)
+			dataframe.Metadata[k] = append(dataframe.Metadata[k], v)
 		}
 	}
 }
@@ -73,6 +171,7 @@ func (s *Controller) OnCandle(candle model.Candle) {
 		return
 	}
 
+	s.releaseTimeframes(candle.Time.Add(s.duration))
 	s.updateDataFrame(candle)
 
 	if len(s.dataframe.Close) >= s.strategy.WarmupPeriod() {
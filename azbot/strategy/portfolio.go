@@ -0,0 +1,83 @@
+package strategy
+
+import (
+	"time"
+
+	"github.com/ezquant/azbot/azbot/model"
+	"github.com/ezquant/azbot/azbot/service"
+)
+
+// PortfolioController synchronizes the candles of all pairs to execute a PortfolioStrategy
+// once per time. Candles must be received in chronological order, a candle of a later time
+// closes the current time even if some pairs are missing, eg: stocks suspended from trading.
+type PortfolioController struct {
+	strategy    PortfolioStrategy
+	broker      service.Broker
+	pairs       []string
+	controllers map[string]*Controller
+	started     bool
+
+	current  time.Time
+	received map[string]bool
+}
+
+func NewPortfolioController(pairs []string, strategy PortfolioStrategy, broker service.Broker,
+	controllers map[string]*Controller) *PortfolioController {
+
+	return &PortfolioController{
+		strategy:    strategy,
+		broker:      broker,
+		pairs:       pairs,
+		controllers: controllers,
+		received:    make(map[string]bool),
+	}
+}
+
+func (p *PortfolioController) Start() {
+	p.started = true
+}
+
+// OnCandle receives closed candles after they are processed by the controller of the pair
+func (p *PortfolioController) OnCandle(candle model.Candle) {
+	if !candle.Complete {
+		return
+	}
+
+	p.Advance(candle.Time)
+
+	p.current = candle.Time
+	p.received[candle.Pair] = true
+	if len(p.received) == len(p.pairs) {
+		p.Flush()
+	}
+}
+
+// Advance executes the strategy for the current time when t is later, eg: a pair has no candle at the current
+// time. It must be called before a candle updates the wallet and the controllers of the pairs, otherwise
+// the strategy would see the candles of t. Only complete candles can advance the time, live feeds send
+// partial candles of the next time before the other pairs close the current time.
+func (p *PortfolioController) Advance(t time.Time) {
+	if len(p.received) > 0 && t.After(p.current) {
+		p.Flush()
+	}
+}
+
+// Flush executes the strategy with the candles received for the current time
+func (p *PortfolioController) Flush() {
+	if len(p.received) == 0 {
+		return
+	}
+	p.received = make(map[string]bool)
+
+	dataframes := make(map[string]*model.Dataframe)
+	for _, pair := range p.pairs {
+		controller, ok := p.controllers[pair]
+		if ok && len(controller.dataframe.Close) >= p.strategy.WarmupPeriod() {
+			dataframes[pair] = controller.dataframe
+		}
+	}
+
+	if p.started && len(dataframes) > 0 {
+		p.strategy.OnPortfolioCandle(dataframes, p.broker)
+	}
+}
@@ -0,0 +1,116 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/ezquant/azbot/azbot/plus/localkv"
+	"github.com/ezquant/azbot/azbot/plus/models"
+)
+
+var ErrStrategyNotFound = errors.New("strategy not found")
+
+// Factory creates a strategy from its configuration, kv stores the state of the strategy
+type Factory func(config *models.Config, kv *localkv.LocalKV) (Strategy, error)
+
+// RegisterOption configures a registered strategy
+type RegisterOption func(*registration)
+
+type registration struct {
+	factory    Factory
+	parameters interface{}
+}
+
+var registry = struct {
+	sync.RWMutex
+	strategies map[string]registration
+}{strategies: make(map[string]registration)}
+
+// WithParameters declares the parameter struct of the strategy, see models.BindParameters. It allows
+// the parameters to be listed and validated without creating the strategy, eg: `azbot config init`
+func WithParameters(parameters interface{}) RegisterOption {
+	return func(r *registration) {
+		r.parameters = parameters
+	}
+}
+
+// Register adds the factory of a strategy under a name, usually in the init function of the package of
+// the strategy. It panics when the name is empty or already registered.
+func Register(name string, factory Factory, options ...RegisterOption) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if name == "" || factory == nil {
+		panic("strategy: Register with empty name or nil factory")
+	}
+	if _, ok := registry.strategies[name]; ok {
+		panic(fmt.Sprintf("strategy: Register called twice for %s", name))
+	}
+
+	entry := registration{factory: factory}
+	for _, option := range options {
+		option(&entry)
+	}
+	registry.strategies[name] = entry
+}
+
+// New creates the strategy registered with the name in `config.Strategy`
+func New(config *models.Config, kv *localkv.LocalKV) (Strategy, error) {
+	entry, err := lookup(config.Strategy)
+	if err != nil {
+		return nil, err
+	}
+	return entry.factory(config, kv)
+}
+
+// Parameters returns the parameter schema of a registered strategy, or nil when the strategy was
+// registered without WithParameters
+func Parameters(name string) ([]models.Parameter, error) {
+	entry, err := lookup(name)
+	if err != nil || entry.parameters == nil {
+		return nil, err
+	}
+	return models.ParameterSchema(entry.parameters)
+}
+
+// ValidateParameters checks the parameters of the configuration against the parameter struct of the
+// strategy, without creating the strategy
+func ValidateParameters(config *models.Config) error {
+	entry, err := lookup(config.Strategy)
+	if err != nil || entry.parameters == nil {
+		return err
+	}
+
+	target := reflect.New(reflect.TypeOf(entry.parameters))
+	if target.Elem().Kind() == reflect.Ptr {
+		target = reflect.New(target.Elem().Type().Elem())
+	}
+	return config.Bind(target.Interface())
+}
+
+func lookup(name string) (registration, error) {
+	registry.RLock()
+	entry, ok := registry.strategies[name]
+	registry.RUnlock()
+
+	if !ok {
+		return entry, fmt.Errorf("%w: %s (available: %v)", ErrStrategyNotFound, name, Names())
+	}
+	return entry, nil
+}
+
+// Names returns the names of the registered strategies in alphabetical order
+func Names() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	names := make([]string, 0, len(registry.strategies))
+	for name := range registry.strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,88 @@
+package strategy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ezquant/azbot/azbot/model"
+	"github.com/ezquant/azbot/azbot/plus/localkv"
+	"github.com/ezquant/azbot/azbot/plus/models"
+	"github.com/ezquant/azbot/azbot/service"
+)
+
+type registeredStrategy struct {
+	timeframe string
+}
+
+func (s registeredStrategy) Timeframe() string                            { return s.timeframe }
+func (s registeredStrategy) WarmupPeriod() int                            { return 1 }
+func (s registeredStrategy) Indicators(*model.Dataframe) []ChartIndicator { return nil }
+func (s registeredStrategy) OnCandle(*model.Dataframe, service.Broker)    {}
+
+func TestRegistry(t *testing.T) {
+	Register("TestRegistryB", func(config *models.Config, _ *localkv.LocalKV) (Strategy, error) {
+		return registeredStrategy{timeframe: config.BacktestConfig.Timeframe}, nil
+	})
+	Register("TestRegistryA", func(*models.Config, *localkv.LocalKV) (Strategy, error) {
+		return nil, errors.New("invalid parameters")
+	})
+
+	require.Subset(t, Names(), []string{"TestRegistryA", "TestRegistryB"})
+	require.Less(t, indexOf(Names(), "TestRegistryA"), indexOf(Names(), "TestRegistryB"))
+
+	config := &models.Config{Strategy: "TestRegistryB"}
+	config.BacktestConfig.Timeframe = "1h"
+	str, err := New(config, nil)
+	require.NoError(t, err)
+	require.Equal(t, "1h", str.Timeframe())
+
+	_, err = New(&models.Config{Strategy: "TestRegistryA"}, nil)
+	require.EqualError(t, err, "invalid parameters")
+
+	_, err = New(&models.Config{Strategy: "Unknown"}, nil)
+	require.ErrorIs(t, err, ErrStrategyNotFound)
+
+	require.Panics(t, func() {
+		Register("TestRegistryB", func(*models.Config, *localkv.LocalKV) (Strategy, error) { return nil, nil })
+	})
+	require.Panics(t, func() { Register("", nil) })
+}
+
+func TestRegistry_Parameters(t *testing.T) {
+	type parameters struct {
+		Period int `param:"period" default:"8" min:"2" max:"50"`
+	}
+	Register("TestParametersA", func(*models.Config, *localkv.LocalKV) (Strategy, error) { return nil, nil },
+		WithParameters(&parameters{}))
+	Register("TestParametersB", func(*models.Config, *localkv.LocalKV) (Strategy, error) { return nil, nil })
+
+	schema, err := Parameters("TestParametersA")
+	require.NoError(t, err)
+	require.Equal(t, []models.Parameter{{Name: "period", Type: models.ParameterInt, Default: 8, Min: 2, Max: 50}},
+		schema)
+
+	schema, err = Parameters("TestParametersB")
+	require.NoError(t, err)
+	require.Nil(t, schema)
+
+	_, err = Parameters("Unknown")
+	require.ErrorIs(t, err, ErrStrategyNotFound)
+
+	config := &models.Config{Strategy: "TestParametersA", Parameters: []models.Parameter{{Name: "period", Default: 20}}}
+	require.NoError(t, ValidateParameters(config))
+	config.Parameters[0].Default = 100
+	require.ErrorIs(t, ValidateParameters(config), models.ErrInvalidParameter)
+	config.Strategy = "TestParametersB"
+	require.NoError(t, ValidateParameters(config))
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
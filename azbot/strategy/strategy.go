@@ -24,3 +24,30 @@ type HighFrequencyStrategy interface {
 	// OnPartialCandle will be executed for each new partial candle, after indicators are filled.
 	OnPartialCandle(df *model.Dataframe, broker service.Broker)
 }
+
+type MultiTimeframeStrategy interface {
+	Strategy
+
+	// Timeframes are additional time intervals used by the strategy, eg: 1d trend filter of a 1h strategy.
+	// Dataframes of additional timeframes are available in `df.Timeframes` of the main timeframe.
+	Timeframes() []string
+	// TimeframeIndicators will be executed for each new candle of an additional timeframe, before `OnCandle`.
+	// A candle is only available after its close, when the current candle of the main timeframe closes later.
+	TimeframeIndicators(timeframe string, df *model.Dataframe)
+}
+
+type PortfolioStrategy interface {
+	Strategy
+
+	// OnPortfolioCandle will be executed once the candles of all pairs for the same time are closed, after
+	// `OnCandle` of each pair. Dataframes are indexed by pair and contain only pairs after the warmup period.
+	OnPortfolioCandle(dataframes map[string]*model.Dataframe, broker service.Broker)
+}
+
+type ParameterizedStrategy interface {
+	Strategy
+
+	// Parameters returns a pointer to the struct of parameters of the strategy, with `param` tags bound from
+	// the configuration by `models.Config.Bind`. Its schema is used to generate the search space of the optimizer.
+	Parameters() interface{}
+}
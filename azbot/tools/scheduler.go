@@ -2,6 +2,7 @@ package tools
 
 import (
 	"github.com/ezquant/azbot/azbot"
+	"github.com/ezquant/azbot/azbot/calendar"
 	"github.com/ezquant/azbot/azbot/service"
 	"github.com/samber/lo"
 	log "github.com/sirupsen/logrus"
@@ -16,10 +17,24 @@ type OrderCondition struct {
 type Scheduler struct {
 	pair            string
 	orderConditions []OrderCondition
+	calendar        *calendar.Calendar
 }
 
-func NewScheduler(pair string) *Scheduler {
-	return &Scheduler{pair: pair}
+type SchedulerOption func(*Scheduler)
+
+// WithSchedulerCalendar keeps orders scheduled while the exchange is closed, eg: weekends and holidays
+func WithSchedulerCalendar(cal *calendar.Calendar) SchedulerOption {
+	return func(s *Scheduler) {
+		s.calendar = cal
+	}
+}
+
+func NewScheduler(pair string, options ...SchedulerOption) *Scheduler {
+	scheduler := &Scheduler{pair: pair}
+	for _, option := range options {
+		option(scheduler)
+	}
+	return scheduler
 }
 
 func (s *Scheduler) SellWhen(size float64, condition func(df *azbot.Dataframe) bool) {
@@ -37,6 +52,10 @@ func (s *Scheduler) BuyWhen(size float64, condition func(df *azbot.Dataframe) bo
 }
 
 func (s *Scheduler) Update(df *azbot.Dataframe, broker service.Broker) {
+	if s.calendar != nil && !s.calendar.IsTradingDay(df.LastUpdate) {
+		return
+	}
+
 	s.orderConditions = lo.Filter[OrderCondition](s.orderConditions, func(oc OrderCondition, _ int) bool {
 		if oc.Condition(df) {
 			_, err := broker.CreateOrderMarket(oc.Side, s.pair, oc.Size)
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"os"
 
 	"github.com/ezquant/azbot/azbot"
 	"github.com/ezquant/azbot/azbot/exchange"
@@ -12,52 +13,63 @@ import (
 	"github.com/ezquant/azbot/examples/strategies"
 )
 
-// This example shows how to use backtesting with AzBot
-// Backtesting is a simulation of the strategy in historical data (from CSV)
+// This example shows how to use backtesting with A-share data of a local TongDaXin (通达信) installation
+// TDX_VIPDOC is required with the vipdoc directory, eg: C:\new_tdx\vipdoc, and optionally TDX_GBBQ with
+// ex-rights/ex-dividend data exported to CSV to credit dividends and bonus shares
 func main() {
 	ctx := context.Background()
 
+	vipdoc := os.Getenv("TDX_VIPDOC")
+	if vipdoc == "" {
+		log.Fatal("TDX_VIPDOC is not set, eg: TDX_VIPDOC=C:/new_tdx/vipdoc")
+	}
+
 	// bot settings (eg: pairs, telegram, etc)
 	settings := azbot.Settings{
 		Pairs: []string{
-			"BTCUSDT",
-			"ETHUSDT",
+			exchange.TDXPair("sh600104"),
+			exchange.TDXPair("sz000001"),
 		},
 	}
 
 	// initialize your strategy
 	strategy := new(strategies.CrossEMA)
 
-	// load historical data from CSV files
-	csvFeed, err := exchange.NewCSVFeed(
-		strategy.Timeframe(),
-		exchange.PairFeed{
-			Pair:      "BTCUSDT",
-			File:      "testdata/btc-1h.csv",
-			Timeframe: "1h",
-		},
-		exchange.PairFeed{
-			Pair:      "ETHUSDT",
-			File:      "testdata/eth-1h.csv",
-			Timeframe: "1h",
-		},
-	)
+	// load historical data from TDX files, minute data is resampled to the strategy timeframe
+	tdxFeed, err := exchange.NewTDXFeed(vipdoc, strategy.Timeframe(), "sh600104", "sz000001")
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	// prices are raw, dividends and bonus shares are credited to the wallet on ex-dates
+	var actions []exchange.CorporateAction
+	if gbbq := os.Getenv("TDX_GBBQ"); gbbq != "" {
+		actions, err = exchange.LoadCorporateActions(gbbq)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	// initialize a database in memory
 	storage, err := storage.FromMemory()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// create a paper wallet for simulation, initializing with 10.000 USDT
+	// create a paper wallet for simulation, initializing with 100.000 CNY
 	wallet := exchange.NewPaperWallet(
 		ctx,
-		"USDT",
-		exchange.WithPaperAsset("USDT", 10000),
-		exchange.WithDataFeed(csvFeed),
+		exchange.TDXQuote,
+		exchange.WithPaperAsset(exchange.TDXQuote, 100000),
+		exchange.WithDataFeed(tdxFeed),
+		exchange.WithPaperMarketRules(exchange.NewAShareRules()),
+		exchange.WithPaperCorporateActions(actions),
+		exchange.WithPaperFeeModel(exchange.AShareFee{
+			Commission:    0.00025,
+			MinCommission: 5,
+			StampDuty:     0.0005,
+			TransferFee:   0.00001,
+		}),
 	)
 
 	// create a chart  with indicators from the strategy and a custom additional RSI indicator
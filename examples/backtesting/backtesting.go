@@ -1,22 +1,17 @@
 package backtesting
 
 import (
-	"bufio"
 	"context"
 	"fmt"
-	"io"
-	"math"
-	"os"
-	"regexp"
-	"strconv"
-	"strings"
 
 	"github.com/ezquant/azbot/azbot"
 	"github.com/ezquant/azbot/azbot/exchange"
+	"github.com/ezquant/azbot/azbot/montecarlo"
 	"github.com/ezquant/azbot/azbot/plot"
 	"github.com/ezquant/azbot/azbot/plus/localkv"
 	"github.com/ezquant/azbot/azbot/plus/models"
 	"github.com/ezquant/azbot/azbot/storage"
+	"github.com/ezquant/azbot/azbot/strategy"
 	"github.com/ezquant/azbot/examples/strategies"
 
 	log "github.com/sirupsen/logrus"
@@ -24,20 +19,6 @@ import (
 
 const initialBalance = 10000.0 // 设置初始资金总额
 
-// 用于在goroutine间传递结果的结构体
-type summaryResult struct {
-	sharpeRatio float64
-	err         error
-}
-
-// OutputResult 保存解析后的回测数据
-type OutputResult struct {
-	StartPortfolio float64
-	FinalPortfolio float64
-	MaxDrawdown    float64
-	RawOutput      string
-}
-
 // Run executes the backtesting process with the given configuration and database path.
 // Parameters:
 // - config: The configuration for the backtesting process.
@@ -63,16 +44,8 @@ func Run(config *models.Config, databasePath *string) (float64, *plot.Chart) {
 		log.Fatal(err)
 	}
 
-	var strategy strategies.Strategy
-	switch config.Strategy {
-	case "CrossEMA":
-		strategy, err = strategies.NewCrossEMA(config, kv)
-	case "RLPPO":
-		strategy, err = strategies.NewRLPPO(config, kv)
-	default:
-		log.Fatalf("Unknown strategy: %s", config.Strategy)
-	}
-
+	// 策略在各自的包中注册，eg: examples/strategies
+	str, err := strategy.New(config, kv)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -82,12 +55,12 @@ func Run(config *models.Config, databasePath *string) (float64, *plot.Chart) {
 	for pair := range config.AssetWeights {
 		pairFeed = append(pairFeed, exchange.PairFeed{
 			Pair:      pair,
-			File:      fmt.Sprintf("testdata/%s-%s.csv", pair, strategy.Timeframe()),
-			Timeframe: strategy.Timeframe(),
+			File:      fmt.Sprintf("testdata/%s-%s.csv", pair, str.Timeframe()),
+			Timeframe: str.Timeframe(),
 		})
 	}
 
-	csvFeed, err := exchange.NewCSVFeed(strategy.Timeframe(), pairFeed...)
+	csvFeed, err := exchange.NewCSVFeed(str.Timeframe(), pairFeed...)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -102,6 +75,8 @@ func Run(config *models.Config, databasePath *string) (float64, *plot.Chart) {
 		"USDT",
 		exchange.WithPaperAsset("USDT", config.BacktestConfig.InitialBalance),
 		exchange.WithDataFeed(csvFeed),
+		exchange.WithPaperFee(config.BacktestConfig.Fee, config.BacktestConfig.Fee),
+		exchange.WithPaperSlippage(exchange.FixedSlippage{BPS: config.BacktestConfig.Slippage * 10000}),
 	)
 
 	chart, err := plot.NewChart(plot.WithPaperWallet(wallet))
@@ -112,12 +87,13 @@ func Run(config *models.Config, databasePath *string) (float64, *plot.Chart) {
 		ctx,
 		settings,
 		wallet,
-		strategy,
+		str,
 		azbot.WithBacktest(wallet),
 		azbot.WithStorage(storage),
 		azbot.WithCandleSubscription(chart),
 		azbot.WithOrderSubscription(chart),
 		azbot.WithLogLevel(log.WarnLevel),
+		azbot.WithProgressBar(false), // 关闭回测进度条
 	)
 	if err != nil {
 		log.Fatal(err)
@@ -125,52 +101,42 @@ func Run(config *models.Config, databasePath *string) (float64, *plot.Chart) {
 
 	kv.RemoveDB()
 
-	// 重定向错误输出到空设备（关闭回测进度条）
-	if err := runWithProgress(false, func() error {
-		return bot.Run(ctx)
-	}); err != nil {
+	// 运行回测
+	if err := bot.Run(ctx); err != nil {
 		return 0, chart
 	}
 
-	outputResult, err := captureOutput(func() {
-		bot.Summary()
-	})
-	if err != nil {
-		return 0, chart
-	}
+	// 打印回测结果
+	bot.Summary()
 
-	// 计算夏普率
-	riskFreeRate := 0.02
-	returns := (outputResult.FinalPortfolio - outputResult.StartPortfolio) / outputResult.StartPortfolio
-	volatility := math.Abs(outputResult.MaxDrawdown / 100.0)
-	if volatility == 0 {
-		volatility = 0.0001 // 避免除以零
-	}
-	sharpeRatio := (returns - riskFreeRate) / volatility
+	// 根据权益曲线计算年化夏普率
+	report := bot.Report()
+	sharpeRatio := report.Metrics.Sharpe
 
-	// 打印原始输出
-	fmt.Print(outputResult.RawOutput)
+	if config.MonteCarlo.Simulations > 0 {
+		runMonteCarlo(config.MonteCarlo, report)
+	}
 
 	var printDetails bool = false
-	if printDetails {
+	if data, ok := str.(strategies.Strategy); printDetails && ok {
 		totalEquity := 0.0
 		fmt.Printf("REAL ASSETS VALUE\n")
 
-		for pair := range strategy.GetD().AssetWeights {
+		for pair := range data.GetD().AssetWeights {
 			asset, _, err := wallet.Position(pair)
 			if err != nil {
 				log.Fatal(err)
 			}
 
-			assetValue := asset * strategy.GetD().LastClose[pair]
-			volume := strategy.GetD().Volume[pair]
+			assetValue := asset * data.GetD().LastClose[pair]
+			volume := data.GetD().Volume[pair]
 			profitPerc := (assetValue - volume) / volume * 100
 			fmt.Printf("%s = %.2f USDT, Asset Qty = %f, Profit = %.2f%%\n", pair, assetValue, asset, profitPerc)
 			totalEquity += assetValue
 		}
 
 		totalVolume := 0.0
-		for _, volume := range strategy.GetD().Volume {
+		for _, volume := range data.GetD().Volume {
 			totalVolume += volume
 		}
 
@@ -182,102 +148,40 @@ func Run(config *models.Config, databasePath *string) (float64, *plot.Chart) {
 	return sharpeRatio, chart
 }
 
-// runWithProgress 临时重定向标准错误输出
-// 接受一个函数作为参数，在重定向后执行该函数，然后恢复原有输出
-func runWithProgress(withProgress bool, fn func() error) error {
-	if withProgress {
-		return fn()
+// runMonteCarlo 模拟回测交易的不同路径，输出最终权益和最大回撤的分布
+func runMonteCarlo(settings models.MonteCarlo, report azbot.BacktestReport) {
+	trades := make([]montecarlo.Trade, 0, len(report.ClosedTrades))
+	for _, trade := range report.ClosedTrades {
+		trades = append(trades, montecarlo.Trade{Profit: trade.Profit, Notional: trade.Notional})
 	}
 
-	// 保存原始的错误输出
-	oldStderr := os.Stderr
-
-	// 重定向到空设备
-	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
-	if err != nil {
-		return err
+	options := []montecarlo.Option{
+		montecarlo.WithSimulations(settings.Simulations),
+		montecarlo.WithSkipProbability(settings.Skip),
+		montecarlo.WithSeed(settings.Seed),
 	}
-	os.Stderr = devNull
-
-	// 执行函数
-	err = fn()
-
-	// 恢复错误输出
-	os.Stderr = oldStderr
-
-	return err
-}
-
-// captureOutput 捕获并解析函数执行过程中的标准输出
-// fn 是需要捕获输出的函数
-// 返回解析后的输出结果和可能的错误
-func captureOutput(fn func()) (*OutputResult, error) {
-	// 创建管道用于捕获输出
-	r, w, err := os.Pipe()
-	if err != nil {
-		return nil, err
+	if settings.Method != "" {
+		options = append(options, montecarlo.WithMethod(montecarlo.Method(settings.Method)))
 	}
-
-	// 保存原始的标准输出
-	oldStdout := os.Stdout
-	os.Stdout = w
-
-	// 创建一个通道用于同步
-	done := make(chan string)
-
-	// 启动 goroutine 读取输出
-	go func() {
-		var output strings.Builder
-		reader := bufio.NewReader(r)
-		for {
-			line, err := reader.ReadString('\n')
-			if err != nil {
-				if err != io.EOF {
-					log.Printf("读取输出时发生错误: %v", err)
-				}
-				break
-			}
-			output.WriteString(line)
-		}
-		done <- output.String()
-	}()
-
-	// 执行需要捕获输出的函数
-	fn()
-
-	// 恢复标准输出并关闭写入端
-	os.Stdout = oldStdout
-	w.Close()
-
-	// 等待读取完成并获取输出
-	output := <-done
-	r.Close()
-
-	// 解析输出
-	result := &OutputResult{RawOutput: output}
-
-	// 使用正则表达式解析结果
-	startPortfolioRe := regexp.MustCompile(`START PORTFOLIO\s*=\s*([\d.]+)`)
-	finalPortfolioRe := regexp.MustCompile(`FINAL PORTFOLIO\s*=\s*([\d.]+)`)
-	maxDrawdownRe := regexp.MustCompile(`MAX DRAWDOWN = (-[\d.]+)`)
-
-	matches := startPortfolioRe.FindStringSubmatch(output)
-	if matches == nil {
-		return nil, fmt.Errorf("无法解析起始资金数据")
+	if settings.Ruin > 0 {
+		options = append(options, montecarlo.WithRuin(settings.Ruin))
 	}
-	result.StartPortfolio, _ = strconv.ParseFloat(matches[1], 64)
-
-	matches = finalPortfolioRe.FindStringSubmatch(output)
-	if matches == nil {
-		return nil, fmt.Errorf("无法解析最终资金数据")
+	// 每笔交易的开仓和平仓成交使用该交易的平仓金额
+	if settings.FillNoise > 0 {
+		options = append(options, montecarlo.WithFillNoise(settings.FillNoise))
 	}
-	result.FinalPortfolio, _ = strconv.ParseFloat(matches[1], 64)
 
-	matches = maxDrawdownRe.FindStringSubmatch(output)
-	if matches == nil {
-		return nil, fmt.Errorf("无法解析最大回撤数据")
+	// 回测路径的最大回撤使用权益曲线计算，包含持仓期间的浮亏
+	equity := make([]float64, len(report.Equity))
+	for i, point := range report.Equity {
+		equity[i] = point.Value
 	}
-	result.MaxDrawdown, _ = strconv.ParseFloat(matches[1], 64)
+	options = append(options, montecarlo.WithEquity(equity))
 
-	return result, nil
+	result, err := montecarlo.Simulate(trades, report.InitialValue, options...)
+	if err != nil {
+		log.Warnf("蒙特卡洛模拟失败: %v", err)
+		return
+	}
+	fmt.Print(result)
 }
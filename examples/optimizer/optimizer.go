@@ -1,68 +1,144 @@
 package optimizer
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"io"
 	"math"
-	"os"
-	"regexp"
 	"sort"
-	"strconv"
 	"sync"
-	"sync/atomic"
+	"time"
 
 	"github.com/ezquant/azbot/azbot"
 	"github.com/ezquant/azbot/azbot/exchange"
+	"github.com/ezquant/azbot/azbot/metrics"
+	"github.com/ezquant/azbot/azbot/optimize"
 	"github.com/ezquant/azbot/azbot/plus/localkv"
 	"github.com/ezquant/azbot/azbot/plus/models"
 	"github.com/ezquant/azbot/azbot/storage"
-	"github.com/ezquant/azbot/examples/strategies"
+	"github.com/ezquant/azbot/azbot/strategy"
+	"github.com/glebarez/sqlite"
+	"github.com/xhit/go-str2duration/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 
 	//log "github.com/sirupsen/logrus" // 使用 logrus
 	log "github.com/ezquant/azbot/azbot/tools/log"
 )
 
 type Optimizer struct {
-	config        *models.Config
-	results       []OptimizationResult
-	parameterSets []map[string]interface{}
-	mu            sync.Mutex
-	workerCount   int
+	config      *models.Config
+	results     []OptimizationResult
+	space       optimize.Space
+	mu          sync.Mutex
+	workerCount int
+
+	// feed 为所有回测共享的数据，每次回测使用其时间切片
+	feed      *exchange.CSVFeed
+	timeframe string
+	warmup    int
+
+	// study 保存每次试验的结果，resume 为 true 时跳过已完成的试验，reset 为 true 时清空已有的试验
+	study  *optimize.Study
+	resume bool
+	reset  bool
+
+	// schema 为策略的参数定义，用于补全配置中缺少的搜索范围
+	schema []models.Parameter
+
+	// trialsOutput 和 report 为试验表 (CSV 或 JSON) 和 HTML 报告的保存路径，为空时不保存
+	trialsOutput string
+	report       string
+}
+
+type Option func(*Optimizer)
+
+// WithStudy 将试验结果保存到数据库
+func WithStudy(study *optimize.Study) Option {
+	return func(o *Optimizer) {
+		o.study = study
+	}
+}
+
+// WithWorkers 设置并发回测的数量，默认 4
+func WithWorkers(workers int) Option {
+	return func(o *Optimizer) {
+		if workers > 0 {
+			o.workerCount = workers
+		}
+	}
+}
+
+// WithTrialsOutput 将所有试验的参数和指标保存到文件，扩展名为 .json 时保存为 JSON，否则为 CSV
+func WithTrialsOutput(path string) Option {
+	return func(o *Optimizer) {
+		o.trialsOutput = path
+	}
+}
+
+// WithReport 将参数热力图和权益曲线保存为 HTML 报告
+func WithReport(path string) Option {
+	return func(o *Optimizer) {
+		o.report = path
+	}
+}
+
+// WithResume 从数据库中恢复已完成的试验，需要同时使用 WithStudy
+func WithResume(resume bool) Option {
+	return func(o *Optimizer) {
+		o.resume = resume
+	}
+}
+
+// WithReset 清空数据库中已有的试验后重新优化，没有 resume 或 reset 时已有试验的 study 不会被覆盖
+func WithReset(reset bool) Option {
+	return func(o *Optimizer) {
+		o.reset = reset
+	}
 }
 
 type OptimizationResult struct {
 	Parameters map[string]interface{}
+	// Score 为优化目标的值，见 models.Objectives
+	Score      float64
 	Sharpe     float64
+	Sortino    float64
+	Calmar     float64
 	Returns    float64
 	Drawdown   float64
 	Profit     float64
+	TradeCount int
+	// Equity 为回测的权益曲线，Trades 为每笔交易的盈亏，搜索的试验评分后不保留这两项
+	Equity []exchange.AssetValue
+	Trades []float64
+	// ReturnSeries 为权益曲线按时间去重后的收益序列，用于过拟合诊断，从数据库恢复的试验没有此项
+	ReturnSeries []float64
 }
 
-type outputCapturer struct {
-	mu        sync.Mutex
-	buffer    bytes.Buffer
-	readError error
-}
-
-var (
-	successCount atomic.Int32
-	failCount    atomic.Int32
-	oldStderr    *os.File
-)
+// Run 是主入口函数，符合 main.go 中的调用方式。dbPath 不为空时试验结果保存到该 SQLite 数据库
+func Run(config *models.Config, dbPath *string, options ...Option) {
+	log.Infof("开始优化策略 [%s] 的参数...", config.Strategy)
 
-// 使用全局的 capturer 实例
-var globalCapturer = &outputCapturer{}
+	if dbPath != nil && *dbPath != "" {
+		study, err := optimize.OpenStudy(sqlite.Open(*dbPath), config.Strategy,
+			&gorm.Config{Logger: logger.Discard})
+		if err != nil {
+			log.Fatal("打开试验数据库失败: ", err)
+		}
+		options = append([]Option{WithStudy(study)}, options...)
+	}
 
-// Run 是主入口函数，符合 main.go 中的调用方式
-func Run(config *models.Config, dbPath *string) {
-	log.Infof("开始优化策略 [%s] 的参数...", config.Strategy)
+	optimizer := NewOptimizer(config, options...)
+	if config.WalkForward.Train != "" {
+		if optimizer.trialsOutput != "" || optimizer.report != "" {
+			log.Warn("滚动优化不输出试验表和 HTML 报告")
+		}
+		runWalkForward(optimizer, config)
+		return
+	}
 
-	optimizer := NewOptimizer(config)
 	bestResult, err := optimizer.Optimize()
 	if err != nil {
-		log.Fatal("优化失败: %v", err)
+		log.Fatal("优化失败: ", err)
 	}
 
 	// 输出最优参数
@@ -72,257 +148,455 @@ func Run(config *models.Config, dbPath *string) {
 		log.Infof("%s: %v", name, value)
 	}
 	log.Info("----------------------------------------")
+	log.Infof("优化目标 %s: %.4f", optimizer.objective(), bestResult.Score)
 	log.Infof("夏普率: %.2f", bestResult.Sharpe)
 	log.Infof("收益率: %.2f%%", bestResult.Returns*100)
 	log.Infof("最大回撤: %.2f%%", bestResult.Drawdown*100)
+	optimizer.printOverfitting()
 	log.Info("----------------------------------------")
 
 	// 保存最优参数到配置文件
 	if err := saveOptimizedConfig(config, bestResult.Parameters); err != nil {
 		log.Errorf("保存优化后的配置失败: %v", err)
 	}
+
+	if err := optimizer.saveOutputs(); err != nil {
+		log.Errorf("保存优化结果失败: %v", err)
+	}
 }
 
-func NewOptimizer(config *models.Config) *Optimizer {
-	return &Optimizer{
-		config:        config,
-		results:       make([]OptimizationResult, 0),
-		parameterSets: make([]map[string]interface{}, 0),
-		workerCount:   4, // 可配置的并发数
+func NewOptimizer(config *models.Config, options ...Option) *Optimizer {
+	optimizer := &Optimizer{
+		config:      config,
+		results:     make([]OptimizationResult, 0),
+		workerCount: 4, // 可配置的并发数
 	}
+
+	for _, option := range options {
+		option(optimizer)
+	}
+
+	return optimizer
 }
 
-// generateParameterSets 生成所有可能的参数组合
-func (o *Optimizer) generateParameterSets() {
-	log.Info("开始生成参数组合...")
+// searchSpace 将配置中的参数范围转换为搜索空间
+func (o *Optimizer) searchSpace() optimize.Space {
+	if o.space != nil {
+		return o.space
+	}
+
+	log.Info("开始生成搜索空间...")
 	// 打印每个参数的范围和步长
-	for name, param := range o.config.Parameters {
+	for _, param := range o.parameters() {
 		log.Infof("参数 %s: 最小值=%v, 最大值=%v, 步长=%v",
-			name, param.Min, param.Max, param.Step)
+			param.Name, param.Min, param.Max, param.Step)
+
+		dimension := optimize.Dimension{
+			Name:   param.Name,
+			Type:   optimize.Type(param.Type),
+			Min:    toFloat(param.Min),
+			Max:    toFloat(param.Max),
+			Step:   toFloat(param.Step),
+			Values: param.Values,
+		}
+
+		// 没有范围的数值参数固定为默认值，没有默认值时不参与搜索，回测使用策略的默认值
+		numeric := param.Type == models.ParameterInt || param.Type == models.ParameterFloat ||
+			param.Type == models.ParameterDuration
+		if numeric && (param.Min == nil || param.Max == nil) {
+			if param.Default == nil {
+				log.Infof("参数 %s 没有范围和默认值，不参与搜索", param.Name)
+				continue
+			}
+			dimension.Min = toFloat(param.Default)
+			dimension.Max = dimension.Min
+		}
+		// 时长参数以秒为单位搜索
+		if param.Type == models.ParameterDuration {
+			dimension.Type = optimize.TypeInt
+		}
+		o.space = append(o.space, dimension)
 	}
 
-	params := make(map[string][]interface{})
+	return o.space
+}
 
-	// 为每个参数生成可能的值
+// parameters 返回搜索的参数。配置中参数缺少的类型和范围由策略的参数定义补全，
+// 配置中没有但定义了范围的参数也加入搜索
+func (o *Optimizer) parameters() []models.Parameter {
+	schema := make(map[string]models.Parameter, len(o.schema))
+	for _, definition := range o.schema {
+		schema[definition.Name] = definition
+	}
+
+	parameters := make([]models.Parameter, 0, len(o.config.Parameters))
 	for _, param := range o.config.Parameters {
-		values := make([]interface{}, 0)
-
-		switch param.Type {
-		case "bool":
-			values = append(values, false, true)
-
-		case "int":
-			min := param.Min.(int)
-			max := param.Max.(int)
-			step := param.Step.(int)
-			for i := min; i <= max; i += step {
-				values = append(values, i)
+		if definition, ok := schema[param.Name]; ok {
+			delete(schema, param.Name)
+			if param.Type == "" {
+				param.Type = definition.Type
 			}
-
-		case "float":
-			min := param.Min.(float64)
-			max := param.Max.(float64)
-			step := param.Step.(float64)
-			for v := min; v <= max+step/2; v += step { // 添加step/2以处理浮点数精度问题
-				values = append(values, math.Round(v*100)/100)
+			if param.Min == nil {
+				param.Min = definition.Min
+			}
+			if param.Max == nil {
+				param.Max = definition.Max
+			}
+			if param.Step == nil {
+				param.Step = definition.Step
+			}
+			if len(param.Values) == 0 {
+				param.Values = definition.Values
+			}
+			if param.Default == nil {
+				param.Default = definition.Default
 			}
 		}
-
-		params[param.Name] = values
-		log.Infof("参数 %s 的可能值: %v", param.Name, values) // 添加这行来调试
+		parameters = append(parameters, param)
 	}
 
-	// 生成笛卡尔积
-	o.generateCartesianProduct(params, make(map[string]interface{}), o.config.Parameters)
+	for _, definition := range o.schema {
+		_, missing := schema[definition.Name]
+		if missing && (definition.Min != nil && definition.Max != nil || len(definition.Values) > 0) {
+			parameters = append(parameters, definition)
+		}
+	}
+	return parameters
 }
 
-// generateCartesianProduct 递归生成参数的笛卡尔积
-func (o *Optimizer) generateCartesianProduct(params map[string][]interface{}, current map[string]interface{}, paramList []models.Parameter) {
-	if len(current) == len(params) {
-		paramSet := make(map[string]interface{})
-		for k, v := range current {
-			paramSet[k] = v
+// newSampler 根据配置创建参数搜索方法，默认为网格搜索
+func (o *Optimizer) newSampler() (optimize.Sampler, int, error) {
+	settings := o.config.Optimizer
+	space := o.searchSpace()
+
+	sampler, err := optimize.New(settings.Method, space, settings.Trials, settings.Seed)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// 网格搜索的次数为参数组合数
+	total := settings.Trials
+	if settings.Method == "" || settings.Method == "grid" {
+		if total <= 0 || space.Size() < total {
+			total = space.Size()
 		}
-		o.parameterSets = append(o.parameterSets, paramSet)
-		return
+	} else if total <= 0 {
+		total = optimize.DefaultTrials
 	}
 
-	param := paramList[len(current)]
-	for _, val := range params[param.Name] {
-		current[param.Name] = val
-		o.generateCartesianProduct(params, current, paramList)
-		delete(current, param.Name)
+	return sampler, total, nil
+}
+
+// toFloat 将 YAML 中的数值转换为 float64，时长转换为秒数
+func toFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	case string:
+		if duration, err := str2duration.ParseDuration(v); err == nil {
+			return duration.Seconds()
+		}
 	}
+	return 0
 }
 
 // Optimize 执行参数优化
 func (o *Optimizer) Optimize() (OptimizationResult, error) {
-	log.Info("生成参数组合...")
-	o.generateParameterSets()
-	totalCombinations := len(o.parameterSets)
-	log.Infof("共生成 %d 种参数组合", totalCombinations)
-
-	var wg sync.WaitGroup
-	semaphore := make(chan struct{}, o.workerCount)
-	progress := 0
-	var progressMu sync.Mutex
-
-	for i, params := range o.parameterSets {
-		wg.Add(1)
-		semaphore <- struct{}{} // 获取信号量
-
-		go func(parameters map[string]interface{}, index int) {
-			defer wg.Done()
-			defer func() {
-				<-semaphore // 释放信号量
-				progressMu.Lock()
-				progress++
-				if progress%max(1, totalCombinations/20) == 0 { // 每完成5%输出一次进度
-					log.Infof("优化进度: %.1f%% (%d/%d)",
-						float64(progress)/float64(totalCombinations)*100,
-						progress,
-						totalCombinations)
-				}
-				progressMu.Unlock()
-			}()
-
-			// 创建配置副本并更新参数
-			configCopy := *o.config
-			for i := range configCopy.Parameters {
-				if val, exists := parameters[configCopy.Parameters[i].Name]; exists {
-					configCopy.Parameters[i].Default = val
-				}
-			}
+	results, err := o.search(o.study, time.Time{}, time.Time{})
+	if err != nil {
+		return OptimizationResult{}, err
+	}
+
+	// 输出前N个最优结果
+	o.printTopResults(5)
 
-			// 运行回测
-			result, err := o.runBacktest(&configCopy)
+	return results[0], nil
+}
+
+// objective 返回优化目标，默认为夏普率
+func (o *Optimizer) objective() string {
+	if o.config.Optimizer.Objective == "" {
+		return models.ObjectiveSharpe
+	}
+	return o.config.Optimizer.Objective
+}
+
+// search 在时间区间 [start, end) 内回测所有参数组合，返回按优化目标排序的结果。
+// study 不为空时保存每次试验，并在 resume 时恢复已完成的试验
+func (o *Optimizer) search(study *optimize.Study, start, end time.Time) ([]OptimizationResult, error) {
+	if err := o.loadFeed(); err != nil {
+		return nil, err
+	}
+
+	sampler, totalCombinations, err := o.newSampler()
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("搜索方法 %s, 共 %d 次试验", o.config.Optimizer.Method, totalCombinations)
+
+	o.results = make([]OptimizationResult, 0, totalCombinations)
+	history := make([]optimize.Trial, 0, totalCombinations)
+	if study != nil {
+		if o.resume {
+			history, err = o.restore(study)
 			if err != nil {
-				log.Errorf("回测失败: %v", err)
-				return
+				return nil, err
 			}
+			log.Infof("从试验 [%s] 恢复 %d 次试验", study.Name(), len(history))
+		} else if err := o.resetStudy(study); err != nil {
+			return nil, err
+		}
+	}
+	progress := len(history)
 
-			//println("--> 005 got result:", result.Sharpe)
-			log.Warnf("Got result: %.2f, %.2f, %.2f; parameters: %v",
-				result.Sharpe, result.Returns, result.Drawdown, parameters)
+	// 每批并发执行 workerCount 次回测，TPE 和遗传算法根据已完成的试验生成下一批参数
+	for {
+		batch := sampler.Sample(history, o.workerCount)
+		if len(batch) == 0 {
+			break
+		}
 
-			// 保存结果
-			o.mu.Lock()
-			o.results = append(o.results, OptimizationResult{
-				Parameters: parameters,
-				Sharpe:     result.Sharpe,
-				Returns:    result.Returns,
-				Drawdown:   result.Drawdown,
-			})
-			o.mu.Unlock()
-		}(params, i)
-	}
+		trials := make([]optimize.Trial, len(batch))
+
+		var wg sync.WaitGroup
+		for i, params := range batch {
+			wg.Add(1)
+
+			go func(parameters map[string]interface{}, index int) {
+				defer wg.Done()
 
-	wg.Wait()
+				trials[index] = optimize.Trial{Params: parameters, Score: math.Inf(-1)}
 
-	// 按夏普率排序
+				// 运行回测
+				began := time.Now()
+				result, err := o.runBacktest(configWithParameters(o.config, parameters), start, end)
+				result.Score = resultMetrics(result)[o.objective()]
+				if study != nil {
+					_, storeErr := study.Add(parameters, result.Score, resultMetrics(result), time.Since(began), err)
+					if storeErr != nil {
+						log.Errorf("保存试验失败: %v", storeErr)
+					}
+				}
+				if err != nil {
+					log.Errorf("回测失败: %v", err)
+					return
+				}
+
+				//println("--> 005 got result:", result.Sharpe)
+				log.Warnf("Got result: %.2f, %.2f, %.2f; parameters: %v",
+					result.Sharpe, result.Returns, result.Drawdown, parameters)
+
+				// 无效的优化目标与失败的试验相同，排在最后
+				if math.IsNaN(result.Score) || math.IsInf(result.Score, 0) {
+					result.Score = math.Inf(-1)
+				}
+
+				// 保存结果，只保留收益序列，权益曲线和交易占用的内存随试验数和K线数增长
+				result.Parameters = parameters
+				result.ReturnSeries = metrics.EquityReturns(result.Equity)
+				result.Equity, result.Trades = nil, nil
+				trials[index].Score = result.Score
+				o.mu.Lock()
+				o.results = append(o.results, result)
+				o.mu.Unlock()
+			}(params, i)
+		}
+		wg.Wait()
+
+		history = append(history, trials...)
+		step := max(1, totalCombinations/20)
+		if (progress+len(batch))/step > progress/step { // 每完成5%输出一次进度
+			log.Infof("优化进度: %.1f%% (%d/%d)",
+				float64(progress+len(batch))/float64(max(1, totalCombinations))*100,
+				progress+len(batch),
+				totalCombinations)
+		}
+		progress += len(batch)
+	}
+
+	// 按优化目标排序
 	sort.Slice(o.results, func(i, j int) bool {
-		return o.results[i].Sharpe > o.results[j].Sharpe
+		return o.results[i].Score > o.results[j].Score
 	})
 
 	//println("--> 007 sorted result:", o.results[0].Sharpe)
 	if len(o.results) == 0 {
 		println("-> 未找到有效的优化结果")
-		return OptimizationResult{}, fmt.Errorf("未找到有效的优化结果")
+		return nil, fmt.Errorf("未找到有效的优化结果")
 	}
 
-	// 输出前N个最优结果
-	o.printTopResults(5)
-
-	return o.results[0], nil
+	return o.results, nil
 }
 
-func (oc *outputCapturer) Capture(fn func()) (string, error) {
-	oc.mu.Lock()
-	defer oc.mu.Unlock()
+// resetStudy 清空 study 中已有的试验，没有使用 WithReset 时返回错误，避免覆盖之前的优化结果
+func (o *Optimizer) resetStudy(study *optimize.Study) error {
+	trials, err := study.Trials()
+	if err != nil {
+		return err
+	}
+	if len(trials) == 0 {
+		return nil
+	}
 
-	// 清空缓冲区
-	oc.buffer.Reset()
+	if !o.reset {
+		return fmt.Errorf("试验 [%s] 已有 %d 次试验，使用 --resume 继续或 --reset 清空", study.Name(), len(trials))
+	}
 
-	// 创建管道
-	r, w, err := os.Pipe()
-	if err != nil {
-		return "", fmt.Errorf("创建管道失败: %w", err)
+	log.Warnf("清空试验 [%s] 已有的 %d 次试验", study.Name(), len(trials))
+	return study.Reset()
+}
+
+// resultMetrics 返回保存到数据库的回测指标
+func resultMetrics(result OptimizationResult) map[string]float64 {
+	return map[string]float64{
+		models.ObjectiveSharpe:   result.Sharpe,
+		models.ObjectiveSortino:  result.Sortino,
+		models.ObjectiveCalmar:   result.Calmar,
+		models.ObjectiveReturn:   result.Returns,
+		models.ObjectiveDrawdown: result.Drawdown,
+		models.ObjectiveProfit:   result.Profit,
+		"trades":                 float64(result.TradeCount),
 	}
-	defer r.Close()
+}
 
-	// 保存并重定向标准输出
-	oldStdout := os.Stdout
-	os.Stdout = w
+// restore 读取数据库中已完成的试验，返回搜索历史并恢复优化结果
+func (o *Optimizer) restore(study *optimize.Study) ([]optimize.Trial, error) {
+	// 优化目标可能与保存试验时不同，使用保存的指标重新计算
+	history, err := study.History(o.searchSpace(), o.objective())
+	if err != nil {
+		return nil, err
+	}
 
-	// 确保恢复标准输出
-	defer func() {
-		os.Stdout = oldStdout
-	}()
+	trials, err := study.Trials()
+	if err != nil {
+		return nil, err
+	}
 
-	// 创建一个done通道用于同步
-	done := make(chan struct{})
+	for i, trial := range trials {
+		if trial.Failed() || i >= len(history) {
+			continue
+		}
 
-	// 在goroutine中读取管道数据
-	go func() {
-		defer close(done)
-		_, err := io.Copy(&oc.buffer, r)
+		metrics, err := trial.Values()
 		if err != nil {
-			oc.readError = err
+			return nil, err
 		}
-	}()
 
-	// 执行目标函数
-	fn()
+		o.results = append(o.results, OptimizationResult{
+			Parameters: history[i].Params,
+			Score:      history[i].Score,
+			Sharpe:     metrics[models.ObjectiveSharpe],
+			Sortino:    metrics[models.ObjectiveSortino],
+			Calmar:     metrics[models.ObjectiveCalmar],
+			Returns:    metrics[models.ObjectiveReturn],
+			Drawdown:   metrics[models.ObjectiveDrawdown],
+			Profit:     metrics[models.ObjectiveProfit],
+			TradeCount: int(metrics["trades"]),
+		})
+	}
+	return history, nil
+}
 
-	// 关闭写入端并等待读取完成
-	w.Close()
-	<-done
+// configWithParameters 创建配置副本并更新参数默认值
+func configWithParameters(config *models.Config, parameters map[string]interface{}) *models.Config {
+	configCopy := *config
+	configCopy.Parameters = withDefaults(config.Parameters, parameters)
+	return &configCopy
+}
 
-	if oc.readError != nil {
-		return "", fmt.Errorf("读取输出失败: %w", oc.readError)
+// withDefaults 返回参数副本，values 中的值作为默认值，配置中没有的参数追加到末尾
+func withDefaults(parameters []models.Parameter, values map[string]interface{}) []models.Parameter {
+	result := append([]models.Parameter(nil), parameters...)
+	seen := make(map[string]bool, len(result))
+	for i := range result {
+		seen[result[i].Name] = true
+		if val, exists := values[result[i].Name]; exists {
+			result[i].Default = val
+		}
 	}
 
-	return oc.buffer.String(), nil
+	names := make([]string, 0, len(values))
+	for name := range values {
+		if !seen[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		result = append(result, models.Parameter{Name: name, Default: values[name]})
+	}
+	return result
 }
 
-// runBacktest 执行单次回测
-func (o *Optimizer) runBacktest(config *models.Config) (OptimizationResult, error) {
-	ctx := context.Background()
+// loadFeed 读取一次 CSV 数据，供所有回测共享
+func (o *Optimizer) loadFeed() error {
+	if o.feed != nil {
+		return nil
+	}
 
-	// 创建本地 KV 存储
 	kv, err := localkv.NewLocalKV(nil) // 使用临时内存存储
 	if err != nil {
-		return OptimizationResult{}, err
+		return err
 	}
 	defer kv.RemoveDB()
 
-	// 创建策略实例
-	var strategy strategies.Strategy
-	switch config.Strategy {
-	case "CrossEMA":
-		strategy, err = strategies.NewCrossEMA(config, kv)
-	default:
-		return OptimizationResult{}, fmt.Errorf("未知策略类型: %s", config.Strategy)
-	}
+	str, err := strategy.New(o.config, kv)
 	if err != nil {
-		return OptimizationResult{}, err
+		return err
 	}
 
 	// 准备数据源
-	pairFeed := make([]exchange.PairFeed, 0, len(config.AssetWeights))
-	for pair := range config.AssetWeights {
+	pairFeed := make([]exchange.PairFeed, 0, len(o.config.AssetWeights))
+	for pair := range o.config.AssetWeights {
 		pairFeed = append(pairFeed, exchange.PairFeed{
 			Pair:      pair,
-			File:      fmt.Sprintf("testdata/%s-%s.csv", pair, strategy.Timeframe()),
-			Timeframe: strategy.Timeframe(),
+			File:      fmt.Sprintf("testdata/%s-%s.csv", pair, str.Timeframe()),
+			Timeframe: str.Timeframe(),
 		})
 	}
 
 	// 创建 CSV 数据源
-	csvFeed, err := exchange.NewCSVFeed(strategy.Timeframe(), pairFeed...)
+	o.feed, err = exchange.NewCSVFeed(str.Timeframe(), pairFeed...)
+	if err != nil {
+		return err
+	}
+	o.timeframe = str.Timeframe()
+	o.warmup = str.WarmupPeriod()
+
+	// 策略的参数定义用于补全搜索空间
+	if parameterized, ok := str.(strategy.ParameterizedStrategy); ok {
+		o.schema, err = models.ParameterSchema(parameterized.Parameters())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runBacktest 在时间区间 [start, end) 内执行单次回测，零值时间表示不限制
+func (o *Optimizer) runBacktest(config *models.Config, start, end time.Time) (OptimizationResult, error) {
+	ctx := context.Background()
+
+	// 创建本地 KV 存储
+	kv, err := localkv.NewLocalKV(nil) // 使用临时内存存储
 	if err != nil {
 		return OptimizationResult{}, err
 	}
+	defer kv.RemoveDB()
+
+	// 创建策略实例
+	str, err := strategy.New(config, kv)
+	if err != nil {
+		return OptimizationResult{}, err
+	}
+
+	// 数据源为共享数据的副本
+	csvFeed := o.feed.Slice(start, end)
 
 	// 创建存储
 	storage, err := storage.FromMemory()
@@ -336,6 +610,8 @@ func (o *Optimizer) runBacktest(config *models.Config) (OptimizationResult, erro
 		"USDT",
 		exchange.WithPaperAsset("USDT", config.BacktestConfig.InitialBalance), // 使用 BacktestConfig 而不是 Backtest
 		exchange.WithDataFeed(csvFeed),
+		exchange.WithPaperFee(config.BacktestConfig.Fee, config.BacktestConfig.Fee),
+		exchange.WithPaperSlippage(exchange.FixedSlippage{BPS: config.BacktestConfig.Slippage * 10000}),
 	)
 
 	// 获取交易对列表
@@ -351,110 +627,40 @@ func (o *Optimizer) runBacktest(config *models.Config) (OptimizationResult, erro
 			Pairs: pairs,
 		},
 		wallet,
-		strategy,
+		str,
 		azbot.WithBacktest(wallet),
 		azbot.WithStorage(storage),
 		azbot.WithLogLevel(log.WarnLevel), // 使用 info 级别日志太多
+		azbot.WithProgressBar(false),      // 关闭回测进度条
 	)
 	if err != nil {
 		return OptimizationResult{}, err
 	}
 
-	// 重定向错误输出到空设备（关闭回测进度条）
-	oldStderr = os.Stderr
-	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
-	if err != nil {
-		log.Fatal(err)
-	}
-	os.Stderr = devNull
 	// 运行回测
 	if err := bot.Run(ctx); err != nil {
-		os.Stderr = oldStderr // 恢复错误输出
 		return OptimizationResult{}, err
 	}
-	// 恢复错误输出
-	os.Stderr = oldStderr
 
-	// 使用互斥锁保护输出重定向操作
-	//var outputMutex sync.Mutex
-	//outputMutex.Lock()
-	//defer outputMutex.Unlock()
+	// 根据权益曲线计算年化夏普率
+	report := bot.Report()
+	sharpeRatio := report.Metrics.Sharpe
 
-	// 运行回测后捕获输出
-	output, err := globalCapturer.Capture(func() {
-		bot.Summary()
-	})
-	if err != nil {
-		log.Errorf("捕获输出失败: %v", err)
-		return OptimizationResult{}, err
-	}
-
-	//println("--> 003: length of output", len(output))
-
-	if len(output) < 100 { // 添加基本的输出长度检查
-		return OptimizationResult{}, fmt.Errorf("输出数据异常：长度=%d", len(output))
+	var trades []float64
+	for _, pair := range report.Pairs {
+		trades = append(trades, pair.Profits...)
 	}
 
-	// 使用正则表达式提取需要的信息
-	var (
-		returns float64
-		//drawdown float64
-		//profit   float64
-	)
-
-	// 使用正则表达式解析结果
-	startPortfolioRe := regexp.MustCompile(`START PORTFOLIO\s*=\s*([\d.]+)`)
-	finalPortfolioRe := regexp.MustCompile(`FINAL PORTFOLIO\s*=\s*([\d.]+)`)
-	maxDrawdownRe := regexp.MustCompile(`MAX DRAWDOWN = (-[\d.]+)`)
-	// 增强容错处理
-	//startPortfolioRe := regexp.MustCompile(`(?i)start.*?portfolio\s*[:=]?\s*([\d.]+)`)
-	//finalPortfolioRe := regexp.MustCompile(`(?i)final.*?portfolio\s*[:=]?\s*([\d.]+)`)
-	//maxDrawdownRe := regexp.MustCompile(`(?i)max(?:imum)?\s*drawdown\s*[:=]?\s*(-?[\d.]+)%?`)
-
-	matches := startPortfolioRe.FindStringSubmatch(output)
-	if matches == nil {
-		//println("--> 101 output:", output)
-		failCount.Add(1)
-		log.Errorf("无法解析起始资金数据 err %d", failCount.Load())
-		return OptimizationResult{}, err
-	}
-	startPortfolio, _ := strconv.ParseFloat(matches[1], 64)
-
-	matches = finalPortfolioRe.FindStringSubmatch(output)
-	if matches == nil {
-		failCount.Add(1)
-		//println("--> 102 output:", output)
-		log.Errorf("无法解析最终资金数据 err %d", failCount.Load())
-		return OptimizationResult{}, err
-	}
-	finalPortfolio, _ := strconv.ParseFloat(matches[1], 64)
-
-	matches = maxDrawdownRe.FindStringSubmatch(output)
-	if matches == nil {
-		//println("--> 103 output:", output)
-		failCount.Add(1)
-		log.Errorf("无法解析最大回撤数据 err %d", failCount.Load())
-		return OptimizationResult{}, err
-	}
-	maxDrawdown, _ := strconv.ParseFloat(matches[1], 64)
-
-	//println("--> 004 ", startPortfolio, finalPortfolio, maxDrawdown)
-	// 计算夏普率
-	riskFreeRate := 0.02
-	returns = (finalPortfolio - startPortfolio) / startPortfolio
-	volatility := math.Abs(maxDrawdown / 100.0)
-	if volatility == 0 {
-		volatility = 0.0001 // 避免除以零
-	}
-	sharpeRatio := (returns - riskFreeRate) / volatility
-
-	successCount.Add(1)
-
 	return OptimizationResult{
-		Sharpe:   sharpeRatio,
-		Returns:  returns,
-		Drawdown: maxDrawdown,                     // 使用解析得到的最大回撤值
-		Profit:   finalPortfolio - startPortfolio, // 计算实际利润
+		Sharpe:     sharpeRatio,
+		Sortino:    report.Metrics.Sortino,
+		Calmar:     report.Metrics.Calmar,
+		Returns:    report.Return,
+		Drawdown:   report.MaxDrawdown,
+		Profit:     report.Profit,
+		TradeCount: len(trades),
+		Equity:     report.Equity,
+		Trades:     trades,
 	}, nil
 }
 
@@ -464,21 +670,19 @@ func (o *Optimizer) printTopResults(n int) {
 		return
 	}
 
-	log.Warnf("优化回测结果解析成功率: %.01f%%",
-		float64(successCount.Load())/float64(successCount.Load()+failCount.Load())*100)
-	//os.Stderr = oldStderr // 恢复错误输出（必需）
 	//log.SetOutput(os.Stderr) // 默认输出到 stderr
 	//log.SetLevel(log.InfoLevel)
 	log.Warnf("最优参数组合（前5个）:")
 	log.Warnf("----------------------------------------")
-	log.Warnf("排名 | 夏普率 | 收益率 | 最大回撤 | 参数")
+	log.Warnf("排名 | %s | 夏普率 | 收益率 | 最大回撤 | 参数", o.objective())
 	log.Warnf("----------------------------------------")
 
 	for i := 0; i < min(n, len(o.results)); i++ {
 		result := o.results[i]
 		log.Warnf(
-			"#%d | %.2f | %.2f%% | %.2f%% | %v",
+			"#%d | %.4f | %.2f | %.2f%% | %.2f%% | %v",
 			i+1,
+			result.Score,
 			result.Sharpe,
 			result.Returns*100,
 			result.Drawdown*100,
@@ -490,11 +694,7 @@ func (o *Optimizer) printTopResults(n int) {
 // saveOptimizedConfig 保存优化后的配置
 func saveOptimizedConfig(config *models.Config, bestParams map[string]interface{}) error {
 	// 更新配置中的默认参数
-	for i := range config.Parameters {
-		if val, exists := bestParams[config.Parameters[i].Name]; exists {
-			config.Parameters[i].Default = val
-		}
-	}
+	config.Parameters = withDefaults(config.Parameters, bestParams)
 
 	// 生成优化后的配置文件名
 	optimizedConfigPath := fmt.Sprintf("user_data/config_%s_optimized.yml", config.Strategy)
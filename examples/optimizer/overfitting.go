@@ -0,0 +1,62 @@
+package optimizer
+
+import (
+	"errors"
+
+	"github.com/ezquant/azbot/azbot/metrics"
+	log "github.com/ezquant/azbot/azbot/tools/log"
+)
+
+// Overfitting 为最优参数的过拟合诊断，由本次优化各试验的收益序列计算
+type Overfitting struct {
+	// Trials 为参与计算的试验数，从数据库恢复的试验没有收益序列，不参与计算
+	Trials int
+	// DeflatedSharpe 为扣除多次试验的选择偏差后，最优参数夏普率为正的概率
+	DeflatedSharpe float64
+	// PBO 为回测过拟合概率：样本内最优参数在样本外低于中位数的比例
+	PBO float64
+}
+
+// Overfitting 计算最优结果的紧缩夏普率和回测过拟合概率 (CSCV)
+func (o *Optimizer) Overfitting() (Overfitting, error) {
+	if len(o.results) == 0 || len(o.results[0].ReturnSeries) == 0 {
+		return Overfitting{}, errors.New("最优参数没有收益序列")
+	}
+
+	// results 已按配置的优化目标排序，第一个为最优参数
+	var returns [][]float64
+	var sharpes []float64
+	for _, result := range o.results {
+		if len(result.ReturnSeries) == 0 {
+			continue
+		}
+		returns = append(returns, result.ReturnSeries)
+		sharpes = append(sharpes, metrics.Sharpe(result.ReturnSeries, 0, 1))
+	}
+
+	diagnostics := Overfitting{
+		Trials:         len(returns),
+		DeflatedSharpe: metrics.DeflatedSharpe(returns[0], sharpes),
+	}
+
+	var err error
+	diagnostics.PBO, err = metrics.PBO(returns, metrics.DefaultSplits)
+	return diagnostics, err
+}
+
+// printOverfitting 输出最优参数的过拟合诊断
+func (o *Optimizer) printOverfitting() {
+	diagnostics, err := o.Overfitting()
+	if err != nil && diagnostics.Trials == 0 {
+		log.Warnf("无法计算过拟合诊断: %v", err)
+		return
+	}
+
+	log.Infof("试验次数: %d", diagnostics.Trials)
+	log.Infof("紧缩夏普率: %.2f%%", diagnostics.DeflatedSharpe*100)
+	if err != nil {
+		log.Warnf("无法计算回测过拟合概率: %v", err)
+		return
+	}
+	log.Infof("回测过拟合概率 (PBO): %.2f%%", diagnostics.PBO*100)
+}
@@ -0,0 +1,200 @@
+package optimizer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ezquant/azbot/azbot/exchange"
+	"github.com/ezquant/azbot/azbot/plot"
+	log "github.com/ezquant/azbot/azbot/tools/log"
+)
+
+// 试验表的格式
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// trialRow 为试验表的一行，非有限的指标在 JSON 中为 null
+type trialRow struct {
+	Rank       int                    `json:"rank"`
+	Score      *float64               `json:"score"`
+	Parameters map[string]interface{} `json:"parameters"`
+	Sharpe     *float64               `json:"sharpe"`
+	Sortino    *float64               `json:"sortino"`
+	Calmar     *float64               `json:"calmar"`
+	Return     *float64               `json:"return"`
+	Drawdown   *float64               `json:"drawdown"`
+	Profit     *float64               `json:"profit"`
+	Trades     int                    `json:"trades"`
+}
+
+// WriteTrials 输出所有成功试验的参数和回测指标，按优化目标从高到低排序，format 为 csv 或 json
+func (o *Optimizer) WriteTrials(w io.Writer, format string) error {
+	rows := make([]trialRow, len(o.results))
+	for i, result := range o.results {
+		rows[i] = trialRow{
+			Rank:       i + 1,
+			Score:      finite(result.Score),
+			Parameters: result.Parameters,
+			Sharpe:     finite(result.Sharpe),
+			Sortino:    finite(result.Sortino),
+			Calmar:     finite(result.Calmar),
+			Return:     finite(result.Returns),
+			Drawdown:   finite(result.Drawdown),
+			Profit:     finite(result.Profit),
+			Trades:     result.TradeCount,
+		}
+	}
+
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rows)
+	case FormatCSV:
+		return writeTrialsCSV(w, rows)
+	}
+	return fmt.Errorf("不支持的试验表格式 %s", format)
+}
+
+// writeTrialsCSV 每个参数一列，参数按名称排序
+func writeTrialsCSV(w io.Writer, rows []trialRow) error {
+	seen := make(map[string]bool)
+	var names []string
+	for _, row := range rows {
+		for name := range row.Parameters {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	writer := csv.NewWriter(w)
+	header := append([]string{"rank", "score"}, names...)
+	header = append(header, "sharpe", "sortino", "calmar", "return", "drawdown", "profit", "trades")
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		record := []string{strconv.Itoa(row.Rank), formatFloat(row.Score)}
+		for _, name := range names {
+			value, ok := row.Parameters[name]
+			if !ok {
+				record = append(record, "")
+				continue
+			}
+			record = append(record, fmt.Sprint(value))
+		}
+		for _, value := range []*float64{row.Sharpe, row.Sortino, row.Calmar, row.Return, row.Drawdown, row.Profit} {
+			record = append(record, formatFloat(value))
+		}
+		record = append(record, strconv.Itoa(row.Trades))
+
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteReport 输出 HTML 报告：每两个参数的优化目标热力图，以及最优和中位数试验的权益曲线
+func (o *Optimizer) WriteReport(w io.Writer) error {
+	report := plot.OptimizationReport{
+		Strategy:  o.config.Strategy,
+		Objective: o.objective(),
+		Trials:    make([]plot.OptimizationTrial, 0, len(o.results)),
+	}
+
+	for _, result := range o.results {
+		report.Trials = append(report.Trials, plot.OptimizationTrial{Params: result.Parameters, Score: result.Score})
+	}
+
+	// results 已按优化目标排序，试验不保留权益曲线，重新回测最优和中位数试验
+	if len(o.results) > 0 {
+		var err error
+		if report.Best, err = o.equity(o.results[0]); err != nil {
+			return err
+		}
+		if report.Median, err = o.equity(o.results[len(o.results)/2]); err != nil {
+			return err
+		}
+	}
+
+	return plot.WriteOptimizationReport(w, report)
+}
+
+// equity 使用试验的参数在全部数据上回测，返回权益曲线
+func (o *Optimizer) equity(result OptimizationResult) ([]exchange.AssetValue, error) {
+	backtest, err := o.runBacktest(configWithParameters(o.config, result.Parameters), time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	return backtest.Equity, nil
+}
+
+// saveOutputs 保存试验表和 HTML 报告
+func (o *Optimizer) saveOutputs() error {
+	if o.trialsOutput != "" {
+		format := FormatCSV
+		if strings.EqualFold(filepath.Ext(o.trialsOutput), ".json") {
+			format = FormatJSON
+		}
+		if err := writeFile(o.trialsOutput, func(w io.Writer) error { return o.WriteTrials(w, format) }); err != nil {
+			return err
+		}
+		log.Infof("试验表已保存到: %s", o.trialsOutput)
+	}
+
+	if o.report != "" {
+		if err := writeFile(o.report, o.WriteReport); err != nil {
+			return err
+		}
+		log.Infof("优化报告已保存到: %s", o.report)
+	}
+	return nil
+}
+
+func writeFile(path string, write func(w io.Writer) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func finite(value float64) *float64 {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil
+	}
+	return &value
+}
+
+func formatFloat(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
@@ -0,0 +1,325 @@
+package optimizer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/xhit/go-str2duration/v2"
+
+	"github.com/ezquant/azbot/azbot/exchange"
+	"github.com/ezquant/azbot/azbot/metrics"
+	"github.com/ezquant/azbot/azbot/optimize"
+	"github.com/ezquant/azbot/azbot/plus/models"
+	log "github.com/ezquant/azbot/azbot/tools/log"
+)
+
+// WalkForwardWindow 为一个训练窗口及其后的样本外测试窗口，时间区间均为 [start, end)
+type WalkForwardWindow struct {
+	TrainStart time.Time
+	TrainEnd   time.Time
+	TestStart  time.Time
+	TestEnd    time.Time
+
+	// Parameters 为训练窗口的最优参数，OutOfSample 为其在测试窗口的回测结果
+	Parameters  map[string]interface{}
+	InSample    OptimizationResult
+	OutOfSample OptimizationResult
+}
+
+// ParameterStability 统计参数在各窗口最优值的变化，Mean 和 StdDev 仅用于数值参数
+type ParameterStability struct {
+	Name   string
+	Values []interface{}
+	Mean   float64
+	StdDev float64
+	// Mode 为出现次数最多的值，ModeRatio 为其占窗口数的比例
+	Mode      interface{}
+	ModeRatio float64
+}
+
+type WalkForwardReport struct {
+	Windows []WalkForwardWindow
+	// Equity 为拼接后的样本外权益曲线，每个测试窗口从上一窗口的期末权益开始
+	Equity  []exchange.AssetValue
+	Metrics metrics.Metrics
+	// Efficiency 为样本外与样本内的日收益之比，各窗口的收益按窗口天数折算为日复利收益，越接近 1 过拟合越少。
+	// 样本内收益不为正时为 0
+	Efficiency float64
+	Stability  []ParameterStability
+}
+
+// walkForwardWindows 从 start 开始按测试窗口长度滚动生成窗口，直到数据结束时间 end。
+// 最后一个测试窗口截止于 end，anchored 为 true 时训练窗口始终从 start 开始。
+func walkForwardWindows(start, end time.Time, train, test time.Duration, anchored bool) []WalkForwardWindow {
+	var windows []WalkForwardWindow
+	for testStart := start.Add(train); testStart.Before(end); testStart = testStart.Add(test) {
+		trainStart := testStart.Add(-train)
+		if anchored {
+			trainStart = start
+		}
+
+		testEnd := testStart.Add(test)
+		if testEnd.After(end) {
+			testEnd = end
+		}
+
+		windows = append(windows, WalkForwardWindow{
+			TrainStart: trainStart,
+			TrainEnd:   testStart,
+			TestStart:  testStart,
+			TestEnd:    testEnd,
+		})
+	}
+	return windows
+}
+
+// dailyReturn 将 duration 内的收益折算为日复利收益，使不同长度窗口的收益可以比较
+func dailyReturn(returns float64, duration time.Duration) float64 {
+	days := duration.Hours() / 24
+	if days <= 0 {
+		return 0
+	}
+	if returns <= -1 {
+		return -1
+	}
+	return math.Pow(1+returns, 1/days) - 1
+}
+
+// WalkForward 在每个训练窗口优化参数，并在之后的测试窗口评估最优参数
+func (o *Optimizer) WalkForward(settings models.WalkForward) (WalkForwardReport, error) {
+	train, err := str2duration.ParseDuration(settings.Train)
+	if err != nil {
+		return WalkForwardReport{}, fmt.Errorf("训练窗口无效 %s: %w", settings.Train, err)
+	}
+
+	test, err := str2duration.ParseDuration(settings.Test)
+	if err != nil {
+		return WalkForwardReport{}, fmt.Errorf("测试窗口无效 %s: %w", settings.Test, err)
+	}
+
+	if err := o.loadFeed(); err != nil {
+		return WalkForwardReport{}, err
+	}
+
+	interval, err := str2duration.ParseDuration(o.timeframe)
+	if err != nil {
+		return WalkForwardReport{}, err
+	}
+
+	// Range 返回最后一根K线的开始时间，数据截止于其结束时间
+	start, end := o.feed.Range()
+	windows := walkForwardWindows(start, end.Add(interval), train, test, settings.Anchored)
+	if len(windows) == 0 {
+		return WalkForwardReport{}, fmt.Errorf("数据不足: %s - %s 小于训练窗口 %s",
+			start.Format(time.DateOnly), end.Format(time.DateOnly), settings.Train)
+	}
+
+	report := WalkForwardReport{}
+	value := o.config.BacktestConfig.InitialBalance
+	var inSample, outOfSample float64
+	var trades []float64
+
+	for i := range windows {
+		window := &windows[i]
+		log.Infof("窗口 %d/%d: 训练 %s - %s, 测试 %s - %s", i+1, len(windows),
+			window.TrainStart.Format(time.DateOnly), window.TrainEnd.Format(time.DateOnly),
+			window.TestStart.Format(time.DateOnly), window.TestEnd.Format(time.DateOnly))
+
+		// 每个窗口的试验保存为独立的 study
+		var study *optimize.Study
+		if o.study != nil {
+			study = o.study.WithName(fmt.Sprintf("%s/wf-%d", o.study.Name(), i+1))
+		}
+
+		results, err := o.search(study, window.TrainStart, window.TrainEnd)
+		if err != nil {
+			return WalkForwardReport{}, err
+		}
+		window.InSample = results[0]
+		window.Parameters = results[0].Parameters
+
+		// 测试窗口前保留预热数据，策略从测试窗口开始交易
+		window.OutOfSample, err = o.runBacktest(configWithParameters(o.config, window.Parameters),
+			o.warmupStart(window.TestStart), window.TestEnd)
+		if err != nil {
+			return WalkForwardReport{}, err
+		}
+		window.OutOfSample.Parameters = window.Parameters
+
+		report.Equity = append(report.Equity, rebaseEquity(window.OutOfSample.Equity, window.TestStart, value)...)
+		if len(report.Equity) > 0 {
+			value = report.Equity[len(report.Equity)-1].Value
+		}
+
+		inSample += dailyReturn(window.InSample.Returns, window.TrainEnd.Sub(window.TrainStart))
+		outOfSample += dailyReturn(window.OutOfSample.Returns, window.TestEnd.Sub(window.TestStart))
+		trades = append(trades, window.OutOfSample.Trades...)
+	}
+
+	report.Windows = windows
+	report.Stability = parameterStability(o.parameters(), windows)
+	report.Efficiency = walkForwardEfficiency(inSample, outOfSample)
+
+	report.Metrics, err = metrics.Compute(report.Equity, trades, o.timeframe)
+	if err != nil {
+		log.Warnf("样本外指标计算失败: %v", err)
+	}
+
+	return report, nil
+}
+
+// rebaseEquity 返回从 start 开始的权益曲线，并按比例缩放使其从 value 开始，start 之前为预热数据
+func rebaseEquity(equity []exchange.AssetValue, start time.Time, value float64) []exchange.AssetValue {
+	var base float64
+	var rebased []exchange.AssetValue
+	for _, point := range equity {
+		if point.Time.Before(start) {
+			continue
+		}
+		if base == 0 {
+			base = point.Value
+		}
+		if base > 0 {
+			rebased = append(rebased, exchange.AssetValue{
+				Time:  point.Time,
+				Value: value * point.Value / base,
+			})
+		}
+	}
+	return rebased
+}
+
+// walkForwardEfficiency 返回样本外与样本内日收益之比，样本内收益不为正时比值没有意义，返回 0
+func walkForwardEfficiency(inSample, outOfSample float64) float64 {
+	if inSample <= 0 {
+		return 0
+	}
+	return outOfSample / inSample
+}
+
+// warmupStart 返回 t 之前第 warmup-1 根K线的时间，使策略在 t 完成预热
+func (o *Optimizer) warmupStart(t time.Time) time.Time {
+	pairs := make([]string, 0, len(o.config.AssetWeights))
+	for pair := range o.config.AssetWeights {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+
+	start := t
+	for _, pair := range pairs {
+		candles, err := o.feed.CandlesByPeriod(context.Background(), pair, o.timeframe, time.Time{}, t)
+		if err != nil {
+			continue
+		}
+
+		// 只使用完整K线，并排除时间为 t 的K线
+		var times []time.Time
+		for _, candle := range candles {
+			if candle.Complete && candle.Time.Before(t) {
+				times = append(times, candle.Time)
+			}
+		}
+
+		index := max(0, len(times)-(o.warmup-1))
+		if index < len(times) && times[index].Before(start) {
+			start = times[index]
+		}
+	}
+	return start
+}
+
+// parameterStability 统计各窗口最优参数的分布
+func parameterStability(parameters []models.Parameter, windows []WalkForwardWindow) []ParameterStability {
+	stability := make([]ParameterStability, 0, len(parameters))
+	for _, param := range parameters {
+		result := ParameterStability{Name: param.Name}
+
+		counts := make(map[string]int)
+		var modeCount int
+		var numbers []float64
+		for _, window := range windows {
+			value, ok := window.Parameters[param.Name]
+			if !ok {
+				continue
+			}
+			result.Values = append(result.Values, value)
+
+			key := fmt.Sprint(value)
+			counts[key]++
+			if counts[key] > modeCount {
+				result.Mode = value
+				modeCount = counts[key]
+			}
+
+			switch v := value.(type) {
+			case int:
+				numbers = append(numbers, float64(v))
+			case float64:
+				numbers = append(numbers, v)
+			}
+		}
+
+		if len(result.Values) > 0 {
+			result.ModeRatio = float64(modeCount) / float64(len(result.Values))
+		}
+
+		if len(numbers) > 0 && len(numbers) == len(result.Values) {
+			for _, n := range numbers {
+				result.Mean += n
+			}
+			result.Mean /= float64(len(numbers))
+
+			for _, n := range numbers {
+				result.StdDev += (n - result.Mean) * (n - result.Mean)
+			}
+			result.StdDev = math.Sqrt(result.StdDev / float64(len(numbers)))
+		}
+
+		stability = append(stability, result)
+	}
+	return stability
+}
+
+// runWalkForward 执行滚动优化，输出样本外结果并保存最后一个窗口的参数
+func runWalkForward(optimizer *Optimizer, config *models.Config) {
+	report, err := optimizer.WalkForward(config.WalkForward)
+	if err != nil {
+		log.Fatal("滚动优化失败: ", err)
+	}
+
+	log.Warnf("滚动优化结果:")
+	log.Warnf("----------------------------------------")
+	log.Warnf("窗口 | 测试区间 | 样本内夏普率 | 样本外夏普率 | 样本外收益率 | 参数")
+	log.Warnf("----------------------------------------")
+	for i, window := range report.Windows {
+		log.Warnf("#%d | %s - %s | %.2f | %.2f | %.2f%% | %v",
+			i+1,
+			window.TestStart.Format(time.DateOnly),
+			window.TestEnd.Format(time.DateOnly),
+			window.InSample.Sharpe,
+			window.OutOfSample.Sharpe,
+			window.OutOfSample.Returns*100,
+			window.Parameters)
+	}
+	log.Warnf("----------------------------------------")
+	log.Warnf("参数稳定性:")
+	for _, param := range report.Stability {
+		log.Warnf("%s: 众数=%v (%.0f%%), 均值=%.4f, 标准差=%.4f, 取值=%v",
+			param.Name, param.Mode, param.ModeRatio*100, param.Mean, param.StdDev, param.Values)
+	}
+	log.Warnf("----------------------------------------")
+	log.Warnf("样本外夏普率: %.2f", report.Metrics.Sharpe)
+	log.Warnf("样本外收益率: %.2f%%", report.Metrics.TotalReturn*100)
+	log.Warnf("样本外最大回撤: %.2f%%", report.Metrics.MaxDrawdown*100)
+	log.Warnf("滚动优化效率: %.2f", report.Efficiency)
+	log.Warnf("----------------------------------------")
+
+	// 最后一个窗口的参数用于后续交易
+	last := report.Windows[len(report.Windows)-1]
+	if err := saveOptimizedConfig(config, last.Parameters); err != nil {
+		log.Errorf("保存优化后的配置失败: %v", err)
+	}
+}
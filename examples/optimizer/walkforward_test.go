@@ -0,0 +1,167 @@
+package optimizer
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ezquant/azbot/azbot/exchange"
+	"github.com/ezquant/azbot/azbot/plus/models"
+)
+
+func TestWalkForwardWindows(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := func(n int) time.Time {
+		return start.AddDate(0, 0, n)
+	}
+
+	tt := []struct {
+		name     string
+		end      time.Time
+		anchored bool
+		expected []WalkForwardWindow
+	}{
+		{
+			name: "rolling",
+			end:  day(40),
+			expected: []WalkForwardWindow{
+				{TrainStart: day(0), TrainEnd: day(20), TestStart: day(20), TestEnd: day(30)},
+				{TrainStart: day(10), TrainEnd: day(30), TestStart: day(30), TestEnd: day(40)},
+			},
+		},
+		{
+			name:     "anchored",
+			end:      day(40),
+			anchored: true,
+			expected: []WalkForwardWindow{
+				{TrainStart: day(0), TrainEnd: day(20), TestStart: day(20), TestEnd: day(30)},
+				{TrainStart: day(0), TrainEnd: day(30), TestStart: day(30), TestEnd: day(40)},
+			},
+		},
+		{
+			name: "last test window clamped to the end of data",
+			end:  day(35),
+			expected: []WalkForwardWindow{
+				{TrainStart: day(0), TrainEnd: day(20), TestStart: day(20), TestEnd: day(30)},
+				{TrainStart: day(10), TrainEnd: day(30), TestStart: day(30), TestEnd: day(35)},
+			},
+		},
+		{
+			name: "data shorter than the train window",
+			end:  day(15),
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			windows := walkForwardWindows(start, tc.end, 20*24*time.Hour, 10*24*time.Hour, tc.anchored)
+			require.Equal(t, tc.expected, windows)
+		})
+	}
+}
+
+func TestDailyReturn(t *testing.T) {
+	tt := []struct {
+		name     string
+		returns  float64
+		duration time.Duration
+		expected float64
+	}{
+		{name: "one day", returns: 0.1, duration: 24 * time.Hour, expected: 0.1},
+		{name: "compounded", returns: 0.21, duration: 48 * time.Hour, expected: 0.1},
+		{name: "loss", returns: -0.19, duration: 48 * time.Hour, expected: -0.1},
+		{name: "total loss", returns: -1, duration: 48 * time.Hour, expected: -1},
+		{name: "empty duration", returns: 0.1, duration: 0, expected: 0},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			require.InDelta(t, tc.expected, dailyReturn(tc.returns, tc.duration), 1e-9)
+		})
+	}
+}
+
+func TestWalkForwardEfficiency(t *testing.T) {
+	tt := []struct {
+		name        string
+		inSample    float64
+		outOfSample float64
+		expected    float64
+	}{
+		{name: "positive", inSample: 0.02, outOfSample: 0.01, expected: 0.5},
+		{name: "out of sample loss", inSample: 0.02, outOfSample: -0.01, expected: -0.5},
+		{name: "both negative", inSample: -0.02, outOfSample: -0.01, expected: 0},
+		{name: "zero in sample", inSample: 0, outOfSample: 0.01, expected: 0},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			require.InDelta(t, tc.expected, walkForwardEfficiency(tc.inSample, tc.outOfSample), 1e-9)
+		})
+	}
+}
+
+func TestRebaseEquity(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	equity := []exchange.AssetValue{
+		{Time: start.Add(-time.Hour), Value: 900},
+		{Time: start, Value: 1000},
+		{Time: start.Add(time.Hour), Value: 1100},
+		{Time: start.Add(2 * time.Hour), Value: 950},
+	}
+
+	// warmup points are ignored and the window starts with the final equity of the previous window
+	require.Equal(t, []exchange.AssetValue{
+		{Time: start, Value: 2000},
+		{Time: start.Add(time.Hour), Value: 2200},
+		{Time: start.Add(2 * time.Hour), Value: 1900},
+	}, rebaseEquity(equity, start, 2000))
+
+	require.Empty(t, rebaseEquity(equity, start.Add(3*time.Hour), 2000))
+	require.Empty(t, rebaseEquity([]exchange.AssetValue{{Time: start, Value: 0}}, start, 2000))
+}
+
+func TestParameterStability(t *testing.T) {
+	parameters := []models.Parameter{
+		{Name: "period", Type: "int"},
+		{Name: "ratio", Type: "float"},
+		{Name: "mode", Type: "enum"},
+		{Name: "unused", Type: "int"},
+	}
+	windows := []WalkForwardWindow{
+		{Parameters: map[string]interface{}{"period": 10, "ratio": 0.5, "mode": "fast"}},
+		{Parameters: map[string]interface{}{"period": 10, "ratio": 1.5, "mode": "slow"}},
+		{Parameters: map[string]interface{}{"period": 16, "ratio": 1.0, "mode": "fast"}},
+		{Parameters: map[string]interface{}{"period": 12, "ratio": 1.0, "mode": "fast"}},
+	}
+
+	stability := parameterStability(parameters, windows)
+	require.Len(t, stability, 4)
+
+	period := stability[0]
+	require.Equal(t, "period", period.Name)
+	require.Equal(t, []interface{}{10, 10, 16, 12}, period.Values)
+	require.Equal(t, 10, period.Mode)
+	require.Equal(t, 0.5, period.ModeRatio)
+	require.InDelta(t, 12, period.Mean, 1e-9)
+	require.InDelta(t, math.Sqrt(6), period.StdDev, 1e-9)
+
+	ratio := stability[1]
+	require.Equal(t, 1.0, ratio.Mode)
+	require.InDelta(t, 1, ratio.Mean, 1e-9)
+	require.InDelta(t, math.Sqrt(0.125), ratio.StdDev, 1e-9)
+
+	// mean and standard deviation are only calculated for numbers
+	mode := stability[2]
+	require.Equal(t, "fast", mode.Mode)
+	require.Equal(t, 0.75, mode.ModeRatio)
+	require.Zero(t, mode.Mean)
+	require.Zero(t, mode.StdDev)
+
+	unused := stability[3]
+	require.Empty(t, unused.Values)
+	require.Nil(t, unused.Mode)
+	require.Zero(t, unused.ModeRatio)
+}
@@ -6,54 +6,68 @@ import (
 	"github.com/ezquant/azbot/azbot/plus/localkv"
 	"github.com/ezquant/azbot/azbot/plus/models"
 	"github.com/ezquant/azbot/azbot/service"
+	"github.com/ezquant/azbot/azbot/sizing"
 	"github.com/ezquant/azbot/azbot/strategy"
 	"github.com/ezquant/azbot/azbot/tools/log"
 )
 
 type CrossEMA struct {
-	D              *models.StrategyData
-	kv             *localkv.LocalKV
-	positionSize   bool
-	riskPerTrade   float64
-	stopLoss       bool
-	stopLossMult   float64
-	takeProfit     bool
-	takeProfitMult float64
-	ema8Period     int
-	sma21Period    int
+	// Sizer calculates the quantity of buy orders, by default 30% of the free quote
+	Sizer sizing.Sizer
+
+	D      *models.StrategyData
+	kv     *localkv.LocalKV
+	params CrossEMAParameters
+}
+
+// CrossEMAParameters are the parameters of CrossEMA bound from the configuration
+type CrossEMAParameters struct {
+	// PositionSize is kept for the existing configurations, it doesn't change the size of orders
+	PositionSize bool `param:"dynamic_position_size" default:"false"`
+	// Sizing selects the sizer of buy orders: free_quote (30% of the free quote), fixed_fractional
+	// (30% of the equity) or atr (risk_per_trade of the equity with a stop at stop_loss_multiplier ATRs)
+	Sizing         string  `param:"position_sizing" default:"free_quote" enum:"free_quote,fixed_fractional,atr"`
+	RiskPerTrade   float64 `param:"risk_per_trade" default:"0.02" min:"0" max:"1" step:"0.01"`
+	StopLoss       bool    `param:"dynamic_stop_loss" default:"false"`
+	StopLossMult   float64 `param:"stop_loss_multiplier" default:"2" min:"0.5" max:"10" step:"0.5"`
+	TakeProfit     bool    `param:"dynamic_take_profit" default:"false"`
+	TakeProfitMult float64 `param:"take_profit_multiplier" default:"2" min:"0.5" max:"10" step:"0.5"`
+	EMA8Period     int     `param:"ema8_period" default:"8" min:"2" max:"50" step:"1"`
+	SMA21Period    int     `param:"sma21_period" default:"21" min:"2" max:"100" step:"1"`
+}
+
+func init() {
+	strategy.Register("CrossEMA", func(config *models.Config, kv *localkv.LocalKV) (strategy.Strategy, error) {
+		ema, err := NewCrossEMA(config, kv)
+		if err != nil {
+			return nil, err
+		}
+		return ema, nil
+	}, strategy.WithParameters(&CrossEMAParameters{}))
 }
 
 // NewCrossEMA is used for backtesting
 func NewCrossEMA(config *models.Config, kv *localkv.LocalKV) (*CrossEMA, error) {
+	// 从参数列表中获取参数值，类型错误或超出范围时返回错误
+	var ema CrossEMA
+	if err := config.Bind(&ema.params); err != nil {
+		return nil, err
+	}
+
 	data, err := models.NewStrategyData(config)
 	if err != nil {
 		return nil, err
 	}
-
-	// 从参数列表中获取参数值
-	var ema CrossEMA
 	ema.D = data
 	ema.kv = kv
 
-	for _, param := range config.Parameters {
-		switch param.Name {
-		case "dynamic_position_size":
-			ema.positionSize = param.Default.(bool)
-		case "risk_per_trade":
-			ema.riskPerTrade = param.Default.(float64)
-		case "dynamic_stop_loss":
-			ema.stopLoss = param.Default.(bool)
-		case "stop_loss_multiplier":
-			ema.stopLossMult = param.Default.(float64)
-		case "dynamic_take_profit":
-			ema.takeProfit = param.Default.(bool)
-		case "take_profit_multiplier":
-			ema.takeProfitMult = param.Default.(float64)
-		case "ema8_period":
-			ema.ema8Period = param.Default.(int)
-		case "sma21_period":
-			ema.sma21Period = param.Default.(int)
-		}
+	switch ema.params.Sizing {
+	case "fixed_fractional":
+		ema.Sizer = sizing.FixedFractional(0.3)
+	case "atr":
+		ema.Sizer = sizing.ATR(ema.params.RiskPerTrade, 14, ema.params.StopLossMult)
+	default:
+		ema.Sizer = sizing.FreeQuote(0.3)
 	}
 
 	return &ema, nil
@@ -66,8 +80,14 @@ func (e CrossEMA) Timeframe() string {
 	return "5m" // default 4h
 }
 
+// WarmupPeriod 至少为 60 根蜡烛，均线周期更长时需要多一根蜡烛才能计算交叉
 func (e CrossEMA) WarmupPeriod() int {
-	return 60
+	return max(60, max(e.params.EMA8Period, e.params.SMA21Period)+1)
+}
+
+// Parameters returns the parameters bound from the configuration
+func (e *CrossEMA) Parameters() interface{} {
+	return &e.params
 }
 
 func (d CrossEMA) GetD() *models.StrategyData {
@@ -76,8 +96,8 @@ func (d CrossEMA) GetD() *models.StrategyData {
 
 func (e CrossEMA) Indicators(df *azbot.Dataframe) []strategy.ChartIndicator {
 	// 通过2024年的数据测试，ema8和sma21的参数设置为12和31时，收益最高
-	df.Metadata["ema8"] = indicator.EMA(df.Close, e.ema8Period)   // 12, 11, 10, 8
-	df.Metadata["sma21"] = indicator.SMA(df.Close, e.sma21Period) // 31, 29, 27, 21
+	df.Metadata["ema8"] = indicator.EMA(df.Close, e.params.EMA8Period)   // 12, 11, 10, 8
+	df.Metadata["sma21"] = indicator.SMA(df.Close, e.params.SMA21Period) // 31, 29, 27, 21
 
 	return []strategy.ChartIndicator{
 		{
@@ -109,8 +129,6 @@ func (e CrossEMA) Indicators(df *azbot.Dataframe) []strategy.ChartIndicator {
 // - df: 包含蜡烛数据和技术指标的Dataframe对象
 // - broker: 用于执行交易操作的Broker服务
 func (e *CrossEMA) OnCandle(df *azbot.Dataframe, broker service.Broker) {
-	closePrice := df.Close.Last(0)
-
 	assetPosition, quotePosition, err := broker.Position(df.Pair)
 	if err != nil {
 		log.Error(err)
@@ -120,12 +138,17 @@ func (e *CrossEMA) OnCandle(df *azbot.Dataframe, broker service.Broker) {
 	if quotePosition >= 10 && // minimum quote position to trade
 		df.Metadata["ema8"].Crossover(df.Metadata["sma21"]) { // trade signal (EMA8 > SMA21)
 
-		// 每次买入可用余额的1/3
-		weight := 0.3 // TODO: 优化参数
-		amount := quotePosition * weight / closePrice
-		_, err := broker.CreateOrderMarket(azbot.SideTypeBuy, df.Pair, amount)
+		amount, err := e.Sizer.Size(df, broker)
 		if err != nil {
 			log.Error(err)
+			return
+		}
+
+		if amount > 0 {
+			_, err = broker.CreateOrderMarket(azbot.SideTypeBuy, df.Pair, amount)
+			if err != nil {
+				log.Error(err)
+			}
 		}
 
 		return
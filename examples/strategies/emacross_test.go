@@ -0,0 +1,60 @@
+package strategies
+
+import (
+	"context"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ezquant/azbot/azbot"
+	"github.com/ezquant/azbot/azbot/exchange"
+	"github.com/ezquant/azbot/azbot/plus/models"
+	"github.com/ezquant/azbot/azbot/sizing"
+	"github.com/ezquant/azbot/azbot/storage"
+)
+
+func TestCrossEMA_longPeriod(t *testing.T) {
+	ctx := context.Background()
+
+	// 周期大于默认的 60 根预热蜡烛
+	var ema CrossEMA
+	err := models.BindParameters([]models.Parameter{
+		{Name: "ema8_period", Default: 12},
+		{Name: "sma21_period", Default: 80},
+	}, &ema.params)
+	require.NoError(t, err)
+	require.Equal(t, 81, ema.WarmupPeriod())
+	ema.Sizer = sizing.FreeQuote(0.3)
+
+	csvFeed, err := exchange.NewCSVFeed(
+		ema.Timeframe(),
+		exchange.PairFeed{
+			Pair:      "BTCUSDT",
+			File:      "../../testdata/btc-1h.csv",
+			Timeframe: ema.Timeframe(),
+		},
+	)
+	require.NoError(t, err)
+
+	db, err := storage.FromMemory()
+	require.NoError(t, err)
+
+	paperWallet := exchange.NewPaperWallet(
+		ctx,
+		"USDT",
+		exchange.WithPaperAsset("USDT", 10000),
+		exchange.WithDataFeed(csvFeed),
+	)
+
+	bot, err := azbot.NewBot(ctx, azbot.Settings{Pairs: []string{"BTCUSDT"}},
+		paperWallet,
+		&ema,
+		azbot.WithStorage(db),
+		azbot.WithBacktest(paperWallet),
+		azbot.WithLogLevel(log.ErrorLevel),
+	)
+	require.NoError(t, err)
+	require.NoError(t, bot.Run(ctx))
+	require.NotZero(t, bot.Report().Trades)
+}
@@ -0,0 +1,87 @@
+package strategies
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/ezquant/azbot/azbot"
+	"github.com/ezquant/azbot/azbot/plus/localkv"
+	"github.com/ezquant/azbot/azbot/plus/models"
+	"github.com/ezquant/azbot/azbot/rebalance"
+	"github.com/ezquant/azbot/azbot/service"
+	"github.com/ezquant/azbot/azbot/strategy"
+	"github.com/ezquant/azbot/azbot/tools/log"
+)
+
+// Rebalance keeps the portfolio allocated to the asset weights, eg: models.Config.AssetWeights.
+// The portfolio is rebalanced monthly or when the weight of an asset drifts more than 5%.
+type Rebalance struct {
+	Quote   string
+	Weights map[string]float64
+	// Fee of market orders reserved from the cash of buys, eg: 0.001 for 0.1%
+	Fee float64
+
+	rebalancer *rebalance.Rebalancer
+}
+
+func init() {
+	strategy.Register("Rebalance", func(config *models.Config, _ *localkv.LocalKV) (strategy.Strategy, error) {
+		quote := config.Paper.Quote
+		if quote == "" {
+			quote = "USDT"
+		}
+		if len(config.AssetWeights) == 0 {
+			return nil, errors.New("rebalance: asset_weights is required")
+		}
+		// 回测和模拟交易使用同一配置时预留较高的手续费
+		fee := math.Max(config.BacktestConfig.Fee, config.Paper.Fee)
+		return &Rebalance{Quote: quote, Weights: config.AssetWeights, Fee: fee}, nil
+	})
+}
+
+func (r Rebalance) Timeframe() string {
+	return "1d"
+}
+
+func (r Rebalance) WarmupPeriod() int {
+	return 1
+}
+
+func (r Rebalance) Indicators(_ *azbot.Dataframe) []strategy.ChartIndicator {
+	return nil
+}
+
+func (r *Rebalance) OnCandle(_ *azbot.Dataframe, _ service.Broker) {}
+
+func (r *Rebalance) OnPortfolioCandle(dataframes map[string]*azbot.Dataframe, broker service.Broker) {
+	if r.rebalancer == nil {
+		rebalanceBroker, ok := broker.(rebalance.Broker)
+		if !ok {
+			log.Error("rebalance: broker without assets info")
+			return
+		}
+
+		r.rebalancer = rebalance.New(rebalanceBroker, r.Quote, r.Weights,
+			rebalance.WithTrigger(rebalance.Any(rebalance.Monthly(), rebalance.Drift(0.05))),
+			rebalance.WithCashBuffer(0.01),
+			rebalance.WithFee(r.Fee))
+	}
+
+	// portfolio is only rebalanced with prices of all pairs
+	if len(dataframes) < len(r.Weights) {
+		return
+	}
+
+	var now time.Time
+	for _, df := range dataframes {
+		if df.LastUpdate.After(now) {
+			now = df.LastUpdate
+		}
+	}
+
+	_, err := r.rebalancer.Rebalance(now, rebalance.Prices(dataframes))
+	if err != nil {
+		log.Error(err)
+	}
+}
@@ -18,22 +18,43 @@ import (
 // 确保 RLStrategy 实现 Strategy 接口
 var _ Strategy = (*RLStrategy)(nil)
 
-// 默认配置参数，当没有传入配置时使用
+// 策略网络的输入和输出维度，其他参数见 RLPPOParameters
 const (
-	defaultStateSize        = 16   // 增加状态空间维度，捕获更多市场特征
-	defaultActionSize       = 1    // 连续动作空间
-	defaultHiddenSize       = 128  // 增加神经网络隐藏层大小
-	defaultGamma            = 0.99 // 折扣因子
-	defaultClipEpsilon      = 0.2  // PPO裁剪参数
-	defaultEntropyCoef      = 0.01 // 熵系数，增加探索
-	defaultLearningRate     = 5e-4 // 学习率
-	defaultValueCoef        = 0.5  // 价值函数系数
-	defaultBatchSize        = 64   // 批量大小
-	defaultUpdateEpochs     = 3    // 每次更新的迭代次数
-	defaultLookbackWindow   = 48   // 对应4小时的历史数据（5分钟x48=4小时）
-	defaultTargetSharpRatio = 2.0  // 目标夏普率
+	defaultStateSize  = 16 // 增加状态空间维度，捕获更多市场特征
+	defaultActionSize = 1  // 连续动作空间
 )
 
+// RLPPOParameters 为 RLPPO 从配置绑定的参数，类型错误或超出范围时返回错误
+type RLPPOParameters struct {
+	// 神经网络参数
+	StateSize  int `param:"state_size" default:"16" min:"8" max:"24" step:"4"`
+	HiddenSize int `param:"hidden_size" default:"128" min:"64" max:"256" step:"32"`
+
+	// PPO算法参数，EntropyCoef 和 ValueCoef 保留给完整的 PPO 更新，目前未使用
+	Gamma        float64 `param:"gamma" default:"0.99" min:"0.95" max:"0.999" step:"0.01"`
+	ClipEpsilon  float64 `param:"clip_epsilon" default:"0.2" min:"0.1" max:"0.3" step:"0.05"`
+	EntropyCoef  float64 `param:"entropy_coef" default:"0.01" min:"0.001" max:"0.1" step:"0.01"`
+	LearningRate float64 `param:"learning_rate" default:"0.0005" min:"0.0001" max:"0.001" step:"0.0001"`
+	ValueCoef    float64 `param:"value_coef" default:"0.5" min:"0.1" max:"1" step:"0.1"`
+
+	// 批处理参数
+	BatchSize    int `param:"batch_size" default:"64" min:"32" max:"128" step:"16"`
+	UpdateEpochs int `param:"update_epochs" default:"3" min:"1" max:"5" step:"1"`
+
+	// 交易环境参数，默认回看 48 根K线（5分钟x48=4小时）
+	LookbackWindow   int     `param:"lookback_window" default:"48" min:"24" max:"96" step:"12"`
+	TargetSharpRatio float64 `param:"target_sharp_ratio" default:"2" min:"1" max:"3" step:"0.5"`
+
+	// 风险管理参数
+	MaxLeverage     float64 `param:"max_leverage" default:"3" min:"1" max:"5" step:"0.5"`
+	TransactionCost float64 `param:"transaction_cost" default:"0.00075" min:"0.0001" max:"0.002" step:"0.0001"`
+	RewardScaling   float64 `param:"reward_scaling" default:"10" min:"1" max:"20" step:"1"`
+
+	// 训练控制参数
+	TrainingMode bool `param:"training_mode" default:"true"`
+	UpdateFreq   int  `param:"update_freq" default:"60" min:"30" max:"120" step:"10"`
+}
+
 // PPOPolicy 定义PPO策略网络
 type PPOPolicy struct {
 	g         *gorgonia.ExprGraph
@@ -156,23 +177,16 @@ type TradingEnv struct {
 	rewardScaling    float64 // 奖励缩放系数
 }
 
-func NewTradingEnv(config map[string]interface{}) *TradingEnv {
-	// 从配置读取参数，如果配置中没有则使用默认值
-	lookbackWindow := getIntConfig(config, "lookback_window", defaultLookbackWindow)
-	transactionCost := getFloatConfig(config, "transaction_cost", 0.00075) // 0.001
-	maxLeverage := getFloatConfig(config, "max_leverage", 3.0)             // 1.0
-	rewardScaling := getFloatConfig(config, "reward_scaling", 10.0)
-	stateSize := getIntConfig(config, "state_size", defaultStateSize)
-
+func NewTradingEnv(params RLPPOParameters) *TradingEnv {
 	return &TradingEnv{
-		stateSize:        stateSize,
-		windowSize:       lookbackWindow,
+		stateSize:        params.StateSize,
+		windowSize:       params.LookbackWindow,
 		riskFreeRate:     0.02 / 365 / 288, // 每5分钟的无风险利率
 		initialEquity:    10000,
-		transactionCost:  transactionCost, // 交易成本
-		volatilityWindow: 24,              // 2小时窗口计算波动率
-		maxLeverage:      maxLeverage,     // 最大使用杠杆
-		rewardScaling:    rewardScaling,   // 放大奖励信号
+		transactionCost:  params.TransactionCost, // 交易成本
+		volatilityWindow: 24,                     // 2小时窗口计算波动率
+		maxLeverage:      params.MaxLeverage,     // 最大使用杠杆
+		rewardScaling:    params.RewardScaling,   // 放大奖励信号
 	}
 }
 
@@ -328,8 +342,9 @@ type RLStrategy struct {
 	targetSharpRatio float64
 
 	// 回测系统需要
-	data *models.StrategyData
-	kv   *localkv.LocalKV
+	data   *models.StrategyData
+	kv     *localkv.LocalKV
+	params RLPPOParameters
 }
 
 // Timeframe 实现策略接口
@@ -606,54 +621,11 @@ func (s *RLStrategy) updatePolicy() {
 	}
 }
 
-// 辅助函数：从配置中读取整数值
-func getIntConfig(config map[string]interface{}, key string, defaultValue int) int {
-	if val, ok := config[key]; ok {
-		if intVal, ok := val.(int); ok {
-			return intVal
-		}
-	}
-	return defaultValue
-}
-
-// 辅助函数：从配置中读取浮点值
-func getFloatConfig(config map[string]interface{}, key string, defaultValue float64) float64 {
-	if val, ok := config[key]; ok {
-		if floatVal, ok := val.(float64); ok {
-			return floatVal
-		}
-	}
-	return defaultValue
-}
-
-// 辅助函数：从配置中读取布尔值
-func getBoolConfig(config map[string]interface{}, key string, defaultValue bool) bool {
-	if val, ok := config[key]; ok {
-		if boolVal, ok := val.(bool); ok {
-			return boolVal
-		}
-	}
-	return defaultValue
-}
-
-// NewRLStrategy 创建新的策略实例，支持配置参数
-func NewRLStrategy(config map[string]interface{}) *RLStrategy {
-	// 从配置中读取参数
-	// stateSize 在 TradingEnv 中使用，不需要在这里单独保存
-	hiddenSize := getIntConfig(config, "hidden_size", defaultHiddenSize)
-	gamma := getFloatConfig(config, "gamma", defaultGamma)
-	clipEpsilon := getFloatConfig(config, "clip_epsilon", defaultClipEpsilon)
-	learningRate := getFloatConfig(config, "learning_rate", defaultLearningRate)
-	batchSize := getIntConfig(config, "batch_size", defaultBatchSize)
-	updateEpochs := getIntConfig(config, "update_epochs", defaultUpdateEpochs)
-	// lookbackWindow 在 TradingEnv 中已使用，不需要在这里单独保存
-	targetSharpRatio := getFloatConfig(config, "target_sharp_ratio", defaultTargetSharpRatio)
-	trainingMode := getBoolConfig(config, "training_mode", true)
-	updateFreq := getIntConfig(config, "update_freq", 60)
-
+// NewRLStrategy 使用绑定的参数创建新的策略实例
+func NewRLStrategy(params RLPPOParameters) *RLStrategy {
 	// 创建环境和策略
-	env := NewTradingEnv(config)
-	policy := NewPPOPolicy(hiddenSize, learningRate)
+	env := NewTradingEnv(params)
+	policy := NewPPOPolicy(params.HiddenSize, params.LearningRate)
 
 	return &RLStrategy{
 		policy:           policy,
@@ -668,33 +640,48 @@ func NewRLStrategy(config map[string]interface{}) *RLStrategy {
 		returns:          make([]float64, 0),
 		sharpeRatio:      0.0,
 		maxDrawdown:      0.0,
-		trainingMode:     trainingMode,
-		updateFreq:       updateFreq,
+		trainingMode:     params.TrainingMode,
+		updateFreq:       params.UpdateFreq,
 		stepCounter:      0,
-		gamma:            gamma,
-		clipEpsilon:      clipEpsilon,
-		batchSize:        batchSize,
-		updateEpochs:     updateEpochs,
-		targetSharpRatio: targetSharpRatio,
+		gamma:            params.Gamma,
+		clipEpsilon:      params.ClipEpsilon,
+		batchSize:        params.BatchSize,
+		updateEpochs:     params.UpdateEpochs,
+		targetSharpRatio: params.TargetSharpRatio,
+		params:           params,
 	}
 }
 
-// 这个函数用于支持无配置参数调用
+// 这个函数用于支持无配置参数调用，参数使用 default 标签的值
 func NewRLStrategyWithDefault() *RLStrategy {
-	return NewRLStrategy(make(map[string]interface{}))
+	var params RLPPOParameters
+	if err := models.BindParameters(nil, &params); err != nil {
+		panic(err)
+	}
+	return NewRLStrategy(params)
+}
+
+func init() {
+	strategy.Register("RLPPO", func(config *models.Config, kv *localkv.LocalKV) (strategy.Strategy, error) {
+		ppo, err := NewRLPPO(config, kv)
+		if err != nil {
+			return nil, err
+		}
+		return ppo, nil
+	}, strategy.WithParameters(&RLPPOParameters{}))
 }
 
 // NewRLPPO 从配置文件创建实例
 func NewRLPPO(config *models.Config, kv *localkv.LocalKV) (*RLStrategy, error) {
-	data, err := models.NewStrategyData(config)
-	if err != nil {
+	// 从参数列表中获取参数值，类型错误或超出范围时返回错误
+	var params RLPPOParameters
+	if err := config.Bind(&params); err != nil {
 		return nil, err
 	}
 
-	// 从配置中提取参数
-	params := make(map[string]interface{})
-	for _, param := range config.Parameters {
-		params[param.Name] = param.Default
+	data, err := models.NewStrategyData(config)
+	if err != nil {
+		return nil, err
 	}
 
 	// 创建策略
@@ -706,6 +693,11 @@ func NewRLPPO(config *models.Config, kv *localkv.LocalKV) (*RLStrategy, error) {
 	return strategy, nil
 }
 
+// Parameters 返回从配置绑定的参数
+func (s *RLStrategy) Parameters() interface{} {
+	return &s.params
+}
+
 // GetD 实现 Strategy 接口
 func (s *RLStrategy) GetD() *models.StrategyData {
 	return s.data